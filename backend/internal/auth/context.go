@@ -0,0 +1,43 @@
+package auth
+
+import "github.com/gin-gonic/gin"
+
+const principalKey = "auth.principal"
+
+// Principal is the authenticated caller attached to a request
+type Principal struct {
+	ID        string // Profile ID for the current role
+	AccountID string
+	Email     string
+	Role      string
+	Verified  bool
+	Approved  bool // false for physicians whose license has not been approved
+	Claims    *Claims
+}
+
+func (p *Principal) IsPatient() bool {
+	return p.Role == RolePatient
+}
+
+func (p *Principal) IsPhysician() bool {
+	return p.Role == RolePhysician
+}
+
+func (p *Principal) IsAdmin() bool {
+	return p.Role == RoleAdmin
+}
+
+// SetPrincipal stores the authenticated caller on the request context
+func SetPrincipal(c *gin.Context, p *Principal) {
+	c.Set(principalKey, p)
+}
+
+// CurrentPrincipal returns the authenticated caller, if any
+func CurrentPrincipal(c *gin.Context) (*Principal, bool) {
+	value, exists := c.Get(principalKey)
+	if !exists {
+		return nil, false
+	}
+	p, ok := value.(*Principal)
+	return p, ok
+}
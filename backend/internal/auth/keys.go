@@ -0,0 +1,399 @@
+package auth
+
+import (
+	"crypto"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// minRSAKeyBits is the smallest RSA modulus accepted for signing
+const minRSAKeyBits = 2048
+
+// keyManifestFile optionally sits in JWT_KEYS_DIR to schedule key rotation
+const keyManifestFile = "keys.json"
+
+var ErrNoSigningKey = errors.New("no JWT signing key configured")
+
+// signingKey is one private key the API can sign tokens with. A key signs
+// new tokens from ActiveFrom onwards (until a newer key takes over) and is
+// accepted for verification until ExpiresAt.
+type signingKey struct {
+	KID        string
+	Method     jwt.SigningMethod
+	Private    crypto.Signer
+	ActiveFrom time.Time
+	ExpiresAt  time.Time // zero means the key never expires
+}
+
+func (k *signingKey) expired(now time.Time) bool {
+	return !k.ExpiresAt.IsZero() && !now.Before(k.ExpiresAt)
+}
+
+// keySet holds every loaded key, keyed by kid
+type keySet struct {
+	mu   sync.RWMutex
+	keys map[string]*signingKey
+}
+
+var signingKeys = &keySet{keys: map[string]*signingKey{}}
+
+// keyManifest schedules rotation for the keys in JWT_KEYS_DIR
+type keyManifest struct {
+	Keys []struct {
+		KID        string    `json:"kid"`
+		File       string    `json:"file"`
+		ActiveFrom time.Time `json:"active_from"`
+		ExpiresAt  time.Time `json:"expires_at"`
+	} `json:"keys"`
+}
+
+// IsProduction reports whether the server runs in production mode (APP_ENV=production)
+func IsProduction() bool {
+	return os.Getenv("APP_ENV") == "production"
+}
+
+// LoadSigningKeys loads the JWT signing keys from JWT_KEYS_DIR or
+// JWT_PRIVATE_KEY_FILE. Outside production, a temporary key is generated
+// when neither is set; in production that is an error.
+func LoadSigningKeys() error {
+	keys, err := readConfiguredKeys()
+	if err != nil {
+		return err
+	}
+
+	if len(keys) == 0 {
+		if IsProduction() {
+			return fmt.Errorf("%w: set JWT_KEYS_DIR or JWT_PRIVATE_KEY_FILE", ErrNoSigningKey)
+		}
+		key, err := generateDevelopmentKey()
+		if err != nil {
+			return err
+		}
+		log.Println("WARNING: no JWT signing key configured; using a temporary key. Tokens will not survive a restart.")
+		keys = []*signingKey{key}
+	}
+
+	if _, err := pickSigningKey(keys, time.Now()); err != nil {
+		return err
+	}
+
+	signingKeys.replace(keys)
+	return nil
+}
+
+// WatchSigningKeys reloads keys from JWT_KEYS_DIR every interval so new keys
+// can be added and old ones retired without a restart. It returns
+// immediately when keys are not loaded from a directory.
+func WatchSigningKeys(interval time.Duration) {
+	if os.Getenv("JWT_KEYS_DIR") == "" || interval <= 0 {
+		return
+	}
+
+	go func() {
+		for range time.Tick(interval) {
+			keys, err := readConfiguredKeys()
+			if err == nil {
+				_, err = pickSigningKey(keys, time.Now())
+			}
+			if err != nil {
+				log.Printf("Failed to reload JWT signing keys, keeping current keys: %v", err)
+				continue
+			}
+			signingKeys.replace(keys)
+		}
+	}()
+}
+
+func (s *keySet) replace(keys []*signingKey) {
+	byKID := make(map[string]*signingKey, len(keys))
+	for _, key := range keys {
+		byKID[key.KID] = key
+	}
+
+	s.mu.Lock()
+	s.keys = byKID
+	s.mu.Unlock()
+}
+
+// snapshot returns the loaded keys that have not expired, sorted by kid
+func (s *keySet) snapshot(now time.Time) []*signingKey {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	keys := make([]*signingKey, 0, len(s.keys))
+	for _, key := range s.keys {
+		if !key.expired(now) {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].KID < keys[j].KID })
+	return keys
+}
+
+func (s *keySet) lookup(kid string, now time.Time) (*signingKey, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	key, ok := s.keys[kid]
+	if !ok || key.expired(now) {
+		return nil, false
+	}
+	return key, true
+}
+
+// pickSigningKey returns the key that should sign new tokens at now: the
+// most recently activated key that has not expired
+func pickSigningKey(keys []*signingKey, now time.Time) (*signingKey, error) {
+	var current *signingKey
+	for _, key := range keys {
+		if key.ActiveFrom.After(now) || key.expired(now) {
+			continue
+		}
+		if current == nil || key.ActiveFrom.After(current.ActiveFrom) ||
+			(key.ActiveFrom.Equal(current.ActiveFrom) && key.KID > current.KID) {
+			current = key
+		}
+	}
+	if current == nil {
+		return nil, fmt.Errorf("%w: no key is active at %s", ErrNoSigningKey, now.Format(time.RFC3339))
+	}
+	return current, nil
+}
+
+func readConfiguredKeys() ([]*signingKey, error) {
+	if dir := os.Getenv("JWT_KEYS_DIR"); dir != "" {
+		return readKeyDir(dir)
+	}
+
+	if file := os.Getenv("JWT_PRIVATE_KEY_FILE"); file != "" {
+		key, err := readKeyFile(file)
+		if err != nil {
+			return nil, err
+		}
+		if kid := os.Getenv("JWT_KEY_ID"); kid != "" {
+			key.KID = kid
+		}
+		return []*signingKey{key}, nil
+	}
+
+	return nil, nil
+}
+
+// readKeyDir loads keys listed in keys.json, or every *.pem file when there
+// is no manifest. Without a manifest all keys are active immediately and the
+// one with the greatest kid (file name) signs new tokens.
+func readKeyDir(dir string) ([]*signingKey, error) {
+	data, err := os.ReadFile(filepath.Join(dir, keyManifestFile))
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return nil, err
+	}
+
+	var keys []*signingKey
+	if err == nil {
+		var manifest keyManifest
+		if err := json.Unmarshal(data, &manifest); err != nil {
+			return nil, fmt.Errorf("invalid %s: %w", keyManifestFile, err)
+		}
+		for _, entry := range manifest.Keys {
+			if entry.KID == "" || entry.File == "" {
+				return nil, fmt.Errorf("invalid %s: every key needs a kid and a file", keyManifestFile)
+			}
+			key, err := readKeyFile(filepath.Join(dir, entry.File))
+			if err != nil {
+				return nil, err
+			}
+			key.KID = entry.KID
+			key.ActiveFrom = entry.ActiveFrom
+			key.ExpiresAt = entry.ExpiresAt
+			keys = append(keys, key)
+		}
+	} else {
+		files, err := filepath.Glob(filepath.Join(dir, "*.pem"))
+		if err != nil {
+			return nil, err
+		}
+		for _, file := range files {
+			key, err := readKeyFile(file)
+			if err != nil {
+				return nil, err
+			}
+			key.KID = strings.TrimSuffix(filepath.Base(file), ".pem")
+			keys = append(keys, key)
+		}
+	}
+
+	seen := map[string]bool{}
+	for _, key := range keys {
+		if seen[key.KID] {
+			return nil, fmt.Errorf("duplicate JWT key id %q", key.KID)
+		}
+		seen[key.KID] = true
+	}
+	return keys, nil
+}
+
+// readKeyFile parses a PEM-encoded RSA or Ed25519 private key. The kid
+// defaults to a thumbprint of the public key.
+func readKeyFile(path string) (*signingKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("%s: no PEM data found", path)
+	}
+
+	var parsed interface{}
+	switch block.Type {
+	case "RSA PRIVATE KEY":
+		parsed, err = x509.ParsePKCS1PrivateKey(block.Bytes)
+	case "PRIVATE KEY":
+		parsed, err = x509.ParsePKCS8PrivateKey(block.Bytes)
+	default:
+		return nil, fmt.Errorf("%s: unsupported PEM block %q", path, block.Type)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	key, err := newSigningKey(parsed)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return key, nil
+}
+
+func newSigningKey(private interface{}) (*signingKey, error) {
+	var key signingKey
+	switch k := private.(type) {
+	case *rsa.PrivateKey:
+		if k.N.BitLen() < minRSAKeyBits {
+			return nil, fmt.Errorf("RSA keys must be at least %d bits", minRSAKeyBits)
+		}
+		key.Method = jwt.SigningMethodRS256
+		key.Private = k
+	case ed25519.PrivateKey:
+		key.Method = jwt.SigningMethodEdDSA
+		key.Private = k
+	default:
+		return nil, errors.New("only RSA and Ed25519 private keys are supported")
+	}
+
+	kid, err := thumbprint(key.Private.Public())
+	if err != nil {
+		return nil, err
+	}
+	key.KID = kid
+	return &key, nil
+}
+
+func generateDevelopmentKey() (*signingKey, error) {
+	_, private, err := ed25519.GenerateKey(rand.Reader)
+	if err != nil {
+		return nil, err
+	}
+	return newSigningKey(private)
+}
+
+// thumbprint derives a key id from the SHA-256 of the DER public key
+func thumbprint(public crypto.PublicKey) (string, error) {
+	der, err := x509.MarshalPKIXPublicKey(public)
+	if err != nil {
+		return "", err
+	}
+	sum := sha256.Sum256(der)
+	return base64.RawURLEncoding.EncodeToString(sum[:12]), nil
+}
+
+// signToken signs claims with the current signing key, recording its kid
+// and the token type in the header. Claims must include kind's audience.
+func signToken(kind tokenType, claims jwt.Claims) (string, error) {
+	key, err := pickSigningKey(signingKeys.snapshot(time.Now()), time.Now())
+	if err != nil {
+		return "", err
+	}
+
+	token := jwt.NewWithClaims(key.Method, claims)
+	token.Header["kid"] = key.KID
+	token.Header["typ"] = kind.typ
+	return token.SignedString(key.Private)
+}
+
+// parseSignedToken verifies a token of the given type signed by any loaded,
+// unexpired key
+func parseSignedToken(kind tokenType, tokenString string, claims jwt.Claims) error {
+	token, err := jwt.ParseWithClaims(tokenString, claims, func(t *jwt.Token) (interface{}, error) {
+		if typ, _ := t.Header["typ"].(string); typ != kind.typ {
+			return nil, ErrInvalidToken
+		}
+		kid, _ := t.Header["kid"].(string)
+		key, ok := signingKeys.lookup(kid, time.Now())
+		if !ok {
+			return nil, ErrInvalidToken
+		}
+		if t.Method.Alg() != key.Method.Alg() {
+			return nil, ErrInvalidToken
+		}
+		return key.Private.Public(), nil
+	}, jwt.WithValidMethods([]string{jwt.SigningMethodRS256.Alg(), jwt.SigningMethodEdDSA.Alg()}), jwt.WithAudience(kind.audience))
+	if err != nil || !token.Valid {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+// JWK is a public key in JSON Web Key format
+type JWK struct {
+	KTY string `json:"kty"`
+	KID string `json:"kid"`
+	Use string `json:"use"`
+	Alg string `json:"alg"`
+	N   string `json:"n,omitempty"`
+	E   string `json:"e,omitempty"`
+	Crv string `json:"crv,omitempty"`
+	X   string `json:"x,omitempty"`
+}
+
+// PublicJWKS returns the public half of every unexpired key, including keys
+// scheduled to activate later, so verifiers can cache them ahead of rotation
+func PublicJWKS() []JWK {
+	keys := signingKeys.snapshot(time.Now())
+	jwks := make([]JWK, 0, len(keys))
+	for _, key := range keys {
+		jwk := JWK{KID: key.KID, Use: "sig", Alg: key.Method.Alg()}
+		switch public := key.Private.Public().(type) {
+		case *rsa.PublicKey:
+			jwk.KTY = "RSA"
+			jwk.N = base64.RawURLEncoding.EncodeToString(public.N.Bytes())
+			jwk.E = base64.RawURLEncoding.EncodeToString(big.NewInt(int64(public.E)).Bytes())
+		case ed25519.PublicKey:
+			jwk.KTY = "OKP"
+			jwk.Crv = "Ed25519"
+			jwk.X = base64.RawURLEncoding.EncodeToString(public)
+		default:
+			continue
+		}
+		jwks = append(jwks, jwk)
+	}
+	return jwks
+}
@@ -0,0 +1,102 @@
+package auth
+
+import (
+	"os"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/yourusername/health-connect/internal/models"
+)
+
+const MFAChallengeTTL = 5 * time.Minute
+
+// MaxMFAChallengeFailures is how many wrong codes a challenge accepts before
+// it is revoked and the user has to sign in again
+const MaxMFAChallengeFailures = 5
+
+var challengePolicy = throttlePolicy{
+	freeAttempts:  MaxMFAChallengeFailures,
+	lockThreshold: MaxMFAChallengeFailures,
+	lockDuration:  MFAChallengeTTL,
+	resetAfter:    MFAChallengeTTL,
+}
+
+// MFARequiredForPhysicians reports whether physicians must enroll in MFA
+// before accessing patient data (MFA_REQUIRED_FOR_PHYSICIANS=true)
+func MFARequiredForPhysicians() bool {
+	return os.Getenv("MFA_REQUIRED_FOR_PHYSICIANS") == "true"
+}
+
+// MFAChallengeClaims are carried by the token returned from the first login
+// step when the account has MFA enabled
+type MFAChallengeClaims struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// GenerateMFAChallenge signs a short-lived token proving the password step succeeded
+func GenerateMFAChallenge(accountID, email, role string) (string, error) {
+	now := time.Now()
+	claims := MFAChallengeClaims{
+		Email: email,
+		Role:  role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.NewString(),
+			Subject:   accountID,
+			Audience:  jwt.ClaimStrings{mfaChallengeToken.audience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(MFAChallengeTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	return signToken(mfaChallengeToken, claims)
+}
+
+// ParseMFAChallenge validates an MFA challenge token
+func ParseMFAChallenge(tokenString string) (*MFAChallengeClaims, error) {
+	claims := &MFAChallengeClaims{}
+	if err := parseSignedToken(mfaChallengeToken, tokenString, claims); err != nil {
+		return nil, err
+	}
+
+	if claims.ID == "" || claims.Subject == "" || !ValidRole(claims.Role) {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+func challengeThrottleKey(jti string) string {
+	return "mfa-challenge:" + jti
+}
+
+// RecordMFAFailure counts a wrong code against the account, the client IP
+// and the challenge itself, revoking the challenge once it has had
+// MaxMFAChallengeFailures. It reports whether the account has just been locked.
+func RecordMFAFailure(db *gorm.DB, claims *MFAChallengeClaims, ipKey string) (bool, error) {
+	locked, err := RecordLoginFailure(db, AccountThrottleKey(claims.Email), ipKey)
+	if err != nil {
+		return false, err
+	}
+
+	exhausted, err := recordFailure(db, challengeThrottleKey(claims.ID), challengePolicy)
+	if err != nil {
+		return locked, err
+	}
+	if exhausted {
+		return locked, RevokeMFAChallenge(db, claims)
+	}
+	return locked, nil
+}
+
+// RevokeMFAChallenge stops a challenge from being used again
+func RevokeMFAChallenge(db *gorm.DB, claims *MFAChallengeClaims) error {
+	if err := revokeAccessToken(db, claims.ID, claims.ExpiresAt.Time); err != nil {
+		return err
+	}
+	return db.Where("key = ?", challengeThrottleKey(claims.ID)).Delete(&models.LoginThrottle{}).Error
+}
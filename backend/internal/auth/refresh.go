@@ -0,0 +1,184 @@
+package auth
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/yourusername/health-connect/internal/models"
+)
+
+var (
+	ErrInvalidRefreshToken = errors.New("invalid or expired refresh token")
+	ErrRefreshTokenReused  = errors.New("refresh token reuse detected")
+)
+
+// TokenPair is an access token together with the refresh token that renews it
+type TokenPair struct {
+	AccessToken  string
+	RefreshToken string
+	ExpiresIn    int64 // access token lifetime in seconds
+}
+
+func hashToken(raw string) string {
+	sum := sha256.Sum256([]byte(raw))
+	return hex.EncodeToString(sum[:])
+}
+
+func newOpaqueToken() (string, error) {
+	b := make([]byte, 32)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return base64.RawURLEncoding.EncodeToString(b), nil
+}
+
+// IssueTokens creates an access token and a refresh token for the account
+// acting in role. An empty familyID starts a new token family (a new login
+// session).
+func IssueTokens(db *gorm.DB, accountID, email, role, familyID string) (*TokenPair, error) {
+	accessToken, jti, err := GenerateToken(accountID, email, role)
+	if err != nil {
+		return nil, err
+	}
+
+	raw, err := newOpaqueToken()
+	if err != nil {
+		return nil, err
+	}
+
+	if familyID == "" {
+		familyID = uuid.New().String()
+	}
+
+	refresh := models.RefreshToken{
+		UserID:        accountID,
+		Role:          role,
+		FamilyID:      familyID,
+		TokenHash:     hashToken(raw),
+		AccessTokenID: jti,
+		ExpiresAt:     time.Now().Add(RefreshTokenTTL),
+	}
+	if err := db.Create(&refresh).Error; err != nil {
+		return nil, err
+	}
+
+	return &TokenPair{
+		AccessToken:  accessToken,
+		RefreshToken: raw,
+		ExpiresIn:    int64(AccessTokenTTL.Seconds()),
+	}, nil
+}
+
+// ConsumeRefreshToken marks a refresh token as used and returns it so the
+// caller can issue the next pair in the same family. Presenting a token that
+// was already used revokes the entire family.
+func ConsumeRefreshToken(db *gorm.DB, raw string) (*models.RefreshToken, error) {
+	var token models.RefreshToken
+	if err := db.Where("token_hash = ?", hashToken(raw)).First(&token).Error; err != nil {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	if token.RevokedAt != nil {
+		if err := RevokeFamily(db, token.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	if time.Now().After(token.ExpiresAt) {
+		return nil, ErrInvalidRefreshToken
+	}
+
+	// Guard against two concurrent refreshes both succeeding
+	now := time.Now()
+	result := db.Model(&models.RefreshToken{}).
+		Where("id = ? AND revoked_at IS NULL", token.ID).
+		Update("revoked_at", now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		if err := RevokeFamily(db, token.FamilyID); err != nil {
+			return nil, err
+		}
+		return nil, ErrRefreshTokenReused
+	}
+
+	token.RevokedAt = &now
+	return &token, nil
+}
+
+// RevokeFamily revokes every refresh token in a family along with the
+// access tokens that were issued with them
+func RevokeFamily(db *gorm.DB, familyID string) error {
+	return db.Transaction(func(tx *gorm.DB) error {
+		var tokens []models.RefreshToken
+		if err := tx.Where("family_id = ?", familyID).Find(&tokens).Error; err != nil {
+			return err
+		}
+
+		for _, t := range tokens {
+			if t.AccessTokenID == "" {
+				continue
+			}
+			if err := revokeAccessToken(tx, t.AccessTokenID, t.CreatedAt.Add(AccessTokenTTL)); err != nil {
+				return err
+			}
+		}
+
+		return tx.Model(&models.RefreshToken{}).
+			Where("family_id = ? AND revoked_at IS NULL", familyID).
+			Update("revoked_at", time.Now()).Error
+	})
+}
+
+// RevokeRefreshToken revokes the family that a raw refresh token belongs to
+func RevokeRefreshToken(db *gorm.DB, raw string) error {
+	var token models.RefreshToken
+	if err := db.Where("token_hash = ?", hashToken(raw)).First(&token).Error; err != nil {
+		return ErrInvalidRefreshToken
+	}
+	return RevokeFamily(db, token.FamilyID)
+}
+
+// RevokeAccessToken adds an access token to the revocation list
+func RevokeAccessToken(db *gorm.DB, claims *Claims) error {
+	expiresAt := time.Now().Add(AccessTokenTTL)
+	if claims.ExpiresAt != nil {
+		expiresAt = claims.ExpiresAt.Time
+	}
+	return revokeAccessToken(db, claims.ID, expiresAt)
+}
+
+func revokeAccessToken(db *gorm.DB, jti string, expiresAt time.Time) error {
+	if time.Now().After(expiresAt) {
+		return nil
+	}
+	return db.Where(models.RevokedToken{JTI: jti}).
+		Attrs(models.RevokedToken{ExpiresAt: expiresAt}).
+		FirstOrCreate(&models.RevokedToken{}).Error
+}
+
+// IsTokenRevoked reports whether an access token jti is on the revocation list
+func IsTokenRevoked(db *gorm.DB, jti string) (bool, error) {
+	var count int64
+	err := db.Model(&models.RevokedToken{}).Where("jti = ?", jti).Count(&count).Error
+	return count > 0, err
+}
+
+// PurgeExpiredTokens removes revocation entries and refresh tokens that can
+// no longer be presented
+func PurgeExpiredTokens(db *gorm.DB) error {
+	now := time.Now()
+	if err := db.Where("expires_at < ?", now).Delete(&models.RevokedToken{}).Error; err != nil {
+		return err
+	}
+	return db.Where("expires_at < ?", now).Delete(&models.RefreshToken{}).Error
+}
@@ -0,0 +1,85 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/yourusername/health-connect/internal/models"
+)
+
+const PasswordResetTokenTTL = time.Hour
+
+var ErrInvalidResetToken = errors.New("invalid or expired reset token")
+
+// CreatePasswordResetToken issues a single-use reset token for the account
+// and invalidates any reset tokens issued before it. Only the hash is stored.
+func CreatePasswordResetToken(db *gorm.DB, accountID string) (string, error) {
+	raw, err := newOpaqueToken()
+	if err != nil {
+		return "", err
+	}
+
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&models.PasswordResetToken{}).
+			Where("user_id = ? AND used_at IS NULL", accountID).
+			Update("used_at", time.Now()).Error; err != nil {
+			return err
+		}
+
+		return tx.Create(&models.PasswordResetToken{
+			UserID:    accountID,
+			TokenHash: hashToken(raw),
+			ExpiresAt: time.Now().Add(PasswordResetTokenTTL),
+		}).Error
+	})
+	if err != nil {
+		return "", err
+	}
+	return raw, nil
+}
+
+// ConsumePasswordResetToken marks a reset token as used and returns it
+func ConsumePasswordResetToken(db *gorm.DB, raw string) (*models.PasswordResetToken, error) {
+	var token models.PasswordResetToken
+	if err := db.Where("token_hash = ?", hashToken(raw)).First(&token).Error; err != nil {
+		return nil, ErrInvalidResetToken
+	}
+
+	if token.UsedAt != nil || time.Now().After(token.ExpiresAt) {
+		return nil, ErrInvalidResetToken
+	}
+
+	now := time.Now()
+	result := db.Model(&models.PasswordResetToken{}).
+		Where("id = ? AND used_at IS NULL", token.ID).
+		Update("used_at", now)
+	if result.Error != nil {
+		return nil, result.Error
+	}
+	if result.RowsAffected == 0 {
+		return nil, ErrInvalidResetToken
+	}
+
+	token.UsedAt = &now
+	return &token, nil
+}
+
+// RevokeAccountSessions revokes every refresh token family belonging to an
+// account, signing it out everywhere and in every role
+func RevokeAccountSessions(db *gorm.DB, accountID string) error {
+	var familyIDs []string
+	if err := db.Model(&models.RefreshToken{}).
+		Where("user_id = ? AND revoked_at IS NULL", accountID).
+		Distinct().Pluck("family_id", &familyIDs).Error; err != nil {
+		return err
+	}
+
+	for _, familyID := range familyIDs {
+		if err := RevokeFamily(db, familyID); err != nil {
+			return err
+		}
+	}
+	return nil
+}
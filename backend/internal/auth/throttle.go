@@ -0,0 +1,150 @@
+package auth
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/yourusername/health-connect/internal/models"
+)
+
+// throttlePolicy controls how quickly failed logins slow down and lock a key
+type throttlePolicy struct {
+	freeAttempts  int           // failures allowed before backoff starts
+	lockThreshold int           // failures that trigger a lockout
+	lockDuration  time.Duration // how long a lockout lasts
+	resetAfter    time.Duration // failures are forgotten after this much quiet time
+}
+
+// AccountLockoutDuration is how long an account stays locked after too many failures
+const AccountLockoutDuration = 30 * time.Minute
+
+var (
+	accountPolicy = throttlePolicy{freeAttempts: 3, lockThreshold: 10, lockDuration: AccountLockoutDuration, resetAfter: 24 * time.Hour}
+	ipPolicy      = throttlePolicy{freeAttempts: 10, lockThreshold: 100, lockDuration: 15 * time.Minute, resetAfter: time.Hour}
+)
+
+const maxLoginBackoff = 5 * time.Minute
+
+// AccountThrottleKey identifies an account for login throttling. Keys are
+// built from the submitted email so unknown accounts are throttled too, and
+// are shared by every role the account holds.
+func AccountThrottleKey(email string) string {
+	return "account:" + strings.ToLower(strings.TrimSpace(email))
+}
+
+// IPThrottleKey identifies a client address for login throttling
+func IPThrottleKey(ip string) string {
+	return "ip:" + ip
+}
+
+func (p throttlePolicy) backoff(failures int) time.Duration {
+	if failures < p.freeAttempts {
+		return 0
+	}
+	shift := failures - p.freeAttempts
+	if shift > 8 {
+		return maxLoginBackoff
+	}
+	delay := time.Second << shift
+	if delay > maxLoginBackoff {
+		delay = maxLoginBackoff
+	}
+	return delay
+}
+
+// retryAfter returns how long the key must wait before another attempt
+func (p throttlePolicy) retryAfter(t *models.LoginThrottle, now time.Time) time.Duration {
+	if t.LockedUntil != nil && now.Before(*t.LockedUntil) {
+		return t.LockedUntil.Sub(now)
+	}
+	if now.Sub(t.LastFailureAt) >= p.resetAfter {
+		return 0
+	}
+	if wait := t.LastFailureAt.Add(p.backoff(t.Failures)).Sub(now); wait > 0 {
+		return wait
+	}
+	return 0
+}
+
+// LoginRetryAfter reports how long the account and client IP must wait
+// before another login attempt. Zero means the attempt may proceed.
+func LoginRetryAfter(db *gorm.DB, accountKey, ipKey string) (time.Duration, error) {
+	now := time.Now()
+	var wait time.Duration
+
+	for key, policy := range map[string]throttlePolicy{accountKey: accountPolicy, ipKey: ipPolicy} {
+		var throttle models.LoginThrottle
+		err := db.Where("key = ?", key).First(&throttle).Error
+		if errors.Is(err, gorm.ErrRecordNotFound) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		if w := policy.retryAfter(&throttle, now); w > wait {
+			wait = w
+		}
+	}
+	return wait, nil
+}
+
+// RecordLoginFailure counts a failed attempt against the account and IP. It
+// reports whether this failure has just locked the account.
+func RecordLoginFailure(db *gorm.DB, accountKey, ipKey string) (bool, error) {
+	var accountLocked bool
+	err := db.Transaction(func(tx *gorm.DB) error {
+		locked, err := recordFailure(tx, accountKey, accountPolicy)
+		if err != nil {
+			return err
+		}
+		accountLocked = locked
+		_, err = recordFailure(tx, ipKey, ipPolicy)
+		return err
+	})
+	return accountLocked, err
+}
+
+func recordFailure(tx *gorm.DB, key string, policy throttlePolicy) (bool, error) {
+	now := time.Now()
+
+	var throttle models.LoginThrottle
+	if err := tx.Where(models.LoginThrottle{Key: key}).FirstOrInit(&throttle).Error; err != nil {
+		return false, err
+	}
+
+	// Forget failures once the key has been quiet. After a lockout expires the
+	// key goes straight back into backoff rather than getting free attempts.
+	if now.Sub(throttle.LastFailureAt) >= policy.resetAfter {
+		throttle.Failures = 0
+		throttle.LockedUntil = nil
+	} else if throttle.LockedUntil != nil && !now.Before(*throttle.LockedUntil) {
+		throttle.Failures = policy.freeAttempts
+		throttle.LockedUntil = nil
+	}
+
+	throttle.Failures++
+	throttle.LastFailureAt = now
+
+	lockedNow := false
+	if throttle.Failures >= policy.lockThreshold && throttle.LockedUntil == nil {
+		until := now.Add(policy.lockDuration)
+		throttle.LockedUntil = &until
+		lockedNow = true
+	}
+
+	return lockedNow, tx.Save(&throttle).Error
+}
+
+// RecordLoginSuccess clears the failure count for an account
+func RecordLoginSuccess(db *gorm.DB, accountKey string) error {
+	return db.Where("key = ?", accountKey).Delete(&models.LoginThrottle{}).Error
+}
+
+// UnlockLogin removes any failure count or lockout for a throttle key
+func UnlockLogin(db *gorm.DB, key string) (bool, error) {
+	result := db.Where("key = ?", key).Delete(&models.LoginThrottle{})
+	return result.RowsAffected > 0, result.Error
+}
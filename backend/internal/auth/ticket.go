@@ -0,0 +1,77 @@
+package auth
+
+import (
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const StreamTicketTTL = time.Minute
+
+// StreamTicketClaims are carried by the ticket that opens the event stream.
+// EventSource cannot set headers, so the stream takes this short-lived
+// ticket in its query string rather than the access token. The ticket keeps
+// the jti and expiry of the access token it was issued for so the stream
+// can end with that session.
+type StreamTicketClaims struct {
+	Email            string           `json:"email"`
+	Role             string           `json:"role"`
+	SessionID        string           `json:"sid"`
+	SessionExpiresAt *jwt.NumericDate `json:"sxp"`
+	jwt.RegisteredClaims
+}
+
+// GenerateStreamTicket signs a ticket for the session of an access token.
+// It expires after StreamTicketTTL, or with the access token if sooner.
+func GenerateStreamTicket(session *Claims) (string, error) {
+	now := time.Now()
+	expiresAt := now.Add(StreamTicketTTL)
+	if session.ExpiresAt != nil && session.ExpiresAt.Before(expiresAt) {
+		expiresAt = session.ExpiresAt.Time
+	}
+
+	claims := StreamTicketClaims{
+		Email:            session.Email,
+		Role:             session.Role,
+		SessionID:        session.ID,
+		SessionExpiresAt: session.ExpiresAt,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        uuid.New().String(),
+			Subject:   session.Subject,
+			Audience:  jwt.ClaimStrings{streamTicket.audience},
+			ExpiresAt: jwt.NewNumericDate(expiresAt),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	return signToken(streamTicket, claims)
+}
+
+// ParseStreamTicket validates a stream ticket
+func ParseStreamTicket(tokenString string) (*StreamTicketClaims, error) {
+	claims := &StreamTicketClaims{}
+	if err := parseSignedToken(streamTicket, tokenString, claims); err != nil {
+		return nil, err
+	}
+
+	if claims.SessionID == "" || claims.SessionExpiresAt == nil || claims.Subject == "" || claims.Email == "" || !ValidRole(claims.Role) {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
+
+// Session returns the claims of the access token the ticket was issued for
+func (t *StreamTicketClaims) Session() *Claims {
+	return &Claims{
+		Email: t.Email,
+		Role:  t.Role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        t.SessionID,
+			Subject:   t.Subject,
+			Audience:  jwt.ClaimStrings{accessToken.audience},
+			ExpiresAt: t.SessionExpiresAt,
+		},
+	}
+}
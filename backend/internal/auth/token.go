@@ -0,0 +1,92 @@
+package auth
+
+import (
+	"errors"
+	"time"
+
+	"github.com/golang-jwt/jwt/v5"
+	"github.com/google/uuid"
+)
+
+const (
+	RolePatient   = "patient"
+	RolePhysician = "physician"
+	RoleAdmin     = "admin"
+)
+
+// ValidRole reports whether role is one the API issues tokens for
+func ValidRole(role string) bool {
+	return role == RolePatient || role == RolePhysician || role == RoleAdmin
+}
+
+const (
+	AccessTokenTTL  = 15 * time.Minute
+	RefreshTokenTTL = 30 * 24 * time.Hour
+)
+
+var ErrInvalidToken = errors.New("invalid or expired token")
+
+// AccessTokenAudience is the aud claim of access tokens. Services verifying
+// access tokens against the published JWKS must require it, along with the
+// at+jwt typ header, so that other tokens signed with the same keys are not
+// accepted as access tokens.
+const AccessTokenAudience = "health-connect:access"
+
+// tokenType tells the kinds of signed token apart. Each has its own typ
+// header and aud claim, and parsing one kind rejects the others.
+type tokenType struct {
+	typ      string
+	audience string
+}
+
+var (
+	accessToken       = tokenType{typ: "at+jwt", audience: AccessTokenAudience}
+	mfaChallengeToken = tokenType{typ: "mfa-challenge+jwt", audience: "health-connect:mfa-challenge"}
+	verificationToken = tokenType{typ: "email-verification+jwt", audience: "health-connect:email-verification"}
+	streamTicket      = tokenType{typ: "stream-ticket+jwt", audience: "health-connect:event-stream"}
+)
+
+type Claims struct {
+	Email string `json:"email"`
+	Role  string `json:"role"`
+	jwt.RegisteredClaims
+}
+
+// GenerateToken issues a short-lived signed JWT for the account acting in the
+// given role. It returns the token together with its jti so it can be
+// revoked later.
+func GenerateToken(accountID, email, role string) (string, string, error) {
+	now := time.Now()
+	jti := uuid.New().String()
+	claims := Claims{
+		Email: email,
+		Role:  role,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ID:        jti,
+			Subject:   accountID,
+			Audience:  jwt.ClaimStrings{accessToken.audience},
+			ExpiresAt: jwt.NewNumericDate(now.Add(AccessTokenTTL)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	signed, err := signToken(accessToken, claims)
+	if err != nil {
+		return "", "", err
+	}
+	return signed, jti, nil
+}
+
+// ParseToken validates a signed JWT and returns its claims
+func ParseToken(tokenString string) (*Claims, error) {
+	claims := &Claims{}
+	if err := parseSignedToken(accessToken, tokenString, claims); err != nil {
+		return nil, err
+	}
+
+	if claims.ID == "" || claims.Subject == "" || claims.Email == "" || !ValidRole(claims.Role) {
+		return nil, ErrInvalidToken
+	}
+
+	return claims, nil
+}
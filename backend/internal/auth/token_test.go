@@ -0,0 +1,118 @@
+package auth
+
+import (
+	"testing"
+
+	"github.com/golang-jwt/jwt/v5"
+)
+
+func useDevelopmentKey(t *testing.T) {
+	t.Helper()
+	key, err := generateDevelopmentKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	signingKeys.replace([]*signingKey{key})
+}
+
+func TestTokensOnlyParseAsTheirOwnType(t *testing.T) {
+	useDevelopmentKey(t)
+
+	access, _, err := GenerateToken("account-1", "pat@example.com", RolePatient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	challenge, err := GenerateMFAChallenge("account-1", "pat@example.com", RolePatient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	verification, err := GenerateVerificationToken("account-1", "pat@example.com")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ParseToken(access); err != nil {
+		t.Errorf("ParseToken(access) = %v", err)
+	}
+	if _, err := ParseMFAChallenge(challenge); err != nil {
+		t.Errorf("ParseMFAChallenge(challenge) = %v", err)
+	}
+	if _, err := ParseVerificationToken(verification); err != nil {
+		t.Errorf("ParseVerificationToken(verification) = %v", err)
+	}
+
+	for name, token := range map[string]string{"challenge": challenge, "verification": verification} {
+		if _, err := ParseToken(token); err == nil {
+			t.Errorf("ParseToken accepted an %s token", name)
+		}
+	}
+	for name, token := range map[string]string{"access": access, "verification": verification} {
+		if _, err := ParseMFAChallenge(token); err == nil {
+			t.Errorf("ParseMFAChallenge accepted an %s token", name)
+		}
+	}
+	for name, token := range map[string]string{"access": access, "challenge": challenge} {
+		if _, err := ParseVerificationToken(token); err == nil {
+			t.Errorf("ParseVerificationToken accepted an %s token", name)
+		}
+	}
+}
+
+func TestAccessTokenClaims(t *testing.T) {
+	useDevelopmentKey(t)
+
+	access, _, err := GenerateToken("account-1", "pat@example.com", RolePatient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	claims := &Claims{}
+	token, _, err := jwt.NewParser().ParseUnverified(access, claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if token.Header["typ"] != "at+jwt" {
+		t.Errorf("typ = %v, want at+jwt", token.Header["typ"])
+	}
+	if len(claims.Audience) != 1 || claims.Audience[0] != AccessTokenAudience {
+		t.Errorf("aud = %v, want [%s]", claims.Audience, AccessTokenAudience)
+	}
+}
+
+func TestStreamTicketCarriesItsSession(t *testing.T) {
+	useDevelopmentKey(t)
+
+	access, jti, err := GenerateToken("account-1", "pat@example.com", RolePatient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	session, err := ParseToken(access)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ticket, err := GenerateStreamTicket(session)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := ParseToken(ticket); err == nil {
+		t.Error("ParseToken accepted a stream ticket")
+	}
+	if _, err := ParseStreamTicket(access); err == nil {
+		t.Error("ParseStreamTicket accepted an access token")
+	}
+
+	claims, err := ParseStreamTicket(ticket)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if claims.ID == jti {
+		t.Error("stream ticket reuses the access token's jti")
+	}
+	if !claims.ExpiresAt.Before(session.ExpiresAt.Time) {
+		t.Errorf("ticket expires at %v, not before the session at %v", claims.ExpiresAt, session.ExpiresAt)
+	}
+	got := claims.Session()
+	if got.ID != jti || got.Subject != "account-1" || got.Role != RolePatient || !got.ExpiresAt.Equal(session.ExpiresAt.Time) {
+		t.Errorf("Session() = %+v, want the access token's claims", got)
+	}
+}
@@ -0,0 +1,63 @@
+package auth
+
+import (
+	"testing"
+	"time"
+)
+
+// RFC 6238 appendix B test vectors for SHA-1, truncated to six digits
+var rfc6238Secret = totpEncoding.EncodeToString([]byte("12345678901234567890"))
+
+var rfc6238Vectors = []struct {
+	unix int64
+	code string
+}{
+	{59, "287082"},
+	{1111111109, "081804"},
+	{1111111111, "050471"},
+	{1234567890, "005924"},
+	{2000000000, "279037"},
+	{20000000000, "353130"},
+}
+
+func TestTOTPCode(t *testing.T) {
+	for _, tt := range rfc6238Vectors {
+		if got := totpCode([]byte("12345678901234567890"), tt.unix/totpPeriod); got != tt.code {
+			t.Errorf("totpCode at %d = %s, want %s", tt.unix, got, tt.code)
+		}
+	}
+}
+
+func TestValidateTOTP(t *testing.T) {
+	for _, tt := range rfc6238Vectors {
+		at := time.Unix(tt.unix, 0)
+		step, ok := ValidateTOTP(rfc6238Secret, tt.code, at)
+		if !ok || step != tt.unix/totpPeriod {
+			t.Errorf("ValidateTOTP at %d = %d, %v, want %d, true", tt.unix, step, ok, tt.unix/totpPeriod)
+		}
+	}
+
+	at := time.Unix(1111111111, 0)
+	tests := []struct {
+		name string
+		at   time.Time
+		code string
+		want bool
+	}{
+		{"one step early", at.Add(-totpPeriod * time.Second), "050471", true},
+		{"one step late", at.Add(totpPeriod * time.Second), "050471", true},
+		{"two steps late", at.Add(2 * totpPeriod * time.Second), "050471", false},
+		{"surrounding spaces", at, " 050471 ", true},
+		{"wrong code", at, "050472", false},
+		{"too short", at, "50471", false},
+	}
+	for _, tt := range tests {
+		if _, ok := ValidateTOTP(rfc6238Secret, tt.code, tt.at); ok != tt.want {
+			t.Errorf("%s: ValidateTOTP = %v, want %v", tt.name, ok, tt.want)
+		}
+	}
+
+	if _, ok := ValidateTOTP("not base32!", "050471", at); ok {
+		t.Error("ValidateTOTP accepted an invalid secret")
+	}
+}
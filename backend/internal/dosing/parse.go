@@ -0,0 +1,542 @@
+package dosing
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode"
+)
+
+// ErrNoFrequency is returned for sigs that do not say how often to take a
+// medication
+var ErrNoFrequency = errors.New("no dosing frequency found")
+
+var numberWords = map[string]string{
+	"one": "1", "two": "2", "three": "3", "four": "4", "five": "5", "six": "6",
+	"seven": "7", "eight": "8", "nine": "9", "ten": "10", "twelve": "12", "half": "0.5",
+}
+
+// units maps unit words to the unit recorded in schedules
+var units = map[string]string{
+	"tab": "tablet", "tabs": "tablet", "tablet": "tablet", "tablets": "tablet",
+	"cap": "capsule", "caps": "capsule", "capsule": "capsule", "capsules": "capsule",
+	"ml": "ml", "mls": "ml", "cc": "ml",
+	"tsp": "teaspoon", "teaspoon": "teaspoon", "teaspoons": "teaspoon", "teaspoonful": "teaspoon",
+	"tbsp": "tablespoon", "tablespoon": "tablespoon", "tablespoons": "tablespoon",
+	"puff": "puff", "puffs": "puff", "inhalation": "puff", "inhalations": "puff",
+	"spray": "spray", "sprays": "spray",
+	"drop": "drop", "drops": "drop", "gtt": "drop", "gtts": "drop",
+	"patch": "patch", "patches": "patch",
+	"supp": "suppository", "suppository": "suppository", "suppositories": "suppository",
+	"application": "application", "applications": "application",
+	"lozenge": "lozenge", "lozenges": "lozenge",
+	"mg": "mg", "mcg": "mcg", "ug": "mcg", "g": "g", "gm": "g", "gram": "g", "grams": "g",
+	"meq": "meq", "unit": "unit", "units": "unit", "iu": "iu",
+}
+
+// strengths are units that measure the drug rather than count doses. "1 tab"
+// is preferred to "500 mg" when a sig gives both.
+var strengths = map[string]bool{"mg": true, "mcg": true, "g": true, "meq": true, "unit": true, "iu": true}
+
+// unitRoutes is the route implied by a unit when the sig names none
+var unitRoutes = map[string]string{
+	"tablet": RouteOral, "capsule": RouteOral, "teaspoon": RouteOral, "tablespoon": RouteOral, "lozenge": RouteOral,
+	"puff": RouteInhaled, "patch": RouteTransdermal, "suppository": RouteRectal, "application": RouteTopical,
+}
+
+var routes = map[string]string{
+	"po": RouteOral, "oral": RouteOral, "orally": RouteOral,
+	"sl": RouteSublingual, "sublingual": RouteSublingual, "sublingually": RouteSublingual,
+	"top": RouteTopical, "topical": RouteTopical, "topically": RouteTopical,
+	"td": RouteTransdermal, "transdermal": RouteTransdermal, "transdermally": RouteTransdermal,
+	"inh": RouteInhaled, "inhale": RouteInhaled, "inhaled": RouteInhaled, "inhalation": RouteInhaled,
+	"nasal": RouteNasal, "nasally": RouteNasal, "intranasal": RouteNasal, "intranasally": RouteNasal, "nostril": RouteNasal, "nostrils": RouteNasal,
+	"ou": RouteOphthalmic, "eye": RouteOphthalmic, "eyes": RouteOphthalmic, "ophthalmic": RouteOphthalmic,
+	"ear": RouteOtic, "ears": RouteOtic, "otic": RouteOtic,
+	"pr": RouteRectal, "rectal": RouteRectal, "rectally": RouteRectal,
+	"sc": RouteSubcutaneous, "sq": RouteSubcutaneous, "subq": RouteSubcutaneous, "subcut": RouteSubcutaneous, "subcutaneous": RouteSubcutaneous, "subcutaneously": RouteSubcutaneous,
+	"im": RouteIntramuscular, "intramuscular": RouteIntramuscular, "intramuscularly": RouteIntramuscular,
+	"iv": RouteIntravenous, "intravenous": RouteIntravenous, "intravenously": RouteIntravenous,
+}
+
+// dailyWords are abbreviations for a number of doses a day
+var dailyWords = map[string]int{"qd": 1, "daily": 1, "qday": 1, "bid": 2, "tid": 3, "qid": 4}
+
+// timeWords name a time of day, including abbreviations like qhs
+var timeWords = map[string]string{
+	"qam": "08:00", "morning": "08:00", "breakfast": "08:00",
+	"noon": "12:00", "lunch": "12:00", "afternoon": "14:00",
+	"qpm": "18:00", "evening": "18:00", "dinner": "18:00", "supper": "18:00",
+	"qhs": "21:00", "hs": "21:00", "bedtime": "21:00", "night": "21:00", "nightly": "21:00",
+	"midnight": "00:00",
+}
+
+// tokenize splits a sig into lowercase words and numbers, dropping
+// punctuation: "Take 1-2 tabs p.o. q4-6h" becomes
+// [take 1-2 tabs po q 4-6 h]. Number words become digits.
+func tokenize(sig string) []string {
+	runes := []rune(strings.ToLower(sig))
+	var tokens []string
+	for i := 0; i < len(runes); {
+		j := i + 1
+		switch {
+		case unicode.IsDigit(runes[i]):
+			for j < len(runes) && (unicode.IsDigit(runes[j]) || strings.ContainsRune("./:-", runes[j]) && j+1 < len(runes) && unicode.IsDigit(runes[j+1])) {
+				j++
+			}
+			tokens = append(tokens, string(runes[i:j]))
+		case unicode.IsLetter(runes[i]):
+			for j < len(runes) && (unicode.IsLetter(runes[j]) || runes[j] == '.' && j+1 < len(runes) && unicode.IsLetter(runes[j+1])) {
+				j++
+			}
+			word := strings.ReplaceAll(string(runes[i:j]), ".", "")
+			if number, ok := numberWords[word]; ok {
+				word = number
+			}
+			tokens = append(tokens, word)
+		}
+		i = j
+	}
+	return tokens
+}
+
+// number reads "2", "0.5", "1/2" or a range such as "1-2"
+func number(token string) (float64, float64, bool) {
+	if low, high, ok := strings.Cut(token, "-"); ok {
+		a, okA := fraction(low)
+		b, okB := fraction(high)
+		return a, b, okA && okB && b > a
+	}
+	value, ok := fraction(token)
+	return value, 0, ok
+}
+
+func fraction(token string) (float64, bool) {
+	if num, den, ok := strings.Cut(token, "/"); ok {
+		a, errA := strconv.ParseFloat(num, 64)
+		b, errB := strconv.ParseFloat(den, 64)
+		return a / b, errA == nil && errB == nil && b != 0
+	}
+	value, err := strconv.ParseFloat(token, 64)
+	return value, err == nil
+}
+
+// count reads a whole number such as the 8 in "every 8 hours", taking the
+// lower end of a range
+func count(token string) (int, bool) {
+	value, _, ok := number(token)
+	if !ok || value < 1 || value != float64(int(value)) {
+		return 0, false
+	}
+	return int(value), true
+}
+
+// clockNumber reads "8" or "8:30" as an hour and minute
+func clockNumber(token string) (int, int, bool) {
+	hours, minutes, hasMinutes := strings.Cut(token, ":")
+	hour, err := strconv.Atoi(hours)
+	if err != nil {
+		return 0, 0, false
+	}
+	minute := 0
+	if hasMinutes {
+		if minute, err = strconv.Atoi(minutes); err != nil || len(minutes) != 2 || minute > 59 {
+			return 0, 0, false
+		}
+	}
+	return hour, minute, true
+}
+
+// segment is what one part of a sig says; tapers have a part per step,
+// separated by "then"
+type segment struct {
+	Schedule
+	strength bool // Amount is a strength like "500 mg" rather than "1 tab"
+	err      error
+}
+
+func (s *segment) empty() bool {
+	return s.Amount == 0 && s.TimesPerDay == 0 && len(s.Times) == 0 && s.IntervalHours == 0 &&
+		s.EveryDays == 0 && !s.AsNeeded && s.Days == 0
+}
+
+type parser struct {
+	tokens []string
+	pos    int
+	n      int // The number matched by the last "#" in accept
+	seg    segment
+}
+
+// accept advances past words if the tokens at the current position match
+// them. A word may list alternatives separated by "|", and "#" matches a
+// whole number.
+func (p *parser) accept(words ...string) bool {
+	if p.pos+len(words) > len(p.tokens) {
+		return false
+	}
+	n := 0
+	for i, word := range words {
+		token := p.tokens[p.pos+i]
+		if word == "#" {
+			var ok bool
+			if n, ok = count(token); !ok {
+				return false
+			}
+			continue
+		}
+		matched := false
+		for _, alternative := range strings.Split(word, "|") {
+			matched = matched || token == alternative
+		}
+		if !matched {
+			return false
+		}
+	}
+	p.pos += len(words)
+	p.n = n
+	return true
+}
+
+func (p *parser) fail(format string, args ...any) {
+	if p.seg.err == nil {
+		p.seg.err = fmt.Errorf(format, args...)
+	}
+}
+
+func (p *parser) setTimesPerDay(n int) {
+	if p.seg.TimesPerDay != 0 && p.seg.TimesPerDay != n {
+		p.fail("conflicting frequencies: %d and %d times a day", p.seg.TimesPerDay, n)
+	}
+	p.seg.TimesPerDay = n
+}
+
+func (p *parser) setEveryDays(n int) {
+	if p.seg.EveryDays != 0 && p.seg.EveryDays != n {
+		p.fail("conflicting frequencies: every %d and every %d days", p.seg.EveryDays, n)
+	}
+	p.seg.EveryDays = n
+}
+
+// frequency reads how many times a day, or how many days apart, doses are
+func (p *parser) frequency() bool {
+	switch {
+	case p.accept("once|1", "a|per|each", "week|wk"), p.accept("once|1", "weekly"),
+		p.accept("every|each|q", "week|wk"), p.accept("weekly"):
+		p.setEveryDays(7)
+	case p.accept("every|q", "#", "weeks|wks"):
+		p.setEveryDays(7 * p.n)
+	case p.accept("every|each|q", "other|alternate", "day"), p.accept("on", "alternate", "days"), p.accept("qod"):
+		p.setEveryDays(2)
+	case p.accept("every|q", "#", "days|d"):
+		p.setEveryDays(p.n)
+	case p.accept("once", "a|per|each", "day"), p.accept("once", "daily"):
+		p.setTimesPerDay(1)
+	case p.accept("twice", "a|per|each", "day"), p.accept("twice", "daily"):
+		p.setTimesPerDay(2)
+	case p.accept("thrice", "a|per|each", "day"), p.accept("thrice", "daily"):
+		p.setTimesPerDay(3)
+	case p.accept("#", "times|x", "a|per|each", "day"), p.accept("#", "times|x", "daily"):
+		p.setTimesPerDay(p.n)
+	case p.accept("every|each|q|a|per", "day"):
+		p.setTimesPerDay(1)
+	case p.accept("daily"):
+		// Adds nothing to "every other day" or "daily at bedtime"
+		if p.seg.EveryDays == 0 && len(p.seg.Times) == 0 {
+			p.setTimesPerDay(1)
+		}
+	case dailyWords[p.tokens[p.pos]] > 0:
+		p.setTimesPerDay(dailyWords[p.tokens[p.pos]])
+		p.pos++
+	default:
+		return false
+	}
+	return true
+}
+
+// interval reads "q8h" or "every 8 hours"; for ranges like "every 4-6
+// hours" the shorter interval is used
+func (p *parser) interval() bool {
+	hours := 0
+	switch {
+	case p.accept("every|q", "#", "hours|hour|hrs|hr|h"):
+		hours = p.n
+	case p.accept("every|q", "hour|hr"):
+		hours = 1
+	default:
+		return false
+	}
+	if p.seg.IntervalHours != 0 && p.seg.IntervalHours != hours {
+		p.fail("conflicting intervals: every %d and every %d hours", p.seg.IntervalHours, hours)
+	}
+	p.seg.IntervalHours = hours
+	return true
+}
+
+func (p *parser) asNeeded() bool {
+	if p.accept("prn") || p.accept("as|when|if", "needed|required|necessary") {
+		p.seg.AsNeeded = true
+		return true
+	}
+	return false
+}
+
+// clockTime reads a time of day: "8am", "8:30 pm", "20:00", "at 8" or a
+// word such as "bedtime"
+func (p *parser) clockTime() bool {
+	token := p.tokens[p.pos]
+	value, ok := timeWords[token]
+	if ok {
+		p.pos++
+	} else {
+		hour, minute, ok := clockNumber(token)
+		if !ok {
+			return false
+		}
+		switch suffix := p.peek(1); {
+		case suffix == "am" || suffix == "pm":
+			if hour < 1 || hour > 12 {
+				return false
+			}
+			hour %= 12
+			if suffix == "pm" {
+				hour += 12
+			}
+			p.pos += 2
+		case strings.Contains(token, ":") || p.pos > 0 && p.tokens[p.pos-1] == "at":
+			if hour > 23 {
+				return false
+			}
+			p.pos++
+		default:
+			return false
+		}
+		value = formatClock(hour*60 + minute)
+	}
+
+	for _, existing := range p.seg.Times {
+		if existing == value {
+			return true
+		}
+	}
+	p.seg.Times = append(p.seg.Times, value)
+	return true
+}
+
+func (p *parser) peek(offset int) string {
+	if p.pos+offset < len(p.tokens) {
+		return p.tokens[p.pos+offset]
+	}
+	return ""
+}
+
+// duration reads the length of a course, "for 10 days" or "x 2 weeks"
+func (p *parser) duration() bool {
+	days := 0
+	switch {
+	case p.accept("for|x", "#", "days|day|d"):
+		days = p.n
+	case p.accept("for|x", "#", "weeks|week|wks|wk"):
+		days = 7 * p.n
+	case p.accept("for", "a", "week"):
+		days = 7
+	default:
+		return false
+	}
+	if p.seg.Days != 0 && p.seg.Days != days {
+		p.fail("conflicting durations: %d and %d days", p.seg.Days, days)
+	}
+	p.seg.Days = days
+	return true
+}
+
+// amount reads the amount of each dose, such as "1 tab", "1-2 puffs" or
+// "500 mg". A count of tablets, puffs and so on is preferred to a strength.
+func (p *parser) amount() bool {
+	low, high, ok := number(p.tokens[p.pos])
+	if !ok {
+		return false
+	}
+	unit := units[p.peek(1)]
+	if unit == "" {
+		// A bare number, as in "take 2 by mouth", counts only if no amount is known
+		if p.seg.Amount == 0 {
+			p.seg.Amount, p.seg.MaxAmount = low, high
+		}
+		p.pos++
+		return true
+	}
+	p.pos += 2
+
+	strength := strengths[unit]
+	switch {
+	case p.seg.Amount == 0, p.seg.strength && !strength:
+		p.seg.Amount, p.seg.MaxAmount, p.seg.Unit, p.seg.strength = low, high, unit, strength
+	case strength && !p.seg.strength:
+		// Keep "1 tab" over the "500 mg" it contains
+	case p.seg.Amount != low || p.seg.MaxAmount != high || p.seg.Unit != unit:
+		p.fail("conflicting amounts: %g %s and %g %s", p.seg.Amount, p.seg.Unit, low, unit)
+	}
+	return true
+}
+
+func (p *parser) route() bool {
+	route := ""
+	switch {
+	case p.accept("by", "mouth"), p.accept("per", "os"):
+		route = RouteOral
+	case p.accept("under", "the", "tongue"):
+		route = RouteSublingual
+	case p.accept("per", "rectum"):
+		route = RouteRectal
+	case routes[p.tokens[p.pos]] != "":
+		route = routes[p.tokens[p.pos]]
+		p.pos++
+	default:
+		return false
+	}
+	if p.seg.Route != "" && p.seg.Route != route {
+		p.fail("conflicting routes: %s and %s", p.seg.Route, route)
+	}
+	p.seg.Route = route
+	return true
+}
+
+// parseSegment reads one part of a sig. Words it does not know, like
+// "take" or "with food", are skipped.
+func parseSegment(tokens []string) segment {
+	p := parser{tokens: tokens}
+	for p.pos < len(p.tokens) && p.seg.err == nil {
+		if !(p.frequency() || p.interval() || p.asNeeded() || p.clockTime() || p.duration() || p.amount() || p.route()) {
+			p.pos++
+		}
+	}
+	if p.seg.Route == "" {
+		p.seg.Route = unitRoutes[p.seg.Unit]
+	}
+	if p.seg.EveryDays > 1 && p.seg.TimesPerDay == 0 && len(p.seg.Times) == 0 {
+		p.seg.TimesPerDay = 1
+	}
+	return p.seg
+}
+
+// perDay is how many doses a segment gives a day, 0 if it does not say
+func (s *segment) perDay() int {
+	if s.TimesPerDay == 0 {
+		return len(s.Times)
+	}
+	return s.TimesPerDay
+}
+
+// Parse reads a sig such as "1 tab PO BID", "1-2 puffs every 4-6 hours as
+// needed" or "40 mg daily for 3 days, then 20 mg daily for 3 days". It
+// returns ErrNoFrequency if the sig does not say how often to take the
+// medication.
+func Parse(sig string) (*Schedule, error) {
+	var segments []segment
+	start := 0
+	tokens := tokenize(sig)
+	for i := 0; i <= len(tokens); i++ {
+		if i < len(tokens) && tokens[i] != "then" {
+			continue
+		}
+		seg := parseSegment(tokens[start:i])
+		if seg.err != nil {
+			return nil, seg.err
+		}
+		if !seg.empty() { // e.g. "then stop"
+			segments = append(segments, seg)
+		}
+		start = i + 1
+	}
+	if len(segments) == 0 {
+		return nil, ErrNoFrequency
+	}
+
+	schedule := segments[0].Schedule
+	if len(segments) > 1 {
+		var err error
+		if schedule, err = taper(segments); err != nil {
+			return nil, err
+		}
+	}
+	if !schedule.AsNeeded && schedule.TimesPerDay == 0 && len(schedule.Times) == 0 && schedule.IntervalHours == 0 {
+		return nil, ErrNoFrequency
+	}
+	if err := schedule.Normalize(); err != nil {
+		return nil, err
+	}
+	return &schedule, nil
+}
+
+// taper combines the parts of a sig separated by "then" into one schedule
+// with a step for each part. Later steps repeat the first's frequency
+// unless they give their own.
+func taper(segments []segment) (Schedule, error) {
+	first := segments[0]
+	schedule := Schedule{
+		Unit:          first.Unit,
+		Route:         first.Route,
+		TimesPerDay:   first.TimesPerDay,
+		Times:         first.Times,
+		IntervalHours: first.IntervalHours,
+		EveryDays:     first.EveryDays,
+	}
+
+	perDay := first.perDay()
+	for i, seg := range segments {
+		switch {
+		case seg.Amount == 0:
+			return Schedule{}, fmt.Errorf("step %d of the taper has no amount", i+1)
+		case seg.Unit != "" && seg.Unit != first.Unit:
+			return Schedule{}, fmt.Errorf("step %d of the taper is in %s, not %s", i+1, seg.Unit, first.Unit)
+		case seg.IntervalHours != 0 && seg.IntervalHours != first.IntervalHours,
+			seg.EveryDays != 0 && seg.EveryDays != first.EveryDays,
+			seg.AsNeeded:
+			return Schedule{}, fmt.Errorf("step %d of the taper changes how doses are spaced, which is not supported", i+1)
+		case seg.Days == 0 && i < len(segments)-1:
+			return Schedule{}, fmt.Errorf("step %d of the taper needs a length, such as \"for 3 days\"", i+1)
+		}
+		if seg.perDay() != 0 {
+			perDay = seg.perDay()
+		}
+
+		step := Step{Amount: seg.Amount, Days: seg.Days}
+		if perDay != first.perDay() {
+			step.TimesPerDay = perDay
+		}
+		schedule.Taper = append(schedule.Taper, step)
+	}
+	return schedule, nil
+}
+
+// ParseMedication reads a medication's schedule from its frequency,
+// taking the amount, unit and route from its dosage ("10mg") when the
+// frequency does not give them. Either may hold the whole sig.
+func ParseMedication(dosage, frequency string) (*Schedule, error) {
+	schedule, err := Parse(frequency)
+	if errors.Is(err, ErrNoFrequency) {
+		return Parse(dosage + " " + frequency)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	dose := parseSegment(tokenize(dosage))
+	if dose.err != nil {
+		return schedule, nil
+	}
+	if schedule.Amount == 0 && len(schedule.Taper) == 0 && dose.Amount > 0 {
+		schedule.Amount, schedule.MaxAmount, schedule.Unit = dose.Amount, dose.MaxAmount, dose.Unit
+	}
+	if schedule.Unit == "" && len(schedule.Taper) > 0 {
+		schedule.Unit = dose.Unit
+	}
+	if schedule.Route == "" {
+		schedule.Route = dose.Route
+		if schedule.Route == "" {
+			schedule.Route = unitRoutes[schedule.Unit]
+		}
+	}
+	return schedule, nil
+}
@@ -0,0 +1,95 @@
+package dosing
+
+import (
+	"errors"
+	"reflect"
+	"testing"
+)
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		sig  string
+		want Schedule
+	}{
+		{"1 tab PO BID", Schedule{Amount: 1, Unit: "tablet", Route: RouteOral, TimesPerDay: 2}},
+		{"take one tablet by mouth three times daily", Schedule{Amount: 1, Unit: "tablet", Route: RouteOral, TimesPerDay: 3}},
+		{"1/2 tab PO qd", Schedule{Amount: 0.5, Unit: "tablet", Route: RouteOral, TimesPerDay: 1}},
+		{"1 tab qid", Schedule{Amount: 1, Unit: "tablet", Route: RouteOral, TimesPerDay: 4}},
+		{"1 tab at 8am and 8pm", Schedule{Amount: 1, Unit: "tablet", Route: RouteOral, TimesPerDay: 2, Times: []string{"08:00", "20:00"}}},
+		{"10 units SC qhs", Schedule{Amount: 10, Unit: "unit", Route: RouteSubcutaneous, TimesPerDay: 1, Times: []string{"21:00"}}},
+		{"1 drop in each eye twice a day", Schedule{Amount: 1, Unit: "drop", Route: RouteOphthalmic, TimesPerDay: 2}},
+		{"every other day at 9pm", Schedule{TimesPerDay: 1, Times: []string{"21:00"}, EveryDays: 2}},
+		{"1 tab weekly", Schedule{Amount: 1, Unit: "tablet", Route: RouteOral, TimesPerDay: 1, EveryDays: 7}},
+		{"2 tabs daily x 5 days", Schedule{Amount: 2, Unit: "tablet", Route: RouteOral, TimesPerDay: 1, Days: 5}},
+		{"1 patch every 72 hours", Schedule{Amount: 1, Unit: "patch", Route: RouteTransdermal, IntervalHours: 72}},
+
+		// As needed
+		{"every 8 hours as needed", Schedule{IntervalHours: 8, AsNeeded: true}},
+		{"1-2 tabs q4-6h prn pain", Schedule{Amount: 1, MaxAmount: 2, Unit: "tablet", Route: RouteOral, IntervalHours: 4, AsNeeded: true}},
+		{"2 puffs q4h prn", Schedule{Amount: 2, Unit: "puff", Route: RouteInhaled, IntervalHours: 4, AsNeeded: true}},
+
+		// Tapers
+		{"40 mg daily for 3 days, then 20 mg daily for 3 days", Schedule{
+			Unit: "mg", TimesPerDay: 1,
+			Taper: []Step{{Amount: 40, Days: 3}, {Amount: 20, Days: 3}},
+		}},
+		{"20 mg BID for 2 days then 10 mg daily for 2 days then stop", Schedule{
+			Unit: "mg", TimesPerDay: 2,
+			Taper: []Step{{Amount: 20, Days: 2}, {Amount: 10, TimesPerDay: 1, Days: 2}},
+		}},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.sig)
+		if err != nil {
+			t.Errorf("Parse(%q) = %v", tt.sig, err)
+			continue
+		}
+		if !reflect.DeepEqual(*got, tt.want) {
+			t.Errorf("Parse(%q) = %+v, want %+v", tt.sig, *got, tt.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		sig       string
+		frequency bool // Whether the error is ErrNoFrequency
+	}{
+		{"", true},
+		{"1 tab", true},
+		{"take with food", true},
+		{"40 mg daily for 3 days, then 20 ml daily", false},
+		{"40 mg daily, then 20 mg daily for 3 days", false},
+	}
+	for _, tt := range tests {
+		got, err := Parse(tt.sig)
+		if err == nil {
+			t.Errorf("Parse(%q) = %+v, want an error", tt.sig, *got)
+			continue
+		}
+		if errors.Is(err, ErrNoFrequency) != tt.frequency {
+			t.Errorf("Parse(%q) = %v, ErrNoFrequency %v", tt.sig, err, tt.frequency)
+		}
+	}
+}
+
+func TestParseMedication(t *testing.T) {
+	tests := []struct {
+		dosage, frequency string
+		want              Schedule
+	}{
+		{"10 mg", "BID", Schedule{Amount: 10, Unit: "mg", TimesPerDay: 2}},
+		{"1 tab", "every 6 hours as needed", Schedule{Amount: 1, Unit: "tablet", Route: RouteOral, IntervalHours: 6, AsNeeded: true}},
+		{"", "1 tab PO BID", Schedule{Amount: 1, Unit: "tablet", Route: RouteOral, TimesPerDay: 2}},
+	}
+	for _, tt := range tests {
+		got, err := ParseMedication(tt.dosage, tt.frequency)
+		if err != nil {
+			t.Errorf("ParseMedication(%q, %q) = %v", tt.dosage, tt.frequency, err)
+			continue
+		}
+		if !reflect.DeepEqual(*got, tt.want) {
+			t.Errorf("ParseMedication(%q, %q) = %+v, want %+v", tt.dosage, tt.frequency, *got, tt.want)
+		}
+	}
+}
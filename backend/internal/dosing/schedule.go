@@ -0,0 +1,323 @@
+// Package dosing turns dosing instructions ("sigs") such as "1 tab PO BID"
+// into structured schedules and works out when their doses are due.
+package dosing
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Routes of administration
+const (
+	RouteOral          = "oral"
+	RouteSublingual    = "sublingual"
+	RouteTopical       = "topical"
+	RouteTransdermal   = "transdermal"
+	RouteInhaled       = "inhaled"
+	RouteNasal         = "nasal"
+	RouteOphthalmic    = "ophthalmic"
+	RouteOtic          = "otic"
+	RouteRectal        = "rectal"
+	RouteSubcutaneous  = "subcutaneous"
+	RouteIntramuscular = "intramuscular"
+	RouteIntravenous   = "intravenous"
+)
+
+// Schedule is how much of a medication to take and when. Doses are taken
+// times_per_day times on each dosing day, at times or at default times
+// spread through the day, or every interval_hours around the clock.
+type Schedule struct {
+	Amount        float64  `json:"amount,omitempty"`         // Per dose, in Unit; set on each step instead when tapering
+	MaxAmount     float64  `json:"max_amount,omitempty"`     // Upper end of a range such as "1-2 tabs"
+	Unit          string   `json:"unit,omitempty"`           // tablet, capsule, ml, puff, mg, ...
+	Route         string   `json:"route,omitempty"`          // oral, topical, inhaled, ...
+	TimesPerDay   int      `json:"times_per_day,omitempty"`  // Taken from times when omitted
+	Times         []string `json:"times,omitempty"`          // Local "HH:MM"; the first dose for interval schedules
+	IntervalHours int      `json:"interval_hours,omitempty"` // Instead of times_per_day, e.g. 8 for every 8 hours
+	EveryDays     int      `json:"every_days,omitempty"`     // 2 for every other day, 7 for weekly; defaults to daily
+	AsNeeded      bool     `json:"as_needed"`                // Taken when needed; the frequency is then a limit, not a schedule
+	Days          int      `json:"days,omitempty"`           // Length of the course; 0 continues until the medication ends
+	Taper         []Step   `json:"taper,omitempty"`          // Consecutive steps from the start date
+}
+
+// Step is one stage of a tapering schedule
+type Step struct {
+	Amount      float64 `json:"amount"`
+	TimesPerDay int     `json:"times_per_day,omitempty"` // Defaults to the schedule's
+	Days        int     `json:"days"`                    // 0 for the last step continues until the medication ends
+}
+
+// Dose is one scheduled dose
+type Dose struct {
+	At     time.Time `json:"at"`
+	Amount float64   `json:"amount,omitempty"`
+}
+
+// Times used for a number of doses a day when none are given
+var defaultTimes = map[int][]string{
+	1: {"08:00"},
+	2: {"08:00", "20:00"},
+	3: {"08:00", "14:00", "20:00"},
+	4: {"08:00", "12:00", "16:00", "20:00"},
+}
+
+// firstDose is when interval schedules start if no time is given
+const firstDose = 8 * 60
+
+// ParseClock reads an "HH:MM" time of day as minutes after midnight
+func ParseClock(value string) (int, error) {
+	t, err := time.Parse("15:04", value)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time %q, expected HH:MM", value)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+func formatClock(minutes int) string {
+	return fmt.Sprintf("%02d:%02d", minutes/60, minutes%60)
+}
+
+// Normalize checks a schedule and puts it in canonical form: units and
+// routes lowercased, times sorted and times_per_day filled in from them
+func (s *Schedule) Normalize() error {
+	s.Unit = strings.ToLower(strings.TrimSpace(s.Unit))
+	s.Route = strings.ToLower(strings.TrimSpace(s.Route))
+
+	if s.Amount < 0 || s.MaxAmount < 0 {
+		return errors.New("amount cannot be negative")
+	}
+	if s.MaxAmount != 0 && s.MaxAmount < s.Amount {
+		return errors.New("max_amount must be at least amount")
+	}
+	if s.TimesPerDay < 0 || s.TimesPerDay > 24 {
+		return errors.New("times_per_day must be between 1 and 24")
+	}
+	if s.IntervalHours < 0 || s.IntervalHours > 168 {
+		return errors.New("interval_hours must be between 1 and 168")
+	}
+	if s.EveryDays < 0 || s.EveryDays > 365 {
+		return errors.New("every_days must be between 1 and 365")
+	}
+	if s.Days < 0 {
+		return errors.New("days cannot be negative")
+	}
+	if s.IntervalHours > 0 && (s.TimesPerDay > 0 || s.EveryDays > 1) {
+		return errors.New("interval_hours cannot be combined with times_per_day or every_days")
+	}
+
+	seen := map[int]bool{}
+	var times []int
+	for _, value := range s.Times {
+		minutes, err := ParseClock(strings.TrimSpace(value))
+		if err != nil {
+			return err
+		}
+		if !seen[minutes] {
+			seen[minutes] = true
+			times = append(times, minutes)
+		}
+	}
+	sort.Ints(times)
+	s.Times = nil
+	for _, minutes := range times {
+		s.Times = append(s.Times, formatClock(minutes))
+	}
+	if s.IntervalHours > 0 && len(s.Times) > 1 {
+		return errors.New("interval schedules take only the time of the first dose")
+	}
+	if s.IntervalHours == 0 && len(s.Times) > 0 {
+		if s.TimesPerDay == 0 {
+			s.TimesPerDay = len(s.Times)
+		} else if s.TimesPerDay != len(s.Times) {
+			return fmt.Errorf("times_per_day is %d but times lists %d", s.TimesPerDay, len(s.Times))
+		}
+	}
+	if !s.AsNeeded && s.TimesPerDay == 0 && s.IntervalHours == 0 {
+		return errors.New("times_per_day, times or interval_hours is required unless the medication is taken as needed")
+	}
+
+	if len(s.Taper) > 0 {
+		if s.Amount != 0 || s.MaxAmount != 0 || s.Days != 0 {
+			return errors.New("amount and days are set on each step of a taper")
+		}
+		if s.AsNeeded {
+			return errors.New("as needed medications cannot be tapered")
+		}
+	}
+	for i, step := range s.Taper {
+		if step.Amount <= 0 {
+			return fmt.Errorf("taper step %d needs an amount", i+1)
+		}
+		if step.TimesPerDay < 0 || step.TimesPerDay > 24 {
+			return fmt.Errorf("taper step %d: times_per_day must be between 1 and 24", i+1)
+		}
+		if step.TimesPerDay > 0 && s.IntervalHours > 0 {
+			return fmt.Errorf("taper step %d: times_per_day cannot be combined with interval_hours", i+1)
+		}
+		if step.Days < 0 || step.Days == 0 && i < len(s.Taper)-1 {
+			return fmt.Errorf("taper step %d needs a number of days", i+1)
+		}
+	}
+	return nil
+}
+
+// courseDays is how many days the schedule lasts, or 0 if it continues
+// until the medication ends
+func (s *Schedule) courseDays() int {
+	if len(s.Taper) == 0 {
+		return s.Days
+	}
+	total := 0
+	for _, step := range s.Taper {
+		if step.Days == 0 {
+			return 0
+		}
+		total += step.Days
+	}
+	return total
+}
+
+// on returns the amount and the local dose times, in minutes after
+// midnight, for the day of the course with the given index
+func (s *Schedule) on(day int) (float64, []int, bool) {
+	if day < 0 || s.EveryDays > 1 && day%s.EveryDays != 0 {
+		return 0, nil, false
+	}
+	if total := s.courseDays(); total > 0 && day >= total {
+		return 0, nil, false
+	}
+
+	amount, perDay := s.Amount, s.TimesPerDay
+	for i, first := 0, 0; i < len(s.Taper); i++ {
+		step := s.Taper[i]
+		if step.Days == 0 || day < first+step.Days {
+			amount = step.Amount
+			if step.TimesPerDay > 0 {
+				perDay = step.TimesPerDay
+			}
+			break
+		}
+		first += step.Days
+	}
+	return amount, s.clock(perDay), true
+}
+
+// clock returns the times of perDay doses, using the schedule's times when
+// there are that many and spreading them through the day otherwise
+func (s *Schedule) clock(perDay int) []int {
+	values := s.Times
+	if len(values) != perDay {
+		values = defaultTimes[perDay]
+	}
+	if values == nil {
+		times := make([]int, perDay)
+		for i := range times {
+			times[i] = (firstDose + i*24*60/perDay) % (24 * 60)
+		}
+		sort.Ints(times)
+		return times
+	}
+	times := make([]int, 0, len(values))
+	for _, value := range values {
+		if minutes, err := ParseClock(value); err == nil {
+			times = append(times, minutes)
+		}
+	}
+	return times
+}
+
+// date returns the calendar date of t in its location, as midnight UTC, so
+// that days between dates can be counted across daylight saving changes
+func date(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func daysBetween(from, to time.Time) int {
+	return int(to.Sub(from).Hours() / 24)
+}
+
+// localTime returns the time minutes after midnight on day's date in loc.
+// A time skipped when the clocks go forward is read on the clock from
+// before the change, so it moves later by the length of the gap: 02:30 on
+// the day New York springs forward is 03:30 EDT.
+func localTime(day time.Time, minutes int, loc *time.Location) time.Time {
+	at := time.Date(day.Year(), day.Month(), day.Day(), minutes/60, minutes%60, 0, 0, loc)
+	if at.Hour()*60+at.Minute() == minutes {
+		return at
+	}
+	_, before := at.Add(-24 * time.Hour).Zone()
+	return time.Date(day.Year(), day.Month(), day.Day(), minutes/60, minutes%60, 0, 0, time.FixedZone("", before)).In(loc)
+}
+
+// Doses returns the doses due from from until until, in loc, for a course
+// that started at start and ends at end (nil if it has no end). Interval
+// schedules count from their first dose time on the start date; daylight
+// saving changes move them on the clock. Daily dose times skipped when the
+// clocks go forward are taken that much later. Medications taken as needed
+// have no due doses.
+func (s *Schedule) Doses(start time.Time, end *time.Time, from, until time.Time, loc *time.Location) []Dose {
+	if s.AsNeeded {
+		return nil
+	}
+	if from.Before(start) {
+		from = start
+	}
+	if end != nil && end.Before(until) {
+		until = *end
+	}
+	startDate := date(start.In(loc))
+	if total := s.courseDays(); total > 0 {
+		courseEnd := localTime(startDate.AddDate(0, 0, total), 0, loc)
+		if courseEnd.Before(until) {
+			until = courseEnd
+		}
+	}
+	if !from.Before(until) {
+		return nil
+	}
+
+	var doses []Dose
+	if s.IntervalHours > 0 {
+		minutes := firstDose
+		if len(s.Times) > 0 {
+			minutes, _ = ParseClock(s.Times[0])
+		}
+		step := time.Duration(s.IntervalHours) * time.Hour
+		at := localTime(startDate, minutes, loc)
+		if at.Before(start) && step >= 24*time.Hour {
+			// Start a patch changed every 72 hours the next morning, not three days later
+			at = at.AddDate(0, 0, 1)
+		}
+		if at.Before(from) {
+			at = at.Add(from.Sub(at) / step * step)
+			if at.Before(from) {
+				at = at.Add(step)
+			}
+		}
+		for ; at.Before(until); at = at.Add(step) {
+			if amount, _, ok := s.on(daysBetween(startDate, date(at.In(loc)))); ok {
+				doses = append(doses, Dose{At: at.In(loc), Amount: amount})
+			}
+		}
+		return doses
+	}
+
+	for day := date(from.In(loc)); ; day = day.AddDate(0, 0, 1) {
+		if !localTime(day, 0, loc).Before(until) {
+			return doses
+		}
+		amount, times, ok := s.on(daysBetween(startDate, day))
+		if !ok {
+			continue
+		}
+		for _, minutes := range times {
+			at := localTime(day, minutes, loc)
+			if !at.Before(from) && at.Before(until) {
+				doses = append(doses, Dose{At: at, Amount: amount})
+			}
+		}
+	}
+}
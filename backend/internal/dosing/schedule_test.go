@@ -0,0 +1,116 @@
+package dosing
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func newYork(t *testing.T) *time.Location {
+	t.Helper()
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return loc
+}
+
+// doseTimes formats doses as local times for comparison
+func doseTimes(doses []Dose) []string {
+	times := make([]string, len(doses))
+	for i, dose := range doses {
+		times[i] = dose.At.Format("2006-01-02 15:04 MST")
+	}
+	return times
+}
+
+func checkDoses(t *testing.T, name string, doses []Dose, want []string) {
+	t.Helper()
+	got := doseTimes(doses)
+	if len(got) != len(want) {
+		t.Errorf("%s: doses = %v, want %v", name, got, want)
+		return
+	}
+	for i := range got {
+		if got[i] != want[i] {
+			t.Errorf("%s: doses = %v, want %v", name, got, want)
+			return
+		}
+	}
+}
+
+func TestDosesAcrossSpringForward(t *testing.T) {
+	loc := newYork(t)
+	start := time.Date(2026, 3, 7, 0, 0, 0, 0, loc)
+	until := time.Date(2026, 3, 10, 0, 0, 0, 0, loc)
+
+	// 02:30 does not exist on March 8, so that dose is taken at 03:30 EDT
+	daily := Schedule{Amount: 1, TimesPerDay: 1, Times: []string{"02:30"}}
+	checkDoses(t, "daily at 02:30", daily.Doses(start, nil, start, until, loc), []string{
+		"2026-03-07 02:30 EST",
+		"2026-03-08 03:30 EDT",
+		"2026-03-09 02:30 EDT",
+	})
+
+	bid := Schedule{Amount: 1, TimesPerDay: 2}
+	checkDoses(t, "twice a day", bid.Doses(start, nil, start, until, loc), []string{
+		"2026-03-07 08:00 EST",
+		"2026-03-07 20:00 EST",
+		"2026-03-08 08:00 EDT",
+		"2026-03-08 20:00 EDT",
+		"2026-03-09 08:00 EDT",
+		"2026-03-09 20:00 EDT",
+	})
+
+	// Interval doses stay 8 hours apart and move on the clock
+	q8h := Schedule{Amount: 1, IntervalHours: 8}
+	checkDoses(t, "every 8 hours", q8h.Doses(start, nil, start, time.Date(2026, 3, 8, 12, 0, 0, 0, loc), loc), []string{
+		"2026-03-07 08:00 EST",
+		"2026-03-07 16:00 EST",
+		"2026-03-08 00:00 EST",
+		"2026-03-08 09:00 EDT",
+	})
+}
+
+func TestDosesAcrossFallBack(t *testing.T) {
+	loc := newYork(t)
+	start := time.Date(2026, 10, 31, 0, 0, 0, 0, loc)
+	until := time.Date(2026, 11, 3, 0, 0, 0, 0, loc)
+
+	// 01:30 happens twice on November 1; the dose is taken once
+	daily := Schedule{Amount: 1, TimesPerDay: 1, Times: []string{"01:30"}}
+	doses := daily.Doses(start, nil, start, until, loc)
+	if len(doses) != 3 {
+		t.Fatalf("daily at 01:30: doses = %v, want one a day", doseTimes(doses))
+	}
+	for _, dose := range doses {
+		if dose.At.Hour() != 1 || dose.At.Minute() != 30 {
+			t.Errorf("daily at 01:30: dose at %v", dose.At)
+		}
+	}
+
+	q8h := Schedule{Amount: 1, IntervalHours: 8}
+	checkDoses(t, "every 8 hours", q8h.Doses(start, nil, time.Date(2026, 11, 1, 0, 0, 0, 0, loc), time.Date(2026, 11, 1, 18, 0, 0, 0, loc), loc), []string{
+		"2026-11-01 00:00 EDT",
+		"2026-11-01 07:00 EST",
+		"2026-11-01 15:00 EST",
+	})
+}
+
+func TestDosesTaperCountsCalendarDays(t *testing.T) {
+	loc := newYork(t)
+	start := time.Date(2026, 3, 6, 9, 0, 0, 0, loc)
+	taper := Schedule{TimesPerDay: 1, Taper: []Step{{Amount: 40, Days: 2}, {Amount: 20, Days: 2}}}
+
+	doses := taper.Doses(start, nil, start, time.Date(2026, 3, 20, 0, 0, 0, 0, loc), loc)
+	checkDoses(t, "taper", doses, []string{
+		"2026-03-07 08:00 EST",
+		"2026-03-08 08:00 EDT",
+		"2026-03-09 08:00 EDT",
+	})
+	for i, want := range []float64{40, 20, 20} {
+		if i < len(doses) && doses[i].Amount != want {
+			t.Errorf("taper: dose %d amount = %v, want %v", i, doses[i].Amount, want)
+		}
+	}
+}
@@ -0,0 +1,175 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/yourusername/health-connect/internal/auth"
+	"github.com/yourusername/health-connect/internal/mail"
+	"github.com/yourusername/health-connect/internal/models"
+)
+
+type AdminHandler struct {
+	DB     *gorm.DB
+	Mailer mail.Sender
+}
+
+type UnlockLoginRequest struct {
+	Email string `json:"email" binding:"omitempty,email"`
+	IP    string `json:"ip" binding:"omitempty,ip"`
+}
+
+type RejectLicenseRequest struct {
+	Reason string `json:"reason" binding:"required"`
+}
+
+func NewAdminHandler(db *gorm.DB, mailer mail.Sender) *AdminHandler {
+	return &AdminHandler{DB: db, Mailer: mailer}
+}
+
+// GetPendingPhysicians lists physicians waiting for license review, oldest first
+func (h *AdminHandler) GetPendingPhysicians(c *gin.Context) {
+	var physicians []models.Physician
+	result := h.DB.Where("license_status = ?", models.LicenseStatusPending).
+		Preload("Specialties").
+		Order("created_at ASC").
+		Find(&physicians)
+
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch pending physicians",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"physicians": physicians,
+	})
+}
+
+// ApprovePhysician approves a physician's license
+func (h *AdminHandler) ApprovePhysician(c *gin.Context) {
+	h.reviewLicense(c, models.LicenseStatusApproved, "")
+}
+
+// RejectPhysician rejects a physician's license with a reason
+func (h *AdminHandler) RejectPhysician(c *gin.Context) {
+	var req RejectLicenseRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+
+	h.reviewLicense(c, models.LicenseStatusRejected, req.Reason)
+}
+
+// reviewLicense records an admin's license decision and notifies the physician
+func (h *AdminHandler) reviewLicense(c *gin.Context, status, reason string) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	var physician models.Physician
+	if err := h.DB.Where("id = ?", c.Param("id")).First(&physician).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Physician not found",
+		})
+		return
+	}
+
+	now := time.Now()
+	err := h.DB.Model(&physician).Updates(map[string]interface{}{
+		"license_status":           status,
+		"license_reviewed_by":      principal.ID,
+		"license_reviewed_at":      now,
+		"license_rejection_reason": reason,
+	}).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update license status",
+		})
+		return
+	}
+
+	body := "Your medical license has been approved. You can now access your patients' records in Health Connect."
+	if status == models.LicenseStatusRejected {
+		body = fmt.Sprintf("Your medical license could not be approved.\n\nReason: %s\n\n"+
+			"Please contact support if you believe this is a mistake.", reason)
+	}
+	if err := h.Mailer.Send(mail.Message{
+		To:      physician.Email,
+		Subject: "Your Health Connect license review",
+		Body:    body,
+	}); err != nil {
+		log.Printf("Failed to send license review email: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"physician": physician,
+	})
+}
+
+// GetLockouts lists accounts and IPs that are currently locked out
+func (h *AdminHandler) GetLockouts(c *gin.Context) {
+	var lockouts []models.LoginThrottle
+	result := h.DB.Where("locked_until > ?", time.Now()).
+		Order("locked_until DESC").
+		Find(&lockouts)
+
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch lockouts",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"lockouts": lockouts,
+	})
+}
+
+// UnlockLogin clears failed-login counters for an account (by email) or an IP
+func (h *AdminHandler) UnlockLogin(c *gin.Context) {
+	var req UnlockLoginRequest
+	if err := c.ShouldBindJSON(&req); err != nil || (req.IP == "" && req.Email == "") {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Provide either email or ip",
+		})
+		return
+	}
+
+	key := auth.IPThrottleKey(req.IP)
+	if req.IP == "" {
+		key = auth.AccountThrottleKey(req.Email)
+	}
+
+	unlocked, err := auth.UnlockLogin(h.DB, key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to unlock",
+		})
+		return
+	}
+	if !unlocked {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "No failed attempts recorded",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Unlocked",
+	})
+}
@@ -0,0 +1,335 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/yourusername/health-connect/internal/auth"
+	"github.com/yourusername/health-connect/internal/interactions"
+	"github.com/yourusername/health-connect/internal/models"
+)
+
+type AllergyHandler struct {
+	DB           *gorm.DB
+	Interactions *interactions.Checker
+}
+
+func NewAllergyHandler(db *gorm.DB, checker *interactions.Checker) *AllergyHandler {
+	return &AllergyHandler{DB: db, Interactions: checker}
+}
+
+type AllergyRequest struct {
+	Substance string     `json:"substance" binding:"required,max=200"`
+	Reaction  string     `json:"reaction" binding:"max=500"`
+	Severity  string     `json:"severity" binding:"omitempty,oneof=mild moderate severe unknown"` // Defaults to unknown
+	Onset     *time.Time `json:"onset"`
+}
+
+// AllergyConflict is a medication that matches one of the patient's allergies
+type AllergyConflict struct {
+	interactions.AllergyMatch
+	Allergy        models.Allergy `json:"allergy"`
+	MedicationID   string         `json:"medication_id,omitempty"` // Empty for a medication not yet prescribed
+	MedicationName string         `json:"medication_name"`
+}
+
+// allergyConflicts returns each of the patient's allergies that a medication matches
+func allergyConflicts(checker *interactions.Checker, allergies []models.Allergy, drug interactions.Drug) []AllergyConflict {
+	var conflicts []AllergyConflict
+	for _, allergy := range allergies {
+		if match, ok := checker.MatchAllergy(drug.Name, allergy.Substance); ok {
+			conflicts = append(conflicts, AllergyConflict{
+				AllergyMatch:   match,
+				Allergy:        allergy,
+				MedicationID:   drug.ID,
+				MedicationName: drug.Name,
+			})
+		}
+	}
+	return conflicts
+}
+
+// checkAllergies refuses a medication being prescribed or renamed with 409
+// if it matches one of the patient's allergies, unless the physician
+// overrides it with a reason
+func (h *MedicationHandler) checkAllergies(c *gin.Context, medication *models.Medication, req *MedicationRequest) ([]AllergyConflict, bool) {
+	var allergies []models.Allergy
+	if err := h.DB.Where("patient_id = ?", medication.PatientID).Find(&allergies).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to check allergies",
+		})
+		return nil, false
+	}
+	conflicts := allergyConflicts(h.Interactions, allergies, interactions.Drug{ID: medication.ID, Name: medication.Name})
+	if len(conflicts) == 0 {
+		return []AllergyConflict{}, true
+	}
+
+	if !req.OverrideAllergies {
+		c.JSON(http.StatusConflict, gin.H{
+			"success":   false,
+			"message":   "This medication matches the patient's recorded allergies. Review them and set override_allergies with an override_reason to continue.",
+			"allergies": conflicts,
+		})
+		return nil, false
+	}
+	if strings.TrimSpace(req.OverrideReason) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "override_reason is required to override allergies",
+		})
+		return nil, false
+	}
+	return conflicts, true
+}
+
+// overrideAllergies records the physician's override of each allergy a
+// medication matched, as part of saving it
+func overrideAllergies(medication *models.Medication, conflicts []AllergyConflict, principal *auth.Principal, req *MedicationRequest) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		for i := range conflicts {
+			override := models.AllergyOverride{
+				PatientID:      medication.PatientID,
+				AllergyID:      conflicts[i].Allergy.ID,
+				MedicationID:   medication.ID,
+				Ingredient:     conflicts[i].Ingredient,
+				Class:          conflicts[i].Class,
+				Reason:         strings.TrimSpace(req.OverrideReason),
+				OverriddenByID: principal.ID,
+			}
+			if err := tx.Create(&override).Error; err != nil {
+				return err
+			}
+			conflicts[i].MedicationID = medication.ID
+		}
+		return nil
+	}
+}
+
+// medicationConflicts returns the patient's active medications that match an allergy
+func (h *AllergyHandler) medicationConflicts(allergy *models.Allergy) ([]AllergyConflict, error) {
+	drugs, err := activeDrugs(h.DB, allergy.PatientID)
+	if err != nil {
+		return nil, err
+	}
+	conflicts := []AllergyConflict{}
+	for _, drug := range drugs {
+		conflicts = append(conflicts, allergyConflicts(h.Interactions, []models.Allergy{*allergy}, drug)...)
+	}
+	return conflicts, nil
+}
+
+// bindAllergy reads an allergy request, responding with 400 if it is invalid
+func bindAllergy(c *gin.Context) (*AllergyRequest, bool) {
+	var req AllergyRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request format: " + err.Error(),
+		})
+		return nil, false
+	}
+	if strings.TrimSpace(req.Substance) == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "substance is required",
+		})
+		return nil, false
+	}
+	if req.Onset != nil && req.Onset.After(time.Now()) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "onset cannot be in the future",
+		})
+		return nil, false
+	}
+	return &req, true
+}
+
+// applyTo copies a request onto an allergy. Allergies recorded by a
+// physician are verified by them; those recorded by the patient need verifying.
+func (req *AllergyRequest) applyTo(allergy *models.Allergy, principal *auth.Principal) {
+	allergy.Substance = strings.TrimSpace(req.Substance)
+	allergy.Reaction = strings.TrimSpace(req.Reaction)
+	allergy.Severity = req.Severity
+	if allergy.Severity == "" {
+		allergy.Severity = models.AllergySeverityUnknown
+	}
+	allergy.Onset = req.Onset
+
+	allergy.VerifiedByID = nil
+	allergy.VerifiedBy = nil
+	allergy.VerifiedAt = nil
+	if principal.IsPhysician() {
+		now := time.Now()
+		allergy.VerifiedByID = &principal.ID
+		allergy.VerifiedAt = &now
+	}
+}
+
+// findAllergy loads one of the patient's allergies, responding with 404 if missing
+func (h *AllergyHandler) findAllergy(c *gin.Context) (*models.Allergy, bool) {
+	var allergy models.Allergy
+	err := h.DB.Where("id = ? AND patient_id = ?", c.Param("allergyId"), c.Param("id")).First(&allergy).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Allergy not found",
+		})
+		return nil, false
+	}
+	return &allergy, true
+}
+
+// checkCanChange stops a patient from changing or deleting an allergy a
+// physician has verified, responding with 403, so one their physicians rely
+// on cannot quietly disappear. Physicians can change any allergy.
+func checkCanChange(c *gin.Context, principal *auth.Principal, allergy *models.Allergy) bool {
+	if principal.IsPatient() && allergy.VerifiedByID != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": "This allergy has been verified by a physician; ask them to change it",
+		})
+		return false
+	}
+	return true
+}
+
+// respondWithConflicts returns an allergy with the patient's active
+// medications that match it
+func (h *AllergyHandler) respondWithConflicts(c *gin.Context, status int, allergy *models.Allergy) {
+	conflicts, err := h.medicationConflicts(allergy)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to check medications",
+		})
+		return
+	}
+
+	c.JSON(status, gin.H{
+		"success":   true,
+		"allergy":   allergy,
+		"conflicts": conflicts,
+	})
+}
+
+// GetAllergies lists a patient's allergies
+func (h *AllergyHandler) GetAllergies(c *gin.Context) {
+	var allergies []models.Allergy
+	result := h.DB.Where("patient_id = ?", c.Param("id")).
+		Preload("VerifiedBy").
+		Order("created_at ASC").
+		Find(&allergies)
+
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch allergies",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"allergies": allergies,
+	})
+}
+
+// CreateAllergy records an allergy, returning any active medications it matches
+func (h *AllergyHandler) CreateAllergy(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	req, ok := bindAllergy(c)
+	if !ok {
+		return
+	}
+
+	allergy := models.Allergy{
+		PatientID:      c.Param("id"),
+		RecordedByID:   principal.ID,
+		RecordedByType: principal.Role,
+	}
+	req.applyTo(&allergy, principal)
+	if err := h.DB.Create(&allergy).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to record allergy",
+		})
+		return
+	}
+
+	h.respondWithConflicts(c, http.StatusCreated, &allergy)
+}
+
+// UpdateAllergy changes an allergy, returning any active medications it matches
+func (h *AllergyHandler) UpdateAllergy(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	req, ok := bindAllergy(c)
+	if !ok {
+		return
+	}
+	allergy, ok := h.findAllergy(c)
+	if !ok || !checkCanChange(c, principal, allergy) {
+		return
+	}
+
+	req.applyTo(allergy, principal)
+	if err := h.DB.Save(allergy).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update allergy",
+		})
+		return
+	}
+
+	h.respondWithConflicts(c, http.StatusOK, allergy)
+}
+
+// VerifyAllergy records that the calling physician has confirmed an allergy
+func (h *AllergyHandler) VerifyAllergy(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	allergy, ok := h.findAllergy(c)
+	if !ok {
+		return
+	}
+
+	now := time.Now()
+	allergy.VerifiedByID = &principal.ID
+	allergy.VerifiedAt = &now
+	if err := h.DB.Model(allergy).Updates(map[string]interface{}{"verified_by_id": principal.ID, "verified_at": now}).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to verify allergy",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"allergy": allergy,
+	})
+}
+
+// DeleteAllergy removes an allergy from the patient's list. It is kept in
+// the database for earlier overrides that refer to it.
+func (h *AllergyHandler) DeleteAllergy(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	allergy, ok := h.findAllergy(c)
+	if !ok || !checkCanChange(c, principal, allergy) {
+		return
+	}
+
+	if err := h.DB.Delete(allergy).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete allergy",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Allergy deleted",
+	})
+}
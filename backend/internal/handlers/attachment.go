@@ -0,0 +1,280 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"io"
+	"log"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/yourusername/health-connect/internal/auth"
+	"github.com/yourusername/health-connect/internal/models"
+	"github.com/yourusername/health-connect/internal/realtime"
+	"github.com/yourusername/health-connect/internal/storage"
+)
+
+const (
+	// DefaultAttachmentMaxBytes is the largest file accepted unless configured
+	DefaultAttachmentMaxBytes = 10 << 20
+
+	// maxAttachmentsPerMessage limits how many files one message can carry
+	maxAttachmentsPerMessage = 5
+)
+
+// allowedAttachmentTypes are the content types accepted for upload, detected
+// from the file content rather than trusted from the client
+var allowedAttachmentTypes = map[string]bool{
+	"image/jpeg":      true,
+	"image/png":       true,
+	"image/gif":       true,
+	"image/webp":      true,
+	"application/pdf": true,
+}
+
+type AttachmentHandler struct {
+	DB       *gorm.DB
+	Store    storage.Store
+	Hub      *realtime.Hub
+	MaxBytes int64
+}
+
+func NewAttachmentHandler(db *gorm.DB, store storage.Store, hub *realtime.Hub, maxBytes int64) *AttachmentHandler {
+	return &AttachmentHandler{DB: db, Store: store, Hub: hub, MaxBytes: maxBytes}
+}
+
+// errAttachmentType is returned for files whose content is not an allowed type
+var errAttachmentType = errors.New("unsupported attachment type")
+
+// attachmentFilename keeps the base name of an uploaded file, without
+// characters that could break the Content-Disposition header
+func attachmentFilename(name string) string {
+	name = filepath.Base(strings.ReplaceAll(name, "\\", "/"))
+	name = strings.Map(func(r rune) rune {
+		if r < 0x20 || r == 0x7f || r == '"' {
+			return -1
+		}
+		return r
+	}, name)
+	if name == "" || name == "." || name == "/" {
+		return "attachment"
+	}
+	if len(name) > 255 {
+		name = name[:255]
+	}
+	return name
+}
+
+// formatSize describes a byte count for error messages
+func formatSize(size int64) string {
+	if size >= 1<<20 {
+		return strconv.FormatInt(size>>20, 10) + " MB"
+	}
+	return strconv.FormatInt(size>>10, 10) + " KB"
+}
+
+// storeAttachment validates one uploaded file and streams it to storage,
+// computing its checksum on the way
+func (h *AttachmentHandler) storeAttachment(file *multipart.FileHeader, attachment *models.Attachment) error {
+	src, err := file.Open()
+	if err != nil {
+		return err
+	}
+	defer src.Close()
+
+	// Detect the type from the first bytes of the content
+	head := make([]byte, 512)
+	n, err := io.ReadFull(src, head)
+	if err != nil && !errors.Is(err, io.ErrUnexpectedEOF) && !errors.Is(err, io.EOF) {
+		return err
+	}
+	head = head[:n]
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(head))
+	if !allowedAttachmentTypes[contentType] {
+		return errAttachmentType
+	}
+
+	hash := sha256.New()
+	body := io.TeeReader(io.MultiReader(bytes.NewReader(head), src), hash)
+	if err := h.Store.Put(attachment.StorageKey, body, file.Size, contentType); err != nil {
+		return err
+	}
+
+	attachment.ContentType = contentType
+	attachment.Size = file.Size
+	attachment.Checksum = hex.EncodeToString(hash.Sum(nil))
+	return nil
+}
+
+// UploadAttachments adds files to a message the caller sent. Files are
+// sent as multipart form fields named "file".
+func (h *AttachmentHandler) UploadAttachments(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	var message models.Message
+	if err := h.DB.Where("id = ?", c.Param("id")).First(&message).Error; err != nil || !canAccessThread(h.DB, principal, &message) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Message not found",
+		})
+		return
+	}
+	if !sentByCaller(principal, &message) {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": "You can only attach files to messages you sent",
+		})
+		return
+	}
+
+	// Cap the whole request so oversized uploads are refused while reading
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.MaxBytes*maxAttachmentsPerMessage+1<<20)
+	form, err := c.MultipartForm()
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"success": false,
+				"message": "Upload is too large",
+			})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request format: expected multipart/form-data",
+		})
+		return
+	}
+	defer form.RemoveAll()
+
+	files := form.File["file"]
+	if len(files) == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "At least one file is required",
+		})
+		return
+	}
+
+	var existing int64
+	if err := h.DB.Model(&models.Attachment{}).Where("message_id = ?", message.ID).Count(&existing).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to upload attachments",
+		})
+		return
+	}
+	if existing+int64(len(files)) > maxAttachmentsPerMessage {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "A message can have at most " + strconv.Itoa(maxAttachmentsPerMessage) + " attachments",
+		})
+		return
+	}
+
+	for _, file := range files {
+		if file.Size > h.MaxBytes {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{
+				"success": false,
+				"message": attachmentFilename(file.Filename) + " is larger than the " + formatSize(h.MaxBytes) + " limit",
+			})
+			return
+		}
+	}
+
+	attachments := make([]models.Attachment, 0, len(files))
+	cleanup := func() {
+		for _, attachment := range attachments {
+			if err := h.Store.Delete(attachment.StorageKey); err != nil {
+				log.Printf("Failed to delete attachment %s from storage: %v", attachment.StorageKey, err)
+			}
+		}
+	}
+
+	for _, file := range files {
+		id := uuid.New().String()
+		attachment := models.Attachment{
+			ID:             id,
+			MessageID:      message.ID,
+			Filename:       attachmentFilename(file.Filename),
+			StorageKey:     "messages/" + message.ID + "/" + id,
+			UploadedByID:   principal.ID,
+			UploadedByType: principal.Role,
+		}
+
+		if err := h.storeAttachment(file, &attachment); err != nil {
+			cleanup()
+			if errors.Is(err, errAttachmentType) {
+				c.JSON(http.StatusUnsupportedMediaType, gin.H{
+					"success": false,
+					"message": attachment.Filename + " is not a supported file type (JPEG, PNG, GIF, WebP or PDF)",
+				})
+				return
+			}
+			log.Printf("Failed to store attachment for message %s: %v", message.ID, err)
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to upload attachments",
+			})
+			return
+		}
+		attachments = append(attachments, attachment)
+	}
+
+	if err := h.DB.Create(&attachments).Error; err != nil {
+		cleanup()
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to upload attachments",
+		})
+		return
+	}
+
+	event := realtime.AttachmentsAdded{MessageID: message.ID, ThreadID: message.ThreadID, Attachments: attachments}
+	publishToThread(h.DB, h.Hub, &message, nil, false, realtime.EventAttachmentsAdded, event)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success":     true,
+		"attachments": attachments,
+	})
+}
+
+// DownloadAttachment streams an attachment to a participant in its conversation
+func (h *AttachmentHandler) DownloadAttachment(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	var attachment models.Attachment
+	var message models.Message
+	if err := h.DB.Where("id = ?", c.Param("id")).First(&attachment).Error; err != nil ||
+		h.DB.Where("id = ?", attachment.MessageID).First(&message).Error != nil ||
+		!canAccessThread(h.DB, principal, &message) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Attachment not found",
+		})
+		return
+	}
+
+	content, err := h.Store.Get(attachment.StorageKey)
+	if err != nil {
+		log.Printf("Failed to read attachment %s from storage: %v", attachment.ID, err)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to download attachment",
+		})
+		return
+	}
+	defer content.Close()
+
+	checksum, _ := hex.DecodeString(attachment.Checksum)
+	c.Header("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}))
+	c.Header("Cache-Control", "private, no-store")
+	c.Header("X-Content-Type-Options", "nosniff")
+	c.Header("Digest", "sha-256="+base64.StdEncoding.EncodeToString(checksum))
+	c.DataFromReader(http.StatusOK, attachment.Size, attachment.ContentType, content, nil)
+}
@@ -1,60 +1,72 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"log"
+	"math"
 	"net/http"
-	"os"
-	"time"
+	"strconv"
+	"strings"
 
 	"github.com/gin-gonic/gin"
-	"github.com/golang-jwt/jwt/v5"
 	"golang.org/x/crypto/bcrypt"
 	"gorm.io/gorm"
 
+	"github.com/yourusername/health-connect/internal/auth"
+	"github.com/yourusername/health-connect/internal/mail"
 	"github.com/yourusername/health-connect/internal/models"
+	"github.com/yourusername/health-connect/internal/validation"
 )
 
 type AuthHandler struct {
-	DB *gorm.DB
+	DB     *gorm.DB
+	Mailer mail.Sender
 }
 
 type LoginRequest struct {
 	Email    string `json:"email" binding:"required,email"`
 	Password string `json:"password" binding:"required"`
+	Role     string `json:"role" binding:"omitempty,oneof=patient physician admin"` // Optional; defaults to the first role the account holds
 }
 
 type LoginResponse struct {
-	Success bool   `json:"success"`
-	Token   string `json:"token,omitempty"`
-	Message string `json:"message,omitempty"`
+	Success      bool     `json:"success"`
+	ID           string   `json:"id,omitempty"`
+	Role         string   `json:"role,omitempty"`
+	Roles        []string `json:"roles,omitempty"` // Every role the account can switch to
+	Token        string   `json:"token,omitempty"`
+	RefreshToken string   `json:"refresh_token,omitempty"`
+	ExpiresIn    int64    `json:"expires_in,omitempty"`
+	Message      string   `json:"message,omitempty"`
 }
 
-type Claims struct {
-	Email string `json:"email"`
-	Role  string `json:"role"`
-	jwt.RegisteredClaims
+type SwitchRoleRequest struct {
+	Role string `json:"role" binding:"required,oneof=patient physician admin"`
 }
 
-func NewAuthHandler(db *gorm.DB) *AuthHandler {
-	return &AuthHandler{DB: db}
+type MFAChallengeResponse struct {
+	Success     bool   `json:"success"`
+	MFARequired bool   `json:"mfa_required"`
+	MFAToken    string `json:"mfa_token"`
+	Message     string `json:"message,omitempty"`
 }
 
-func (h *AuthHandler) generateToken(email, role string) (string, error) {
-	secretKey := os.Getenv("JWT_SECRET")
-	if secretKey == "" {
-		secretKey = "your-secret-key-change-in-production"
-	}
+type RefreshRequest struct {
+	RefreshToken string `json:"refresh_token" binding:"required"`
+}
 
-	claims := Claims{
-		Email: email,
-		Role:  role,
-		RegisteredClaims: jwt.RegisteredClaims{
-			ExpiresAt: jwt.NewNumericDate(time.Now().Add(24 * time.Hour)),
-			IssuedAt:  jwt.NewNumericDate(time.Now()),
-		},
-	}
+type LogoutRequest struct {
+	RefreshToken string `json:"refresh_token"`
+}
+
+func NewAuthHandler(db *gorm.DB, mailer mail.Sender) *AuthHandler {
+	return &AuthHandler{DB: db, Mailer: mailer}
+}
 
-	token := jwt.NewWithClaims(jwt.SigningMethodHS256, claims)
-	return token.SignedString([]byte(secretKey))
+// issueTokens starts a new session for the account acting in role
+func (h *AuthHandler) issueTokens(accountID, email, role string) (*auth.TokenPair, error) {
+	return auth.IssueTokens(h.DB, accountID, email, role, "")
 }
 
 func (h *AuthHandler) hashPassword(password string) (string, error) {
@@ -67,47 +79,119 @@ func (h *AuthHandler) checkPassword(hashedPassword, password string) bool {
 	return err == nil
 }
 
-// Auth handles general authentication endpoint
-func (h *AuthHandler) Auth(c *gin.Context) {
-	c.JSON(http.StatusOK, gin.H{
-		"message": "Auth endpoint - use /auth/patient or /auth/physician",
+// loginAllowed rejects the request with 429 while the account or client IP
+// is backing off or locked out after failed attempts
+func (h *AuthHandler) loginAllowed(c *gin.Context, email string) bool {
+	wait, err := auth.LoginRetryAfter(h.DB, auth.AccountThrottleKey(email), auth.IPThrottleKey(c.ClientIP()))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, LoginResponse{
+			Success: false,
+			Message: "Failed to process login",
+		})
+		return false
+	}
+
+	if wait > 0 {
+		seconds := int(math.Ceil(wait.Seconds()))
+		c.Header("Retry-After", strconv.Itoa(seconds))
+		c.JSON(http.StatusTooManyRequests, LoginResponse{
+			Success: false,
+			Message: fmt.Sprintf("Too many failed attempts. Try again in %d seconds.", seconds),
+		})
+		return false
+	}
+	return true
+}
+
+// recordFailedLogin counts a failed attempt and emails the account owner if
+// it caused a lockout
+func (h *AuthHandler) recordFailedLogin(c *gin.Context, email string) {
+	locked, err := auth.RecordLoginFailure(h.DB, auth.AccountThrottleKey(email), auth.IPThrottleKey(c.ClientIP()))
+	if err != nil {
+		log.Printf("Failed to record login failure: %v", err)
+		return
+	}
+	if locked {
+		h.sendLockoutEmail(email)
+	}
+}
+
+// recordSuccessfulLogin clears the account's failure count once sign-in
+// has fully succeeded, including any MFA step
+func (h *AuthHandler) recordSuccessfulLogin(email string) {
+	if err := auth.RecordLoginSuccess(h.DB, auth.AccountThrottleKey(email)); err != nil {
+		log.Printf("Failed to reset login failures: %v", err)
+	}
+}
+
+// sendLockoutEmail tells the account owner that their account was locked
+func (h *AuthHandler) sendLockoutEmail(email string) {
+	var exists int64
+	h.DB.Model(&models.Account{}).Where("email = ?", email).Count(&exists)
+	if exists == 0 {
+		return
+	}
+
+	err := h.Mailer.Send(mail.Message{
+		To:      email,
+		Subject: "Your Health Connect account has been locked",
+		Body: fmt.Sprintf("We locked your account temporarily after several failed sign-in attempts.\n\n"+
+			"You can try again in %d minutes, or reset your password if you have forgotten it. "+
+			"If these attempts were not you, please reset your password and contact support.",
+			int(auth.AccountLockoutDuration.Minutes())),
 	})
+	if err != nil {
+		log.Printf("Failed to send lockout email: %v", err)
+	}
 }
 
-// PatientLogin handles patient authentication
-func (h *AuthHandler) PatientLogin(c *gin.Context) {
-	var req LoginRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, LoginResponse{
-			Success: false,
-			Message: "Invalid request format",
+// completeLogin finishes a successful password check. Roles with MFA
+// enabled receive a challenge token; everyone else receives session tokens.
+func (h *AuthHandler) completeLogin(c *gin.Context, accountID, email, role string) {
+	var enrollment models.MFAEnrollment
+	if err := h.DB.Where("user_id = ? AND role = ? AND enabled = ?", accountID, role, true).First(&enrollment).Error; err == nil {
+		challenge, err := auth.GenerateMFAChallenge(accountID, email, role)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, LoginResponse{
+				Success: false,
+				Message: "Failed to generate token",
+			})
+			return
+		}
+
+		c.JSON(http.StatusOK, MFAChallengeResponse{
+			Success:     true,
+			MFARequired: true,
+			MFAToken:    challenge,
+			Message:     "Enter the code from your authenticator app",
 		})
 		return
 	}
 
-	var patient models.Patient
-	result := h.DB.Where("email = ?", req.Email).First(&patient)
+	h.recordSuccessfulLogin(email)
+	h.respondWithTokens(c, accountID, email, role)
+}
 
-	if result.Error != nil {
-		// If patient doesn't exist, return error
+// respondWithTokens starts a session and writes the login response
+func (h *AuthHandler) respondWithTokens(c *gin.Context, accountID, email, role string) {
+	profileID, err := models.FindProfileID(h.DB, accountID, role)
+	if err != nil {
 		c.JSON(http.StatusUnauthorized, LoginResponse{
 			Success: false,
-			Message: "Invalid email or password",
+			Message: "Account not found",
 		})
 		return
 	}
-
-	// Check password
-	if !h.checkPassword(patient.Password, req.Password) {
-		c.JSON(http.StatusUnauthorized, LoginResponse{
+	roles, err := models.AccountRoles(h.DB, accountID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, LoginResponse{
 			Success: false,
-			Message: "Invalid email or password",
+			Message: "Failed to generate token",
 		})
 		return
 	}
 
-	// Generate JWT token
-	token, err := h.generateToken(patient.Email, "patient")
+	tokens, err := h.issueTokens(accountID, email, role)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, LoginResponse{
 			Success: false,
@@ -117,13 +201,26 @@ func (h *AuthHandler) PatientLogin(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusOK, LoginResponse{
-		Success: true,
-		Token:   token,
+		Success:      true,
+		ID:           profileID,
+		Role:         role,
+		Roles:        roles,
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
 	})
 }
 
-// PhysicianLogin handles physician authentication
-func (h *AuthHandler) PhysicianLogin(c *gin.Context) {
+// Auth handles general authentication endpoint
+func (h *AuthHandler) Auth(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{
+		"message": "Auth endpoint - use /auth/login",
+	})
+}
+
+// login checks the account's password and signs it in as role. An empty
+// role uses the role from the request body, or else the first role held.
+func (h *AuthHandler) login(c *gin.Context, role string) {
 	var req LoginRequest
 	if err := c.ShouldBindJSON(&req); err != nil {
 		c.JSON(http.StatusBadRequest, LoginResponse{
@@ -132,12 +229,17 @@ func (h *AuthHandler) PhysicianLogin(c *gin.Context) {
 		})
 		return
 	}
+	if role == "" {
+		role = req.Role
+	}
 
-	var physician models.Physician
-	result := h.DB.Where("email = ?", req.Email).First(&physician)
+	if !h.loginAllowed(c, req.Email) {
+		return
+	}
 
-	if result.Error != nil {
-		// If physician doesn't exist, return error
+	var account models.Account
+	if err := h.DB.Where("email = ?", req.Email).First(&account).Error; err != nil {
+		h.recordFailedLogin(c, req.Email)
 		c.JSON(http.StatusUnauthorized, LoginResponse{
 			Success: false,
 			Message: "Invalid email or password",
@@ -146,7 +248,8 @@ func (h *AuthHandler) PhysicianLogin(c *gin.Context) {
 	}
 
 	// Check password
-	if !h.checkPassword(physician.Password, req.Password) {
+	if !h.checkPassword(account.Password, req.Password) {
+		h.recordFailedLogin(c, req.Email)
 		c.JSON(http.StatusUnauthorized, LoginResponse{
 			Success: false,
 			Message: "Invalid email or password",
@@ -154,169 +257,286 @@ func (h *AuthHandler) PhysicianLogin(c *gin.Context) {
 		return
 	}
 
-	// Generate JWT token
-	token, err := h.generateToken(physician.Email, "physician")
+	roles, err := models.AccountRoles(h.DB, account.ID)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, LoginResponse{
 			Success: false,
-			Message: "Failed to generate token",
+			Message: "Failed to process login",
+		})
+		return
+	}
+	if role == "" && len(roles) > 0 {
+		role = roles[0]
+	}
+	if !containsRole(roles, role) {
+		c.JSON(http.StatusForbidden, LoginResponse{
+			Success: false,
+			Roles:   roles,
+			Message: fmt.Sprintf("This account does not have the %s role", role),
 		})
 		return
 	}
 
-	c.JSON(http.StatusOK, LoginResponse{
-		Success: true,
-		Token:   token,
-	})
+	// Block sign-in until the email address is confirmed
+	if !account.Verified {
+		c.JSON(http.StatusForbidden, LoginResponse{
+			Success: false,
+			Message: "Please verify your email address before signing in",
+		})
+		return
+	}
+
+	h.completeLogin(c, account.ID, account.Email, role)
+}
+
+func containsRole(roles []string, role string) bool {
+	for _, r := range roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+// Login authenticates any account; the role is chosen by the request or
+// defaults to the first one the account holds
+func (h *AuthHandler) Login(c *gin.Context) {
+	h.login(c, "")
+}
+
+// PatientLogin handles patient authentication
+func (h *AuthHandler) PatientLogin(c *gin.Context) {
+	h.login(c, auth.RolePatient)
+}
+
+// PhysicianLogin handles physician authentication
+func (h *AuthHandler) PhysicianLogin(c *gin.Context) {
+	h.login(c, auth.RolePhysician)
+}
+
+// AdminLogin handles admin authentication
+func (h *AuthHandler) AdminLogin(c *gin.Context) {
+	h.login(c, auth.RoleAdmin)
+}
+
+// SwitchRole starts a session in another role held by the caller's account
+func (h *AuthHandler) SwitchRole(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	var req SwitchRoleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, LoginResponse{
+			Success: false,
+			Message: "Invalid request format",
+		})
+		return
+	}
+
+	roles, err := models.AccountRoles(h.DB, principal.AccountID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, LoginResponse{
+			Success: false,
+			Message: "Failed to switch role",
+		})
+		return
+	}
+	if !containsRole(roles, req.Role) {
+		c.JSON(http.StatusForbidden, LoginResponse{
+			Success: false,
+			Roles:   roles,
+			Message: fmt.Sprintf("This account does not have the %s role", req.Role),
+		})
+		return
+	}
+
+	h.completeLogin(c, principal.AccountID, principal.Email, req.Role)
 }
 
 type RegisterResponse struct {
-	Success bool   `json:"success"`
-	Token   string `json:"token,omitempty"`
-	Message string `json:"message,omitempty"`
-	ID      string `json:"id,omitempty"`
+	Success      bool   `json:"success"`
+	Role         string `json:"role,omitempty"`
+	Token        string `json:"token,omitempty"`
+	RefreshToken string `json:"refresh_token,omitempty"`
+	ExpiresIn    int64  `json:"expires_in,omitempty"`
+	Message      string `json:"message,omitempty"`
+	ID           string `json:"id,omitempty"`
 }
 
-type PatientRegisterRequest struct {
+// PatientProfileRequest holds the fields of a patient profile
+type PatientProfileRequest struct {
 	Username     string  `json:"username" binding:"required"`
-	Email        string  `json:"email" binding:"required,email"`
-	Password     string  `json:"password" binding:"required,min=6"`
 	Name         string  `json:"name" binding:"required"`
 	Address      string  `json:"address" binding:"required"`
 	HasInsurance bool    `json:"has_insurance"`
 	PhysicianID  *string `json:"physician_id,omitempty"` // Optional physician ID (UUID)
 }
 
-type PhysicianRegisterRequest struct {
+type PatientRegisterRequest struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required,min=6"`
+	PatientProfileRequest
+}
+
+// PhysicianProfileRequest holds the fields of a physician profile
+type PhysicianProfileRequest struct {
 	Username       string   `json:"username" binding:"required"`
-	Email          string   `json:"email" binding:"required,email"`
-	Password       string   `json:"password" binding:"required,min=6"`
 	Name           string   `json:"name" binding:"required"`
 	Address        string   `json:"address" binding:"required"`
 	License        string   `json:"license" binding:"required"`
+	LicenseState   string   `json:"license_state" binding:"required,len=2"` // Two-letter issuing state, e.g. "GA"
+	NPI            string   `json:"npi" binding:"required,len=10,numeric"`
 	OfficeLocation string   `json:"office_location" binding:"required"`
 	Specialties    []string `json:"specialties" binding:"required,min=1"` // Array of specialty names
 }
 
-// PatientRegister handles patient registration
-func (h *AuthHandler) PatientRegister(c *gin.Context) {
-	var req PatientRegisterRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, RegisterResponse{
-			Success: false,
-			Message: "Invalid request format: " + err.Error(),
-		})
-		return
-	}
+type PhysicianRegisterRequest struct {
+	Email    string `json:"email" binding:"required,email"`
+	Password string `json:"password" binding:"required,min=6"`
+	PhysicianProfileRequest
+}
 
-	// Check if email already exists
-	var existingPatient models.Patient
-	if result := h.DB.Where("email = ?", req.Email).First(&existingPatient); result.Error == nil {
-		c.JSON(http.StatusConflict, RegisterResponse{
+// newAccount prepares an unsaved account for a registration. An email that
+// is already registered is rejected; its owner adds roles after signing in.
+func (h *AuthHandler) newAccount(c *gin.Context, email, password string) (*models.Account, bool) {
+	var count int64
+	if err := h.DB.Model(&models.Account{}).Where("email = ?", email).Count(&count).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, RegisterResponse{
 			Success: false,
-			Message: "Email already registered",
+			Message: "Failed to create account",
 		})
-		return
+		return nil, false
 	}
-
-	// Check if username already exists
-	if result := h.DB.Where("username = ?", req.Username).First(&existingPatient); result.Error == nil {
+	if count > 0 {
 		c.JSON(http.StatusConflict, RegisterResponse{
 			Success: false,
-			Message: "Username already taken",
+			Message: "Email already registered; sign in to add another role to your account",
 		})
-		return
+		return nil, false
 	}
 
 	// Hash password
-	hashedPassword, err := h.hashPassword(req.Password)
+	hashedPassword, err := h.hashPassword(password)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, RegisterResponse{
 			Success: false,
 			Message: "Failed to process password",
 		})
-		return
+		return nil, false
+	}
+	return &models.Account{Email: email, Password: hashedPassword}, true
+}
+
+// roleAccount loads the caller's account for adding a role it does not
+// hold yet
+func (h *AuthHandler) roleAccount(c *gin.Context, role string) (*models.Account, bool) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	var account models.Account
+	if err := h.DB.Where("id = ?", principal.AccountID).First(&account).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, RegisterResponse{
+			Success: false,
+			Message: "Account not found",
+		})
+		return nil, false
+	}
+	if _, err := models.FindProfileID(h.DB, account.ID, role); err == nil {
+		c.JSON(http.StatusConflict, RegisterResponse{
+			Success: false,
+			Message: fmt.Sprintf("This account already has the %s role", role),
+		})
+		return nil, false
+	}
+	return &account, true
+}
+
+// createProfile saves a new profile together with its account if the
+// account is new. setAccountID links the profile before it is created.
+func (h *AuthHandler) createProfile(account *models.Account, profile interface{}, setAccountID func(string)) error {
+	return h.DB.Transaction(func(tx *gorm.DB) error {
+		if account.ID == "" {
+			if err := tx.Create(account).Error; err != nil {
+				return err
+			}
+		}
+		setAccountID(account.ID)
+		return tx.Create(profile).Error
+	})
+}
+
+// createPatient validates and saves a patient profile for account
+func (h *AuthHandler) createPatient(c *gin.Context, account *models.Account, req PatientProfileRequest) (*models.Patient, bool) {
+	// Check if username already exists
+	var existingPatient models.Patient
+	if result := h.DB.Where("username = ?", req.Username).First(&existingPatient); result.Error == nil {
+		c.JSON(http.StatusConflict, RegisterResponse{
+			Success: false,
+			Message: "Username already taken",
+		})
+		return nil, false
 	}
 
 	// Create patient
 	patient := models.Patient{
 		Username:     req.Username,
-		Email:        req.Email,
-		Password:     hashedPassword,
+		Email:        account.Email,
 		Name:         req.Name,
 		Address:      req.Address,
 		HasInsurance: req.HasInsurance,
-		Verified:     true, // Auto-verified
 	}
 
 	// If physician ID is provided, associate the patient with the physician
 	if req.PhysicianID != nil {
 		var physician models.Physician
-		if result := h.DB.First(&physician, *req.PhysicianID); result.Error != nil {
+		if result := h.DB.Where("id = ? AND license_status = ?", *req.PhysicianID, models.LicenseStatusApproved).First(&physician); result.Error != nil {
 			c.JSON(http.StatusBadRequest, RegisterResponse{
 				Success: false,
 				Message: "Physician not found",
 			})
-			return
+			return nil, false
 		}
 		patient.Physicians = []models.Physician{physician}
 	}
 
 	// Save patient
-	if err := h.DB.Create(&patient).Error; err != nil {
+	if err := h.createProfile(account, &patient, func(id string) { patient.AccountID = id }); err != nil {
 		c.JSON(http.StatusInternalServerError, RegisterResponse{
 			Success: false,
 			Message: "Failed to create account",
 		})
-		return
+		return nil, false
 	}
-
-	// Generate JWT token
-	token, err := h.generateToken(patient.Email, "patient")
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, RegisterResponse{
-			Success: false,
-			Message: "Account created but failed to generate token",
-		})
-		return
-	}
-
-	c.JSON(http.StatusCreated, RegisterResponse{
-		Success: true,
-		Token:   token,
-		ID:      patient.ID,
-		Message: "Patient account created successfully",
-	})
+	return &patient, true
 }
 
-// PhysicianRegister handles physician registration
-func (h *AuthHandler) PhysicianRegister(c *gin.Context) {
-	var req PhysicianRegisterRequest
-	if err := c.ShouldBindJSON(&req); err != nil {
-		c.JSON(http.StatusBadRequest, RegisterResponse{
+// createPhysician validates and saves a physician profile for account. The
+// license starts pending review.
+func (h *AuthHandler) createPhysician(c *gin.Context, account *models.Account, req PhysicianProfileRequest) (*models.Physician, bool) {
+	// Check if username already exists
+	var existingPhysician models.Physician
+	if result := h.DB.Where("username = ?", req.Username).First(&existingPhysician); result.Error == nil {
+		c.JSON(http.StatusConflict, RegisterResponse{
 			Success: false,
-			Message: "Invalid request format: " + err.Error(),
+			Message: "Username already taken",
 		})
-		return
+		return nil, false
 	}
 
-	// Check if email already exists
-	var existingPhysician models.Physician
-	if result := h.DB.Where("email = ?", req.Email).First(&existingPhysician); result.Error == nil {
-		c.JSON(http.StatusConflict, RegisterResponse{
+	// Validate license format for the issuing state and the NPI check digit
+	req.License = validation.NormalizeLicense(req.License)
+	req.LicenseState = strings.ToUpper(req.LicenseState)
+	if err := validation.ValidateLicense(req.LicenseState, req.License); err != nil {
+		c.JSON(http.StatusBadRequest, RegisterResponse{
 			Success: false,
-			Message: "Email already registered",
+			Message: "Invalid license: " + err.Error(),
 		})
-		return
+		return nil, false
 	}
-
-	// Check if username already exists
-	if result := h.DB.Where("username = ?", req.Username).First(&existingPhysician); result.Error == nil {
-		c.JSON(http.StatusConflict, RegisterResponse{
+	if !validation.ValidNPI(req.NPI) {
+		c.JSON(http.StatusBadRequest, RegisterResponse{
 			Success: false,
-			Message: "Username already taken",
+			Message: "Invalid NPI number",
 		})
-		return
+		return nil, false
 	}
 
 	// Check if license already exists
@@ -325,17 +545,16 @@ func (h *AuthHandler) PhysicianRegister(c *gin.Context) {
 			Success: false,
 			Message: "License number already registered",
 		})
-		return
+		return nil, false
 	}
 
-	// Hash password
-	hashedPassword, err := h.hashPassword(req.Password)
-	if err != nil {
-		c.JSON(http.StatusInternalServerError, RegisterResponse{
+	// Check if NPI already exists
+	if result := h.DB.Where("npi = ?", req.NPI).First(&existingPhysician); result.Error == nil {
+		c.JSON(http.StatusConflict, RegisterResponse{
 			Success: false,
-			Message: "Failed to process password",
+			Message: "NPI number already registered",
 		})
-		return
+		return nil, false
 	}
 
 	// Find or create specialties
@@ -354,7 +573,7 @@ func (h *AuthHandler) PhysicianRegister(c *gin.Context) {
 					Success: false,
 					Message: "Failed to create specialty: " + specialtyName,
 				})
-				return
+				return nil, false
 			}
 		}
 		specialties = append(specialties, specialty)
@@ -363,27 +582,38 @@ func (h *AuthHandler) PhysicianRegister(c *gin.Context) {
 	// Create physician
 	physician := models.Physician{
 		Username:       req.Username,
-		Email:          req.Email,
-		Password:       hashedPassword,
+		Email:          account.Email,
 		Name:           req.Name,
 		Address:        req.Address,
 		License:        req.License,
+		LicenseState:   req.LicenseState,
+		NPI:            &req.NPI,
+		LicenseStatus:  models.LicenseStatusPending, // Reviewed by an admin before accessing patient data
 		OfficeLocation: req.OfficeLocation,
-		Verified:       true, // Auto-verified
 		Specialties:    specialties,
 	}
 
 	// Save physician
-	if err := h.DB.Create(&physician).Error; err != nil {
+	if err := h.createProfile(account, &physician, func(id string) { physician.AccountID = id }); err != nil {
 		c.JSON(http.StatusInternalServerError, RegisterResponse{
 			Success: false,
 			Message: "Failed to create account",
 		})
-		return
+		return nil, false
+	}
+	return &physician, true
+}
+
+// respondRegistered emails the verification link for a new account and
+// writes the registration response with session tokens
+func (h *AuthHandler) respondRegistered(c *gin.Context, account *models.Account, role, profileID, message string) {
+	// Send the verification link; the account stays unverified until it is used
+	if err := h.sendVerificationEmail(account.ID, account.Email); err != nil {
+		log.Printf("Failed to send verification email: %v", err)
 	}
 
-	// Generate JWT token
-	token, err := h.generateToken(physician.Email, "physician")
+	// Generate JWT access and refresh tokens
+	tokens, err := h.issueTokens(account.ID, account.Email, role)
 	if err != nil {
 		c.JSON(http.StatusInternalServerError, RegisterResponse{
 			Success: false,
@@ -393,10 +623,269 @@ func (h *AuthHandler) PhysicianRegister(c *gin.Context) {
 	}
 
 	c.JSON(http.StatusCreated, RegisterResponse{
+		Success:      true,
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+		ID:           profileID,
+		Role:         role,
+		Message:      message,
+	})
+}
+
+// PatientRegister handles patient registration
+func (h *AuthHandler) PatientRegister(c *gin.Context) {
+	var req PatientRegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, RegisterResponse{
+			Success: false,
+			Message: "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+
+	account, ok := h.newAccount(c, req.Email, req.Password)
+	if !ok {
+		return
+	}
+	patient, ok := h.createPatient(c, account, req.PatientProfileRequest)
+	if !ok {
+		return
+	}
+
+	h.respondRegistered(c, account, auth.RolePatient, patient.ID,
+		"Patient account created. Check your email to verify your account.")
+}
+
+// PhysicianRegister handles physician registration
+func (h *AuthHandler) PhysicianRegister(c *gin.Context) {
+	var req PhysicianRegisterRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, RegisterResponse{
+			Success: false,
+			Message: "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+
+	account, ok := h.newAccount(c, req.Email, req.Password)
+	if !ok {
+		return
+	}
+	physician, ok := h.createPhysician(c, account, req.PhysicianProfileRequest)
+	if !ok {
+		return
+	}
+
+	h.respondRegistered(c, account, auth.RolePhysician, physician.ID,
+		"Physician account created. Check your email to verify your account; your license is pending review.")
+}
+
+// AddPatientRole adds a patient profile to the caller's account and starts
+// a session in it
+func (h *AuthHandler) AddPatientRole(c *gin.Context) {
+	var req PatientProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, RegisterResponse{
+			Success: false,
+			Message: "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+
+	account, ok := h.roleAccount(c, auth.RolePatient)
+	if !ok {
+		return
+	}
+	if _, ok := h.createPatient(c, account, req); !ok {
+		return
+	}
+
+	h.completeLogin(c, account.ID, account.Email, auth.RolePatient)
+}
+
+// AddPhysicianRole adds a physician profile to the caller's account and
+// starts a session in it. The license starts pending review.
+func (h *AuthHandler) AddPhysicianRole(c *gin.Context) {
+	var req PhysicianProfileRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, RegisterResponse{
+			Success: false,
+			Message: "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+
+	account, ok := h.roleAccount(c, auth.RolePhysician)
+	if !ok {
+		return
+	}
+	if _, ok := h.createPhysician(c, account, req); !ok {
+		return
+	}
+
+	h.completeLogin(c, account.ID, account.Email, auth.RolePhysician)
+}
+
+// Refresh rotates a refresh token and issues a new access token
+func (h *AuthHandler) Refresh(c *gin.Context) {
+	var req RefreshRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, LoginResponse{
+			Success: false,
+			Message: "Invalid request format",
+		})
+		return
+	}
+
+	stored, err := auth.ConsumeRefreshToken(h.DB, req.RefreshToken)
+	if err != nil {
+		message := "Invalid or expired refresh token"
+		if errors.Is(err, auth.ErrRefreshTokenReused) {
+			message = "Refresh token reuse detected; please sign in again"
+		} else if !errors.Is(err, auth.ErrInvalidRefreshToken) {
+			c.JSON(http.StatusInternalServerError, LoginResponse{
+				Success: false,
+				Message: "Failed to refresh token",
+			})
+			return
+		}
+		c.JSON(http.StatusUnauthorized, LoginResponse{
+			Success: false,
+			Message: message,
+		})
+		return
+	}
+
+	// Reload the account so deleted users cannot keep refreshing
+	var account models.Account
+	profileID := ""
+	if err := h.DB.Where("id = ?", stored.UserID).First(&account).Error; err == nil {
+		profileID, _ = models.FindProfileID(h.DB, account.ID, stored.Role)
+	}
+	if profileID == "" {
+		_ = auth.RevokeFamily(h.DB, stored.FamilyID)
+		c.JSON(http.StatusUnauthorized, LoginResponse{
+			Success: false,
+			Message: "Account not found",
+		})
+		return
+	}
+
+	tokens, err := auth.IssueTokens(h.DB, account.ID, account.Email, stored.Role, stored.FamilyID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, LoginResponse{
+			Success: false,
+			Message: "Failed to generate token",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, LoginResponse{
+		Success:      true,
+		ID:           profileID,
+		Role:         stored.Role,
+		Token:        tokens.AccessToken,
+		RefreshToken: tokens.RefreshToken,
+		ExpiresIn:    tokens.ExpiresIn,
+	})
+}
+
+// Logout revokes the current access token and, if given, its refresh token family
+func (h *AuthHandler) Logout(c *gin.Context) {
+	principal, ok := auth.CurrentPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, LoginResponse{
+			Success: false,
+			Message: "Authentication required",
+		})
+		return
+	}
+
+	var req LogoutRequest
+	_ = c.ShouldBindJSON(&req)
+
+	if err := auth.RevokeAccessToken(h.DB, principal.Claims); err != nil {
+		c.JSON(http.StatusInternalServerError, LoginResponse{
+			Success: false,
+			Message: "Failed to log out",
+		})
+		return
+	}
+
+	if req.RefreshToken != "" {
+		if err := auth.RevokeRefreshToken(h.DB, req.RefreshToken); err != nil && !errors.Is(err, auth.ErrInvalidRefreshToken) {
+			c.JSON(http.StatusInternalServerError, LoginResponse{
+				Success: false,
+				Message: "Failed to log out",
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, LoginResponse{
 		Success: true,
-		Token:   token,
-		ID:      physician.ID,
-		Message: "Physician account created successfully",
+		Message: "Logged out",
 	})
 }
 
+// Me returns the profile of the authenticated caller
+func (h *AuthHandler) Me(c *gin.Context) {
+	principal, ok := auth.CurrentPrincipal(c)
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "Authentication required",
+		})
+		return
+	}
+
+	var user interface{}
+	switch principal.Role {
+	case auth.RolePatient:
+		var patient models.Patient
+		if err := h.DB.Where("id = ?", principal.ID).First(&patient).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Patient not found",
+			})
+			return
+		}
+		user = patient
+	case auth.RolePhysician:
+		var physician models.Physician
+		if err := h.DB.Preload("Specialties").Where("id = ?", principal.ID).First(&physician).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Physician not found",
+			})
+			return
+		}
+		user = physician
+	case auth.RoleAdmin:
+		var admin models.Admin
+		if err := h.DB.Where("id = ?", principal.ID).First(&admin).Error; err != nil {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Admin not found",
+			})
+			return
+		}
+		user = admin
+	}
+
+	roles, err := models.AccountRoles(h.DB, principal.AccountID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to load account roles",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"id":         principal.ID,
+		"account_id": principal.AccountID,
+		"role":       principal.Role,
+		"roles":      roles,
+		"verified":   principal.Verified,
+		"user":       user,
+	})
+}
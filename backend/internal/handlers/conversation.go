@@ -0,0 +1,529 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/yourusername/health-connect/internal/auth"
+	"github.com/yourusername/health-connect/internal/models"
+	"github.com/yourusername/health-connect/internal/notify"
+	"github.com/yourusername/health-connect/internal/realtime"
+)
+
+type ConversationHandler struct {
+	DB       *gorm.DB
+	Messages *MessageHandler
+}
+
+func NewConversationHandler(db *gorm.DB, messages *MessageHandler) *ConversationHandler {
+	return &ConversationHandler{DB: db, Messages: messages}
+}
+
+type CreateConversationRequest struct {
+	PatientID    string   `json:"patient_id"` // Required when a physician creates it
+	PhysicianIDs []string `json:"physician_ids"`
+	Subject      string   `json:"subject" binding:"required,max=200"`
+	Content      string   `json:"content" binding:"required,max=10000"`
+	Urgency      string   `json:"urgency" binding:"omitempty,oneof=routine urgent emergency"`
+	Category     string   `json:"category" binding:"omitempty,oneof=refill billing symptoms appointment other"`
+}
+
+type AddParticipantRequest struct {
+	PhysicianID string `json:"physician_id" binding:"required"`
+	Role        string `json:"role" binding:"omitempty,oneof=owner member"` // Defaults to member
+}
+
+type UpdateParticipantRequest struct {
+	Role string `json:"role" binding:"required,oneof=owner member"`
+}
+
+// ParticipantReadState is how far one member has read a conversation
+type ParticipantReadState struct {
+	ParticipantID   string     `json:"participant_id"`
+	ParticipantType string     `json:"participant_type"`
+	Unread          int        `json:"unread"`
+	LastReadAt      *time.Time `json:"last_read_at"`
+}
+
+// checkCareTeamMember reports why a physician cannot join a patient's
+// conversations, or "" if they can
+func (h *ConversationHandler) checkCareTeamMember(patientID, physicianID string) (int, string) {
+	var physician models.Physician
+	if err := h.DB.Where("id = ?", physicianID).First(&physician).Error; err != nil {
+		return http.StatusBadRequest, "Physician " + physicianID + " not found"
+	}
+	if physician.LicenseStatus != models.LicenseStatusApproved {
+		return http.StatusBadRequest, physician.Name + "'s license has not been approved"
+	}
+	linked, err := models.IsPatientLinked(h.DB, patientID, physicianID)
+	if err != nil {
+		return http.StatusInternalServerError, "Failed to verify care team"
+	}
+	if !linked {
+		return http.StatusForbidden, physician.Name + " is not linked with this patient"
+	}
+	return 0, ""
+}
+
+// findConversation loads a conversation the caller belongs to, with the
+// caller's membership
+func (h *ConversationHandler) findConversation(c *gin.Context, principal *auth.Principal) (*models.Conversation, *models.ConversationParticipant, bool) {
+	conversation, err := models.FindConversation(h.DB, c.Param("id"))
+	if err == nil {
+		member, err := models.FindParticipant(h.DB, conversation.ID, principal.ID, principal.Role)
+		if err == nil {
+			return conversation, member, true
+		}
+	}
+	c.JSON(http.StatusNotFound, gin.H{
+		"error": "Conversation not found",
+	})
+	return nil, nil, false
+}
+
+// requireOwner responds with 403 unless the caller owns the conversation
+func requireOwner(c *gin.Context, member *models.ConversationParticipant) bool {
+	if member.Role != models.ConversationRoleOwner {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": "Only conversation owners can manage participants",
+		})
+		return false
+	}
+	return true
+}
+
+// publishConversationUpdate tells the current members, and anyone who just
+// left, that membership changed
+func (h *ConversationHandler) publishConversationUpdate(conversationID string, removed *models.ConversationParticipant) {
+	participants, err := models.ActiveParticipants(h.DB, conversationID)
+	if err != nil {
+		log.Printf("Failed to find participants of conversation %s: %v", conversationID, err)
+		return
+	}
+	if removed != nil {
+		participants = append(participants, *removed)
+	}
+	event := gin.H{"conversation_id": conversationID}
+	for _, participant := range participants {
+		h.Messages.publish(participant.ParticipantType, participant.ParticipantID, realtime.EventConversationUpdated, event)
+	}
+}
+
+// CreateConversation starts a care-team conversation between a patient and
+// several physicians with its first message. A physician who creates one
+// owns it; when a patient does, the first physician listed owns it.
+func (h *ConversationHandler) CreateConversation(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	var req CreateConversationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+
+	// The first physician is the owner
+	var physicianIDs []string
+	seen := map[string]bool{}
+	addPhysician := func(id string) {
+		if id = strings.TrimSpace(id); id != "" && !seen[id] {
+			seen[id] = true
+			physicianIDs = append(physicianIDs, id)
+		}
+	}
+
+	patientID := req.PatientID
+	if principal.IsPatient() {
+		patientID = principal.ID
+	} else {
+		addPhysician(principal.ID)
+	}
+	if patientID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "patient_id is required",
+		})
+		return
+	}
+	for _, id := range req.PhysicianIDs {
+		addPhysician(id)
+	}
+	if len(physicianIDs) < 2 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "A care-team conversation needs at least two physicians; use /messages for one-to-one conversations",
+		})
+		return
+	}
+	for _, physicianID := range physicianIDs {
+		if status, message := h.checkCareTeamMember(patientID, physicianID); status != 0 {
+			c.JSON(status, gin.H{
+				"success": false,
+				"message": message,
+			})
+			return
+		}
+	}
+
+	conversation := models.Conversation{
+		PatientID:     patientID,
+		Subject:       strings.TrimSpace(req.Subject),
+		CreatedByID:   principal.ID,
+		CreatedByType: principal.Role,
+	}
+	conversation.Participants = append(conversation.Participants, models.ConversationParticipant{
+		ParticipantID:   patientID,
+		ParticipantType: auth.RolePatient,
+		Role:            models.ConversationRoleMember,
+		AddedByID:       principal.ID,
+	})
+	for i, physicianID := range physicianIDs {
+		role := models.ConversationRoleMember
+		if i == 0 {
+			role = models.ConversationRoleOwner
+		}
+		conversation.Participants = append(conversation.Participants, models.ConversationParticipant{
+			ParticipantID:   physicianID,
+			ParticipantType: auth.RolePhysician,
+			Role:            role,
+			AddedByID:       principal.ID,
+		})
+	}
+	if err := h.DB.Create(&conversation).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create conversation",
+		})
+		return
+	}
+
+	message := models.Message{
+		PatientID:   &patientID,
+		PhysicianID: &physicianIDs[0],
+		Subject:     conversation.Subject,
+		Content:     req.Content,
+		SenderType:  principal.Role,
+		Urgency:     req.Urgency,
+		Category:    req.Category,
+		ThreadID:    conversation.ID,
+	}
+	if err := h.Messages.sendMessage(&message, true); err != nil {
+		h.DB.Select("Participants").Delete(&conversation)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to send message",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success":      true,
+		"conversation": conversation,
+		"message":      message,
+	})
+}
+
+// GetConversations lists the caller's care-team conversations, most recently active first
+func (h *ConversationHandler) GetConversations(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	conversations := []models.Conversation{}
+	result := h.DB.Where("id IN (?)", models.ParticipatingThreads(h.DB, principal.ID, principal.Role)).
+		Preload("Participants", "left_at IS NULL").
+		Order("updated_at DESC").
+		Find(&conversations)
+
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch conversations",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":       true,
+		"conversations": conversations,
+	})
+}
+
+// GetConversation returns a conversation with its members, including those
+// who have left, and how far each current member has read. Its messages
+// are at /threads/:id.
+func (h *ConversationHandler) GetConversation(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	conversation, _, ok := h.findConversation(c, principal)
+	if !ok {
+		return
+	}
+
+	var receipts []models.MessageReceipt
+	err := h.DB.Where("conversation_id = ?", conversation.ID).Order("joined_at ASC").Find(&conversation.Participants).Error
+	if err == nil {
+		err = h.DB.Where("thread_id = ?", conversation.ID).Find(&receipts).Error
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch conversation",
+		})
+		return
+	}
+
+	readState := []ParticipantReadState{}
+	for _, participant := range conversation.Participants {
+		if participant.LeftAt != nil {
+			continue
+		}
+		state := ParticipantReadState{ParticipantID: participant.ParticipantID, ParticipantType: participant.ParticipantType}
+		for _, receipt := range receipts {
+			if receipt.RecipientID != participant.ParticipantID || receipt.RecipientType != participant.ParticipantType {
+				continue
+			}
+			if receipt.ReadAt == nil {
+				state.Unread++
+			} else if state.LastReadAt == nil || receipt.ReadAt.After(*state.LastReadAt) {
+				state.LastReadAt = receipt.ReadAt
+			}
+		}
+		readState = append(readState, state)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":      true,
+		"conversation": conversation,
+		"read_state":   readState,
+	})
+}
+
+// SendConversationMessage posts a message to a care-team conversation
+func (h *ConversationHandler) SendConversationMessage(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	var req ReplyMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+
+	conversation, _, ok := h.findConversation(c, principal)
+	if !ok {
+		return
+	}
+
+	var last models.Message
+	if err := h.DB.Where("thread_id = ?", conversation.ID).Order("sent_at DESC").First(&last).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to send message",
+		})
+		return
+	}
+
+	message := newReply(principal, &last, req.Subject, req.Content)
+	message.Urgency = req.Urgency
+	if req.Category != "" {
+		message.Category = req.Category
+	}
+
+	h.Messages.createMessage(c, &message)
+}
+
+// AddParticipant adds a physician from the patient's care team to a conversation
+func (h *ConversationHandler) AddParticipant(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	var req AddParticipantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+
+	conversation, member, ok := h.findConversation(c, principal)
+	if !ok || !requireOwner(c, member) {
+		return
+	}
+
+	if _, err := models.FindParticipant(h.DB, conversation.ID, req.PhysicianID, auth.RolePhysician); err == nil {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": "This physician is already in the conversation",
+		})
+		return
+	}
+	if status, message := h.checkCareTeamMember(conversation.PatientID, req.PhysicianID); status != 0 {
+		c.JSON(status, gin.H{
+			"success": false,
+			"message": message,
+		})
+		return
+	}
+
+	participant := models.ConversationParticipant{
+		ConversationID:  conversation.ID,
+		ParticipantID:   req.PhysicianID,
+		ParticipantType: auth.RolePhysician,
+		Role:            req.Role,
+		AddedByID:       principal.ID,
+	}
+	if participant.Role == "" {
+		participant.Role = models.ConversationRoleMember
+	}
+	if err := h.DB.Create(&participant).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to add participant",
+		})
+		return
+	}
+	h.publishConversationUpdate(conversation.ID, nil)
+	h.Messages.Notifier.Notify(auth.RolePhysician, participant.ParticipantID, notify.KindConversation, "/conversations/"+conversation.ID, false)
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success":     true,
+		"participant": participant,
+	})
+}
+
+// findMember loads the current member named in the URL
+func (h *ConversationHandler) findMember(c *gin.Context, conversationID string) (*models.ConversationParticipant, bool) {
+	var participant models.ConversationParticipant
+	err := h.DB.Where("conversation_id = ? AND participant_id = ? AND left_at IS NULL", conversationID, c.Param("participantId")).
+		First(&participant).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Participant not found",
+		})
+		return nil, false
+	}
+	return &participant, true
+}
+
+// isLastOwner reports whether a member is the conversation's only owner
+func (h *ConversationHandler) isLastOwner(participant *models.ConversationParticipant) (bool, error) {
+	if participant.Role != models.ConversationRoleOwner {
+		return false, nil
+	}
+	var owners int64
+	err := h.DB.Model(&models.ConversationParticipant{}).
+		Where("conversation_id = ? AND role = ? AND left_at IS NULL", participant.ConversationID, models.ConversationRoleOwner).
+		Count(&owners).Error
+	return owners <= 1, err
+}
+
+// lastOwnerError responds when a change would leave a conversation without an owner
+func (h *ConversationHandler) lastOwnerError(c *gin.Context, participant *models.ConversationParticipant) bool {
+	last, err := h.isLastOwner(participant)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to check conversation owners",
+		})
+		return true
+	}
+	if last {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": "A conversation must keep at least one owner; make another physician an owner first",
+		})
+		return true
+	}
+	return false
+}
+
+// UpdateParticipant changes a physician's role in a conversation
+func (h *ConversationHandler) UpdateParticipant(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	var req UpdateParticipantRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+
+	conversation, member, ok := h.findConversation(c, principal)
+	if !ok || !requireOwner(c, member) {
+		return
+	}
+	participant, ok := h.findMember(c, conversation.ID)
+	if !ok {
+		return
+	}
+	if participant.ParticipantType == auth.RolePatient {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "The patient's role cannot be changed",
+		})
+		return
+	}
+	if req.Role != models.ConversationRoleOwner && h.lastOwnerError(c, participant) {
+		return
+	}
+
+	if err := h.DB.Model(participant).Update("role", req.Role).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update participant",
+		})
+		return
+	}
+	h.publishConversationUpdate(conversation.ID, nil)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"participant": participant,
+	})
+}
+
+// RemoveParticipant takes a physician out of a conversation. Owners can
+// remove anyone but the patient; any physician can leave. Their receipts
+// and messages stay in the conversation's history.
+func (h *ConversationHandler) RemoveParticipant(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	conversation, member, ok := h.findConversation(c, principal)
+	if !ok {
+		return
+	}
+	participant, ok := h.findMember(c, conversation.ID)
+	if !ok {
+		return
+	}
+	if participant.ParticipantType == auth.RolePatient {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "The patient cannot be removed from their conversation",
+		})
+		return
+	}
+	if participant.ID != member.ID && !requireOwner(c, member) {
+		return
+	}
+	if h.lastOwnerError(c, participant) {
+		return
+	}
+
+	now := time.Now()
+	if err := h.DB.Model(participant).Update("left_at", now).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to remove participant",
+		})
+		return
+	}
+	h.publishConversationUpdate(conversation.ID, participant)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Participant removed",
+	})
+}
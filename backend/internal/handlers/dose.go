@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"net/http"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yourusername/health-connect/internal/dosing"
+	"github.com/yourusername/health-connect/internal/models"
+)
+
+// maxDoseDays is the furthest ahead upcoming doses are listed
+const maxDoseDays = 31
+
+// UpcomingDose is a dose of one of the patient's medications
+type UpcomingDose struct {
+	dosing.Dose
+	MaxAmount      float64 `json:"max_amount,omitempty"`
+	Unit           string  `json:"unit,omitempty"`
+	Route          string  `json:"route,omitempty"`
+	MedicationID   string  `json:"medication_id"`
+	MedicationName string  `json:"medication_name"`
+}
+
+// schedule returns a medication's dosing schedule. Medications saved before
+// schedules were kept are read from their dosage and frequency.
+func schedule(medication *models.Medication) *dosing.Schedule {
+	if medication.Schedule != nil {
+		return medication.Schedule
+	}
+	schedule, _ := dosing.ParseMedication(medication.Dosage, medication.Frequency)
+	return schedule
+}
+
+// GetUpcomingDoses lists when the patient's active medications are due,
+// from now or from, for the next days (default 1), in the time zone tz
+// (default UTC). Medications taken as needed and those without a schedule
+// are listed separately.
+func (h *MedicationHandler) GetUpcomingDoses(c *gin.Context) {
+	from := time.Now()
+	if value := c.Query("from"); value != "" {
+		var err error
+		if from, err = time.Parse(time.RFC3339, value); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "from must be an RFC 3339 time",
+			})
+			return
+		}
+	}
+	days := 1
+	if value := c.Query("days"); value != "" {
+		var err error
+		if days, err = strconv.Atoi(value); err != nil || days < 1 || days > maxDoseDays {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "days must be between 1 and " + strconv.Itoa(maxDoseDays),
+			})
+			return
+		}
+	}
+
+	loc := time.UTC
+	if value := c.Query("tz"); value != "" {
+		var err error
+		if loc, err = time.LoadLocation(value); err != nil || value == "Local" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "tz must be an IANA time zone such as America/New_York",
+			})
+			return
+		}
+	}
+	from = from.In(loc)
+	until := from.AddDate(0, 0, days)
+
+	var medications []models.Medication
+	if err := h.DB.Where("patient_id = ?", c.Param("id")).Order("name ASC").Find(&medications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch doses",
+		})
+		return
+	}
+
+	doses := []UpcomingDose{}
+	asNeeded := []models.Medication{}
+	unscheduled := []models.Medication{}
+	for _, medication := range medications {
+		if !medication.ActiveDuring(from, until) {
+			continue
+		}
+		medication.Schedule = schedule(&medication)
+		switch {
+		case medication.Schedule == nil:
+			unscheduled = append(unscheduled, medication)
+		case medication.Schedule.AsNeeded:
+			asNeeded = append(asNeeded, medication)
+		default:
+			for _, dose := range medication.Schedule.Doses(medication.StartDate, medication.EndDate, from, until, loc) {
+				upcoming := UpcomingDose{
+					Dose:           dose,
+					Unit:           medication.Schedule.Unit,
+					Route:          medication.Schedule.Route,
+					MedicationID:   medication.ID,
+					MedicationName: medication.Name,
+				}
+				if len(medication.Schedule.Taper) == 0 {
+					upcoming.MaxAmount = medication.Schedule.MaxAmount
+				}
+				doses = append(doses, upcoming)
+			}
+		}
+	}
+	sort.SliceStable(doses, func(i, j int) bool {
+		return doses[i].At.Before(doses[j].At)
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"time_zone":   loc.String(),
+		"from":        from,
+		"until":       until,
+		"doses":       doses,
+		"as_needed":   asNeeded,
+		"unscheduled": unscheduled,
+	})
+}
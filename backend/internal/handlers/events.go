@@ -0,0 +1,187 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/yourusername/health-connect/internal/auth"
+	"github.com/yourusername/health-connect/internal/realtime"
+)
+
+// keepAliveInterval keeps idle connections open through proxies. The
+// session is checked for revocation at the same interval.
+const keepAliveInterval = 25 * time.Second
+
+type EventsHandler struct {
+	DB  *gorm.DB
+	Hub *realtime.Hub
+}
+
+func NewEventsHandler(db *gorm.DB, hub *realtime.Hub) *EventsHandler {
+	return &EventsHandler{DB: db, Hub: hub}
+}
+
+// eventCursor reads the last event ID the client saw, from the
+// Last-Event-ID header EventSource sends on reconnect or ?cursor. It
+// reports false when the client sent neither.
+func eventCursor(c *gin.Context) (uint64, bool, error) {
+	cursor := c.GetHeader("Last-Event-ID")
+	if cursor == "" {
+		cursor = c.Query("cursor")
+	}
+	if cursor == "" {
+		return 0, false, nil
+	}
+	value, err := strconv.ParseUint(cursor, 10, 64)
+	return value, true, err
+}
+
+// IssueStreamTicket returns a short-lived ticket for opening the event
+// stream, so the access token never appears in a URL
+func (h *EventsHandler) IssueStreamTicket(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	ticket, err := auth.GenerateStreamTicket(principal.Claims)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to issue stream ticket",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"ticket":     ticket,
+		"expires_in": int(auth.StreamTicketTTL.Seconds()),
+	})
+}
+
+func writeEvent(c *gin.Context, event realtime.Event) {
+	if event.ID != 0 {
+		fmt.Fprintf(c.Writer, "id: %d\n", event.ID)
+	}
+	fmt.Fprintf(c.Writer, "event: %s\ndata: %s\n\n", event.Type, event.Data)
+}
+
+// StreamEvents streams the caller's events as server-sent events. Events
+// after the cursor are replayed first so nothing is missed on reconnect; a
+// new connection without a cursor only receives events from now on. The
+// stream closes when the session's access token expires or is revoked.
+func (h *EventsHandler) StreamEvents(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	cursor, resume, err := eventCursor(c)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid cursor",
+		})
+		return
+	}
+
+	// Subscribe before replaying so events published in between are not lost
+	events, cancel := h.Hub.Subscribe(principal.Role, principal.ID)
+	defer cancel()
+
+	if !resume {
+		cursor, err = h.Hub.Latest(principal.Role, principal.ID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to open event stream",
+			})
+			return
+		}
+	}
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no")
+	c.Status(http.StatusOK)
+
+	fmt.Fprint(c.Writer, "retry: 3000\n\n")
+	for {
+		missed, last, err := h.Hub.Replay(principal.Role, principal.ID, cursor)
+		if err != nil {
+			log.Printf("Failed to replay events for %s %s: %v", principal.Role, principal.ID, err)
+			return
+		}
+		for _, event := range missed {
+			writeEvent(c, event)
+		}
+		if last == 0 {
+			break
+		}
+		cursor = last
+	}
+	c.Writer.Flush()
+
+	keepAlive := time.NewTicker(keepAliveInterval)
+	defer keepAlive.Stop()
+
+	expiry := time.NewTimer(time.Until(principal.Claims.ExpiresAt.Time))
+	defer expiry.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-expiry.C:
+			// The client reconnects with a ticket from a refreshed token
+			return
+		case event, ok := <-events:
+			if !ok {
+				// Dropped for falling behind; the client reconnects with its cursor
+				return
+			}
+			if event.ID != 0 && event.ID <= cursor {
+				continue // Already sent during replay
+			}
+			writeEvent(c, event)
+			if event.ID != 0 {
+				cursor = event.ID
+			}
+			c.Writer.Flush()
+		case <-keepAlive.C:
+			revoked, err := auth.IsTokenRevoked(h.DB, principal.Claims.ID)
+			if err != nil {
+				log.Printf("Failed to check revocation for %s %s: %v", principal.Role, principal.ID, err)
+			}
+			if revoked {
+				return
+			}
+			fmt.Fprint(c.Writer, ": keep-alive\n\n")
+			c.Writer.Flush()
+		}
+	}
+}
+
+// SendTyping tells the others in a conversation that the caller is typing
+func (h *EventsHandler) SendTyping(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+	threadID := c.Param("id")
+
+	root, err := findThreadRoot(h.DB, threadID)
+	if err != nil || !canAccessThread(h.DB, principal, root) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Thread not found",
+		})
+		return
+	}
+
+	publishToThread(h.DB, h.Hub, root, principal, true, realtime.EventTyping, gin.H{
+		"thread_id":   threadID,
+		"sender_id":   principal.ID,
+		"sender_type": principal.Role,
+	})
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+	})
+}
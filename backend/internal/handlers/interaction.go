@@ -0,0 +1,256 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/yourusername/health-connect/internal/auth"
+	"github.com/yourusername/health-connect/internal/interactions"
+	"github.com/yourusername/health-connect/internal/models"
+)
+
+// InteractionWarning is an interaction found between a patient's
+// medications, with the physician's acknowledgment if they have reviewed it
+type InteractionWarning struct {
+	interactions.Warning
+	Blocking       bool                              `json:"blocking"` // Prescribing needs an override
+	Acknowledgment *models.InteractionAcknowledgment `json:"acknowledgment,omitempty"`
+}
+
+type AcknowledgeInteractionRequest struct {
+	MedicationID      string `json:"medication_id" binding:"required"`
+	OtherMedicationID string `json:"other_medication_id" binding:"required"`
+	Reason            string `json:"reason" binding:"required,max=500"`
+}
+
+// activeDrugs lists a patient's medications that are still being taken
+func activeDrugs(db *gorm.DB, patientID string) ([]interactions.Drug, error) {
+	var medications []models.Medication
+	if err := db.Where("patient_id = ?", patientID).Find(&medications).Error; err != nil {
+		return nil, err
+	}
+	now := time.Now()
+	var drugs []interactions.Drug
+	for _, medication := range medications {
+		if medication.Active(now) {
+			drugs = append(drugs, interactions.Drug{ID: medication.ID, Name: medication.Name})
+		}
+	}
+	return drugs, nil
+}
+
+// reviewInteractions pairs warnings with the patient's acknowledgments of them
+func (h *MedicationHandler) reviewInteractions(patientID string, warnings []interactions.Warning) ([]InteractionWarning, error) {
+	var acknowledgments []models.InteractionAcknowledgment
+	if err := h.DB.Where("patient_id = ?", patientID).Preload("AcknowledgedBy").Find(&acknowledgments).Error; err != nil {
+		return nil, err
+	}
+	byKey := make(map[[4]string]*models.InteractionAcknowledgment, len(acknowledgments))
+	for i := range acknowledgments {
+		byKey[acknowledgments[i].Key()] = &acknowledgments[i]
+	}
+
+	reviewed := make([]InteractionWarning, 0, len(warnings))
+	for _, warning := range warnings {
+		reviewed = append(reviewed, InteractionWarning{
+			Warning:        warning,
+			Blocking:       interactions.Blocks(warning.Severity),
+			Acknowledgment: byKey[models.InteractionKey(warning.MedicationID, warning.Ingredients[0], warning.OtherMedicationID, warning.Ingredients[1])],
+		})
+	}
+	return reviewed, nil
+}
+
+// unrecognizedDrugs lists the drugs whose names match no known drug, so
+// interactions with them cannot be checked
+func (h *MedicationHandler) unrecognizedDrugs(drugs []interactions.Drug) []interactions.Drug {
+	unrecognized := []interactions.Drug{}
+	for _, drug := range drugs {
+		if len(h.Interactions.Ingredients(drug.Name)) == 0 {
+			unrecognized = append(unrecognized, drug)
+		}
+	}
+	return unrecognized
+}
+
+// checkInteractions checks a medication being prescribed, renamed or made
+// active again against the patient's other active medications, returning
+// the interactions found and the medications that could not be checked.
+// Major and contraindicated interactions that have not been acknowledged
+// are refused with 409 unless the physician overrides them with a reason.
+func (h *MedicationHandler) checkInteractions(c *gin.Context, medication *models.Medication, req *MedicationRequest) ([]InteractionWarning, []interactions.Drug, bool) {
+	drugs, err := activeDrugs(h.DB, medication.PatientID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to check interactions",
+		})
+		return nil, nil, false
+	}
+	drug := interactions.Drug{ID: medication.ID, Name: medication.Name}
+	warnings, err := h.reviewInteractions(medication.PatientID, h.Interactions.Check(drug, drugs))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to check interactions",
+		})
+		return nil, nil, false
+	}
+
+	// The medication itself first, then the others it was checked against
+	var others []interactions.Drug
+	for _, other := range drugs {
+		if other.ID != medication.ID {
+			others = append(others, other)
+		}
+	}
+	unrecognized := h.unrecognizedDrugs(append([]interactions.Drug{drug}, others...))
+
+	if req.OverrideInteractions {
+		if strings.TrimSpace(req.OverrideReason) == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "override_reason is required to override interactions",
+			})
+			return nil, nil, false
+		}
+		return warnings, unrecognized, true
+	}
+	for _, warning := range warnings {
+		if warning.Blocking && warning.Acknowledgment == nil {
+			c.JSON(http.StatusConflict, gin.H{
+				"success":      false,
+				"message":      "This medication interacts with the patient's other medications. Review the interactions and set override_interactions with an override_reason to continue.",
+				"interactions": warnings,
+				"unrecognized": unrecognized,
+			})
+			return nil, nil, false
+		}
+	}
+	return warnings, unrecognized, true
+}
+
+// acknowledgeInteractions records the physician's acknowledgment of every
+// warning not yet acknowledged, as part of saving a medication
+func acknowledgeInteractions(tx *gorm.DB, medication *models.Medication, warnings []InteractionWarning, principal *auth.Principal, reason string) error {
+	for i := range warnings {
+		if warnings[i].Acknowledgment != nil {
+			continue
+		}
+		// Stored in key order so each pair has one row whichever side is acknowledged
+		key := models.InteractionKey(medication.ID, warnings[i].Ingredients[0], warnings[i].OtherMedicationID, warnings[i].Ingredients[1])
+		acknowledgment := models.InteractionAcknowledgment{
+			PatientID:         medication.PatientID,
+			MedicationID:      key[0],
+			Ingredient:        key[1],
+			OtherMedicationID: key[2],
+			OtherIngredient:   key[3],
+			Severity:          warnings[i].Severity,
+			Reason:            strings.TrimSpace(reason),
+			AcknowledgedByID:  principal.ID,
+		}
+		if err := tx.Create(&acknowledgment).Error; err != nil {
+			return err
+		}
+		warnings[i].MedicationID = medication.ID
+		warnings[i].Acknowledgment = &acknowledgment
+	}
+	return nil
+}
+
+// GetMedicationInteractions lists the interactions between a patient's
+// active medications, most serious first, and the medications whose names
+// were not recognized and so could not be checked
+func (h *MedicationHandler) GetMedicationInteractions(c *gin.Context) {
+	patientID := c.Param("id")
+
+	drugs, err := activeDrugs(h.DB, patientID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to check interactions",
+		})
+		return
+	}
+	warnings, err := h.reviewInteractions(patientID, h.Interactions.CheckAll(drugs))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to check interactions",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":      true,
+		"interactions": warnings,
+		"unrecognized": h.unrecognizedDrugs(drugs),
+	})
+}
+
+// AcknowledgeInteraction records that the physician reviewed the
+// interactions between two of a patient's active medications and chose to
+// continue both
+func (h *MedicationHandler) AcknowledgeInteraction(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+	patientID := c.Param("id")
+
+	var req AcknowledgeInteractionRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+
+	var medications []models.Medication
+	err := h.DB.Where("patient_id = ? AND id IN ?", patientID, []string{req.MedicationID, req.OtherMedicationID}).Find(&medications).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to acknowledge interaction",
+		})
+		return
+	}
+	now := time.Now()
+	if len(medications) != 2 || !medications[0].Active(now) || !medications[1].Active(now) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Medication not found",
+		})
+		return
+	}
+	medication, other := &medications[0], &medications[1]
+	if medication.ID != req.MedicationID {
+		medication, other = other, medication
+	}
+
+	drug := interactions.Drug{ID: medication.ID, Name: medication.Name}
+	warnings, err := h.reviewInteractions(patientID, h.Interactions.Check(drug, []interactions.Drug{{ID: other.ID, Name: other.Name}}))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to acknowledge interaction",
+		})
+		return
+	}
+	if len(warnings) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "These medications do not interact",
+		})
+		return
+	}
+
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		return acknowledgeInteractions(tx, medication, warnings, principal, req.Reason)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to acknowledge interaction",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":      true,
+		"interactions": warnings,
+	})
+}
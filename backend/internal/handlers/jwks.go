@@ -0,0 +1,18 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yourusername/health-connect/internal/auth"
+)
+
+// JWKS publishes the public keys used to sign access tokens so other
+// services can verify them
+func (h *AuthHandler) JWKS(c *gin.Context) {
+	c.Header("Cache-Control", "public, max-age=300")
+	c.JSON(http.StatusOK, gin.H{
+		"keys": auth.PublicJWKS(),
+	})
+}
@@ -0,0 +1,409 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+	"reflect"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/yourusername/health-connect/internal/auth"
+	"github.com/yourusername/health-connect/internal/dosing"
+	"github.com/yourusername/health-connect/internal/interactions"
+	"github.com/yourusername/health-connect/internal/models"
+	"github.com/yourusername/health-connect/internal/notify"
+)
+
+type MedicationHandler struct {
+	DB           *gorm.DB
+	Notifier     *notify.Dispatcher
+	Interactions *interactions.Checker
+}
+
+func NewMedicationHandler(db *gorm.DB, notifier *notify.Dispatcher, checker *interactions.Checker) *MedicationHandler {
+	return &MedicationHandler{DB: db, Notifier: notifier, Interactions: checker}
+}
+
+type MedicationRequest struct {
+	Name         string           `json:"name" binding:"required,max=200"`
+	Dosage       string           `json:"dosage" binding:"max=100"`
+	Frequency    string           `json:"frequency" binding:"max=100"`
+	Instructions string           `json:"instructions" binding:"max=2000"`
+	Schedule     *dosing.Schedule `json:"schedule"`                 // Read from dosage and frequency when omitted
+	StartDate    *time.Time       `json:"start_date"`               // Defaults to now when prescribing
+	EndDate      *time.Time       `json:"end_date"`                 // Optional planned end of the course
+	Reason       string           `json:"reason" binding:"max=500"` // Optional note recorded in the history
+
+	// Set to prescribe despite matching allergies or major or
+	// contraindicated interactions; override_reason is then required
+	OverrideAllergies    bool   `json:"override_allergies"`
+	OverrideInteractions bool   `json:"override_interactions"`
+	OverrideReason       string `json:"override_reason" binding:"max=500"`
+}
+
+type DiscontinueMedicationRequest struct {
+	Reason  string     `json:"reason" binding:"required,max=500"`
+	EndDate *time.Time `json:"end_date"` // Defaults to now
+}
+
+// bindMedication reads a medication request
+func bindMedication(c *gin.Context) (*MedicationRequest, bool) {
+	var req MedicationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request format: " + err.Error(),
+		})
+		return nil, false
+	}
+	if req.Schedule != nil {
+		if err := req.Schedule.Normalize(); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "Invalid schedule: " + err.Error(),
+			})
+			return nil, false
+		}
+	}
+	return &req, true
+}
+
+// applyTo copies a request onto a medication, reporting whether anything changed
+func (req *MedicationRequest) applyTo(medication *models.Medication) bool {
+	before := medication.Snapshot("", nil, "")
+
+	medication.Name = strings.TrimSpace(req.Name)
+	medication.Dosage = strings.TrimSpace(req.Dosage)
+	medication.Frequency = strings.TrimSpace(req.Frequency)
+	medication.Instructions = strings.TrimSpace(req.Instructions)
+	medication.Schedule = req.Schedule
+	if medication.Schedule == nil {
+		// Frequencies that cannot be read are kept as free text without a schedule
+		medication.Schedule, _ = dosing.ParseMedication(medication.Dosage, medication.Frequency)
+	}
+	if req.StartDate != nil {
+		medication.StartDate = *req.StartDate
+	}
+	medication.EndDate = req.EndDate
+
+	after := medication.Snapshot("", nil, "")
+	return before.Name != after.Name || before.Dosage != after.Dosage || before.Frequency != after.Frequency ||
+		before.Instructions != after.Instructions || !reflect.DeepEqual(before.Schedule, after.Schedule) ||
+		!before.StartDate.Equal(after.StartDate) || !sameTime(before.EndDate, after.EndDate)
+}
+
+// checkDates responds with 400 when a medication would end before it starts
+func checkDates(c *gin.Context, medication *models.Medication) bool {
+	if medication.EndDate != nil && !medication.EndDate.After(medication.StartDate) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "end_date must be after start_date",
+		})
+		return false
+	}
+	return true
+}
+
+func sameTime(a, b *time.Time) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+	return a.Equal(*b)
+}
+
+// errMedicationChanged means another change was saved since the medication
+// was loaded
+var errMedicationChanged = errors.New("medication changed")
+
+// saveVersion stores a medication change together with its history entry,
+// then runs each of then in the same transaction. It returns
+// errMedicationChanged if someone else saved a change first.
+func (h *MedicationHandler) saveVersion(medication *models.Medication, action string, principal *auth.Principal, reason string, then ...func(tx *gorm.DB) error) error {
+	return h.DB.Transaction(func(tx *gorm.DB) error {
+		if medication.ID == "" {
+			if err := tx.Create(medication).Error; err != nil {
+				return err
+			}
+		} else {
+			// Only save over the version this change was made to
+			result := tx.Model(medication).
+				Where("version = ?", medication.Version-1).
+				Select("*").
+				Updates(medication)
+			if result.Error != nil {
+				return result.Error
+			}
+			if result.RowsAffected == 0 {
+				return errMedicationChanged
+			}
+		}
+		version := medication.Snapshot(action, &principal.ID, strings.TrimSpace(reason))
+		if err := tx.Create(&version).Error; err != nil {
+			return err
+		}
+		for _, fn := range then {
+			if err := fn(tx); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+}
+
+// respondSaveError reports a failed saveVersion, with 409 when someone else
+// changed the medication first
+func (h *MedicationHandler) respondSaveError(c *gin.Context, err error, message string) {
+	if errors.Is(err, errMedicationChanged) {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": "This medication was updated by someone else; reload and try again",
+		})
+		return
+	}
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error": message,
+	})
+}
+
+// acknowledgeOverride records an override of the interactions found when
+// saving a medication, if the physician gave one
+func acknowledgeOverride(medication *models.Medication, warnings []InteractionWarning, principal *auth.Principal, req *MedicationRequest) func(tx *gorm.DB) error {
+	return func(tx *gorm.DB) error {
+		if !req.OverrideInteractions {
+			return nil
+		}
+		return acknowledgeInteractions(tx, medication, warnings, principal, req.OverrideReason)
+	}
+}
+
+// findMedication loads one of the patient's medications, responding with 404 if missing
+func (h *MedicationHandler) findMedication(c *gin.Context) (*models.Medication, bool) {
+	var medication models.Medication
+	err := h.DB.Where("id = ? AND patient_id = ?", c.Param("medicationId"), c.Param("id")).First(&medication).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Medication not found",
+		})
+		return nil, false
+	}
+	return &medication, true
+}
+
+// notifyPatient tells the patient their medication list changed
+func (h *MedicationHandler) notifyPatient(medication *models.Medication) {
+	h.Notifier.Notify(auth.RolePatient, medication.PatientID, notify.KindMedication, "/medications", false)
+}
+
+// PrescribeMedication adds a medication to a patient's list, prescribed by
+// the caller, after checking it against the patient's allergies and other
+// medications
+func (h *MedicationHandler) PrescribeMedication(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	req, ok := bindMedication(c)
+	if !ok {
+		return
+	}
+
+	medication := models.Medication{
+		PatientID:      c.Param("id"),
+		StartDate:      time.Now(),
+		PrescribedByID: &principal.ID,
+		Version:        1,
+	}
+	req.applyTo(&medication)
+	if !checkDates(c, &medication) {
+		return
+	}
+	conflicts, ok := h.checkAllergies(c, &medication, req)
+	if !ok {
+		return
+	}
+	warnings, unrecognized, ok := h.checkInteractions(c, &medication, req)
+	if !ok {
+		return
+	}
+
+	err := h.saveVersion(&medication, models.MedicationPrescribed, principal, req.Reason,
+		overrideAllergies(&medication, conflicts, principal, req),
+		acknowledgeOverride(&medication, warnings, principal, req))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to prescribe medication",
+		})
+		return
+	}
+	h.notifyPatient(&medication)
+	for i := range warnings {
+		warnings[i].MedicationID = medication.ID
+	}
+	for i := range unrecognized {
+		if unrecognized[i].ID == "" {
+			unrecognized[i].ID = medication.ID
+		}
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success":      true,
+		"medication":   medication,
+		"allergies":    conflicts,
+		"interactions": warnings,
+		"unrecognized": unrecognized,
+	})
+}
+
+// UpdateMedication changes a medication's details, checking allergies and
+// interactions again if it is renamed or made active again, such as by
+// extending an end date that has passed. Discontinued medications cannot be
+// changed; prescribe a new one instead.
+func (h *MedicationHandler) UpdateMedication(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	req, ok := bindMedication(c)
+	if !ok {
+		return
+	}
+	medication, ok := h.findMedication(c)
+	if !ok {
+		return
+	}
+	if medication.DiscontinuedReason != "" {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": "This medication has been discontinued",
+		})
+		return
+	}
+
+	now := time.Now()
+	name, wasActive := medication.Name, medication.Active(now)
+	if !req.applyTo(medication) {
+		c.JSON(http.StatusOK, gin.H{
+			"success":    true,
+			"medication": medication,
+		})
+		return
+	}
+	if !checkDates(c, medication) {
+		return
+	}
+	conflicts := []AllergyConflict{}
+	warnings := []InteractionWarning{}
+	unrecognized := []interactions.Drug{}
+	if medication.Name != name || !wasActive && medication.Active(now) {
+		if conflicts, ok = h.checkAllergies(c, medication, req); !ok {
+			return
+		}
+		if warnings, unrecognized, ok = h.checkInteractions(c, medication, req); !ok {
+			return
+		}
+	}
+
+	medication.Version++
+	err := h.saveVersion(medication, models.MedicationUpdated, principal, req.Reason,
+		overrideAllergies(medication, conflicts, principal, req),
+		acknowledgeOverride(medication, warnings, principal, req))
+	if err != nil {
+		h.respondSaveError(c, err, "Failed to update medication")
+		return
+	}
+	h.notifyPatient(medication)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":      true,
+		"medication":   medication,
+		"allergies":    conflicts,
+		"interactions": warnings,
+		"unrecognized": unrecognized,
+	})
+}
+
+// DiscontinueMedication stops a medication, recording why and when
+func (h *MedicationHandler) DiscontinueMedication(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	var req DiscontinueMedicationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+	reason := strings.TrimSpace(req.Reason)
+	if reason == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "reason is required",
+		})
+		return
+	}
+	medication, ok := h.findMedication(c)
+	if !ok {
+		return
+	}
+	if medication.DiscontinuedReason != "" {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": "This medication has already been discontinued",
+		})
+		return
+	}
+
+	endDate := time.Now()
+	if req.EndDate != nil {
+		endDate = *req.EndDate
+	}
+	if endDate.Before(medication.StartDate) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "end_date cannot be before the medication's start_date",
+		})
+		return
+	}
+
+	medication.EndDate = &endDate
+	medication.DiscontinuedReason = reason
+	medication.DiscontinuedByID = &principal.ID
+	medication.Version++
+	if err := h.saveVersion(medication, models.MedicationDiscontinued, principal, reason); err != nil {
+		h.respondSaveError(c, err, "Failed to discontinue medication")
+		return
+	}
+	h.notifyPatient(medication)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"medication": medication,
+	})
+}
+
+// GetMedicationHistory returns every version of a medication, oldest first
+func (h *MedicationHandler) GetMedicationHistory(c *gin.Context) {
+	medication, ok := h.findMedication(c)
+	if !ok {
+		return
+	}
+
+	var versions []models.MedicationVersion
+	result := h.DB.Where("medication_id = ?", medication.ID).
+		Preload("ChangedBy").
+		Order("version ASC").
+		Find(&versions)
+
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch medication history",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":    true,
+		"medication": medication,
+		"versions":   versions,
+	})
+}
@@ -0,0 +1,648 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/yourusername/health-connect/internal/auth"
+	"github.com/yourusername/health-connect/internal/models"
+	"github.com/yourusername/health-connect/internal/notify"
+	"github.com/yourusername/health-connect/internal/realtime"
+	"github.com/yourusername/health-connect/internal/triage"
+)
+
+type MessageHandler struct {
+	DB       *gorm.DB
+	Hub      *realtime.Hub
+	Notifier *notify.Dispatcher
+}
+
+func NewMessageHandler(db *gorm.DB, hub *realtime.Hub, notifier *notify.Dispatcher) *MessageHandler {
+	return &MessageHandler{DB: db, Hub: hub, Notifier: notifier}
+}
+
+type SendMessageRequest struct {
+	PatientID   string `json:"patient_id"`   // Required when a physician sends
+	PhysicianID string `json:"physician_id"` // Required when a patient sends
+	Subject     string `json:"subject" binding:"required,max=200"`
+	Content     string `json:"content" binding:"required,max=10000"`
+	Urgency     string `json:"urgency" binding:"omitempty,oneof=routine urgent emergency"`
+	Category    string `json:"category" binding:"omitempty,oneof=refill billing symptoms appointment other"`
+}
+
+type ReplyMessageRequest struct {
+	Subject  string `json:"subject" binding:"max=200"` // Optional; defaults to "Re: " and the thread subject
+	Content  string `json:"content" binding:"required,max=10000"`
+	Urgency  string `json:"urgency" binding:"omitempty,oneof=routine urgent emergency"`
+	Category string `json:"category" binding:"omitempty,oneof=refill billing symptoms appointment other"` // Optional; defaults to the parent's category
+}
+
+// isParticipant reports whether the caller is the patient or physician on a message
+func isParticipant(principal *auth.Principal, message *models.Message) bool {
+	switch {
+	case principal.IsPatient():
+		return message.PatientID != nil && *message.PatientID == principal.ID
+	case principal.IsPhysician():
+		return message.PhysicianID != nil && *message.PhysicianID == principal.ID
+	}
+	return false
+}
+
+// canAccessThread reports whether the caller takes part in a message's
+// conversation: as a current care-team member for group conversations,
+// otherwise directly or as the physician covering it
+func canAccessThread(db *gorm.DB, principal *auth.Principal, message *models.Message) bool {
+	conversation, err := models.FindConversation(db, message.ThreadID)
+	if err == nil {
+		_, err := models.FindParticipant(db, conversation.ID, principal.ID, principal.Role)
+		return err == nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("Failed to load conversation %s: %v", message.ThreadID, err)
+		return false
+	}
+
+	if isParticipant(principal, message) {
+		return true
+	}
+	if !principal.IsPhysician() {
+		return false
+	}
+	covering, err := models.IsCoveringThread(db, principal.ID, message.ThreadID)
+	if err != nil {
+		log.Printf("Failed to check coverage of thread %s: %v", message.ThreadID, err)
+	}
+	return covering
+}
+
+// sentByCaller reports whether the caller sent a message
+func sentByCaller(principal *auth.Principal, message *models.Message) bool {
+	if message.SenderType != principal.Role {
+		return false
+	}
+	if message.SentByID != nil {
+		return *message.SentByID == principal.ID
+	}
+	return isParticipant(principal, message)
+}
+
+// findThreadRoot loads the first message in a thread. A group
+// conversation's thread ID is the conversation's, not its first message's.
+func findThreadRoot(db *gorm.DB, threadID string) (*models.Message, error) {
+	var root models.Message
+	if err := db.Where("thread_id = ?", threadID).Order("sent_at ASC").First(&root).Error; err != nil {
+		return nil, err
+	}
+	return &root, nil
+}
+
+// threadParty is a patient or physician taking part in a conversation
+type threadParty struct {
+	Type string
+	ID   string
+}
+
+// threadParties returns everyone taking part in a message's conversation:
+// the current care team for group conversations, otherwise the patient,
+// the physician and any physician who sent the message for them
+func threadParties(db *gorm.DB, message *models.Message) ([]threadParty, error) {
+	conversation, err := models.FindConversation(db, message.ThreadID)
+	if err == nil {
+		participants, err := models.ActiveParticipants(db, conversation.ID)
+		if err != nil {
+			return nil, err
+		}
+		parties := make([]threadParty, len(participants))
+		for i, participant := range participants {
+			parties[i] = threadParty{Type: participant.ParticipantType, ID: participant.ParticipantID}
+		}
+		return parties, nil
+	}
+	if !errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, err
+	}
+
+	parties := []threadParty{
+		{Type: auth.RolePatient, ID: *message.PatientID},
+		{Type: auth.RolePhysician, ID: *message.PhysicianID},
+	}
+	if message.SentByID != nil && *message.SentByID != *message.PhysicianID {
+		parties = append(parties, threadParty{Type: auth.RolePhysician, ID: *message.SentByID})
+	}
+	return parties, nil
+}
+
+// messageRecipients returns who gets a receipt for a message: the rest of
+// the care team in a group conversation, otherwise the other participant.
+// Automatic replies only go to the patient.
+func messageRecipients(db *gorm.DB, message *models.Message) ([]threadParty, error) {
+	if message.SenderType == models.SenderSystem {
+		return []threadParty{{Type: auth.RolePatient, ID: *message.PatientID}}, nil
+	}
+
+	group, err := models.IsGroupThread(db, message.ThreadID)
+	if err != nil {
+		return nil, err
+	}
+	if group {
+		parties, err := threadParties(db, message)
+		if err != nil {
+			return nil, err
+		}
+		senderID := message.SenderID()
+		recipients := make([]threadParty, 0, len(parties))
+		for _, party := range parties {
+			if party.Type != message.SenderType || party.ID != senderID {
+				recipients = append(recipients, party)
+			}
+		}
+		return recipients, nil
+	}
+
+	recipientID, recipientType := message.Recipient()
+	return []threadParty{{Type: recipientType, ID: recipientID}}, nil
+}
+
+// publishToThread pushes an event to everyone in a message's conversation
+// except the caller, when one is given
+func publishToThread(db *gorm.DB, hub *realtime.Hub, message *models.Message, except *auth.Principal, transient bool, eventType string, data interface{}) {
+	parties, err := threadParties(db, message)
+	if err != nil {
+		log.Printf("Failed to find participants of thread %s: %v", message.ThreadID, err)
+		return
+	}
+	for _, party := range parties {
+		if except != nil && party.Type == except.Role && party.ID == except.ID {
+			continue
+		}
+		publish := hub.Publish
+		if transient {
+			publish = hub.PublishTransient
+		}
+		if err := publish(party.Type, party.ID, eventType, data); err != nil {
+			log.Printf("Failed to publish %s event to %s %s: %v", eventType, party.Type, party.ID, err)
+		}
+	}
+}
+
+// publish pushes an event to a participant. Delivery failures are logged
+// rather than failing the request that caused them.
+func (h *MessageHandler) publish(recipientType, recipientID, eventType string, data interface{}) {
+	if err := h.Hub.Publish(recipientType, recipientID, eventType, data); err != nil {
+		log.Printf("Failed to publish %s event to %s %s: %v", eventType, recipientType, recipientID, err)
+	}
+}
+
+// saveMessage stores a message with a receipt for each recipient and
+// pushes it to everyone in the conversation
+func (h *MessageHandler) saveMessage(message *models.Message) error {
+	message.SentAt = time.Now()
+	var recipients []threadParty
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(message).Error; err != nil {
+			return err
+		}
+
+		var err error
+		recipients, err = messageRecipients(tx, message)
+		if err != nil {
+			return err
+		}
+		for _, recipient := range recipients {
+			err := tx.Create(&models.MessageReceipt{
+				MessageID:     message.ID,
+				RecipientID:   recipient.ID,
+				RecipientType: recipient.Type,
+				ThreadID:      message.ThreadID,
+			}).Error
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// The sender gets the event too so their other sessions stay in sync
+	publishToThread(h.DB, h.Hub, message, nil, false, realtime.EventMessageCreated, message)
+
+	// Automatic replies answer a patient who is signed in, so need no notification
+	if message.SenderType != models.SenderSystem {
+		for _, recipient := range recipients {
+			h.notifyMessage(recipient.Type, recipient.ID, message)
+		}
+	}
+	return nil
+}
+
+// notifyMessage tells a recipient they have a new message. Emergencies
+// are sent straight away, even during quiet hours.
+func (h *MessageHandler) notifyMessage(recipientType, recipientID string, message *models.Message) {
+	h.Notifier.Notify(recipientType, recipientID, notify.KindMessage, "/threads/"+message.ThreadID, message.Urgency == triage.UrgencyEmergency)
+}
+
+// triageMessage sets the urgency and category of a patient's message from
+// what they chose and what the emergency rules find
+func triageMessage(message *models.Message) {
+	result := triage.Classify(message.Subject, message.Content, message.Urgency, message.Category)
+	message.Urgency = result.Urgency
+	message.Category = result.Category
+	message.UrgencyReason = strings.Join(result.Matched, ", ")
+}
+
+// sendSafetyReply answers a possible emergency with advice to call
+// emergency services, since messages are not monitored in real time
+func (h *MessageHandler) sendSafetyReply(message *models.Message) {
+	parentID := message.ID
+	reply := models.Message{
+		PatientID:   message.PatientID,
+		PhysicianID: message.PhysicianID,
+		Subject:     "If this is an emergency, call 911",
+		Content:     triage.SafetyReply,
+		SenderType:  models.SenderSystem,
+		Urgency:     triage.UrgencyEmergency,
+		Category:    message.Category,
+		ParentID:    &parentID,
+		ThreadID:    message.ThreadID,
+	}
+	if err := h.saveMessage(&reply); err != nil {
+		log.Printf("Failed to send safety reply to message %s: %v", message.ID, err)
+	}
+}
+
+// createMessage checks that the patient and physician are linked, then
+// sends the message. Membership of group conversations is checked by the caller.
+func (h *MessageHandler) createMessage(c *gin.Context, message *models.Message) {
+	group, err := models.IsGroupThread(h.DB, message.ThreadID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to verify recipient",
+		})
+		return
+	}
+
+	linked := group
+	if !group {
+		linked, err = models.IsPatientLinked(h.DB, *message.PatientID, *message.PhysicianID)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to verify recipient",
+			})
+			return
+		}
+	}
+	if !linked {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": "You can only message patients or physicians you are linked with",
+		})
+		return
+	}
+
+	if err := h.sendMessage(message, group); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to send message",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"message": message,
+	})
+}
+
+// sendMessage triages and saves a message, then sends any automatic replies
+func (h *MessageHandler) sendMessage(message *models.Message, group bool) error {
+	if group && message.SentByID != nil {
+		// Each physician in a group conversation sends as themselves
+		message.PhysicianID = message.SentByID
+		message.SentByID = nil
+	}
+
+	if message.SenderType == auth.RolePatient {
+		triageMessage(message)
+	} else if message.Urgency == "" {
+		message.Urgency = triage.UrgencyRoutine
+	}
+	if message.Category == "" {
+		message.Category = triage.CategoryOther
+	}
+
+	if err := h.saveMessage(message); err != nil {
+		return err
+	}
+
+	if message.SenderType == auth.RolePatient && message.Urgency == triage.UrgencyEmergency {
+		h.sendSafetyReply(message)
+	}
+	if group {
+		if err := h.DB.Model(&models.Conversation{}).Where("id = ?", message.ThreadID).Update("updated_at", message.SentAt).Error; err != nil {
+			log.Printf("Failed to update conversation %s: %v", message.ThreadID, err)
+		}
+	} else if message.SenderType == auth.RolePatient {
+		// The rest of a care team already sees group messages
+		h.applyOutOfOffice(message)
+	}
+	return nil
+}
+
+// SendMessage starts a new conversation with a linked patient or physician.
+// The sender is taken from the token, never from the request body.
+func (h *MessageHandler) SendMessage(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	var req SendMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+
+	message := models.Message{
+		Subject:    strings.TrimSpace(req.Subject),
+		Content:    req.Content,
+		SenderType: principal.Role,
+		Urgency:    req.Urgency,
+		Category:   req.Category,
+	}
+
+	switch {
+	case principal.IsPatient():
+		if req.PhysicianID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "physician_id is required",
+			})
+			return
+		}
+		message.PatientID = &principal.ID
+		message.PhysicianID = &req.PhysicianID
+	case principal.IsPhysician():
+		if req.PatientID == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "patient_id is required",
+			})
+			return
+		}
+		message.PatientID = &req.PatientID
+		message.PhysicianID = &principal.ID
+	}
+
+	h.createMessage(c, &message)
+}
+
+// newReply builds the caller's reply to a message in the same conversation.
+// An empty subject becomes "Re: " and the parent's subject.
+func newReply(principal *auth.Principal, parent *models.Message, subject, content string) models.Message {
+	subject = strings.TrimSpace(subject)
+	if subject == "" {
+		subject = parent.Subject
+		if !strings.HasPrefix(subject, "Re: ") {
+			subject = "Re: " + subject
+		}
+	}
+
+	parentID := parent.ID
+	message := models.Message{
+		PatientID:   parent.PatientID,
+		PhysicianID: parent.PhysicianID,
+		Subject:     subject,
+		Content:     content,
+		SenderType:  principal.Role,
+		Category:    parent.Category,
+		ParentID:    &parentID,
+		ThreadID:    parent.ThreadID,
+	}
+	if !isParticipant(principal, parent) {
+		// A covering or care-team physician replies on behalf of the
+		// patient's physician
+		message.SentByID = &principal.ID
+	}
+	return message
+}
+
+// ReplyToMessage adds a reply to the conversation containing a message
+func (h *MessageHandler) ReplyToMessage(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	var req ReplyMessageRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+
+	var parent models.Message
+	if err := h.DB.Where("id = ?", c.Param("id")).First(&parent).Error; err != nil || !canAccessThread(h.DB, principal, &parent) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Message not found",
+		})
+		return
+	}
+
+	message := newReply(principal, &parent, req.Subject, req.Content)
+	message.Urgency = req.Urgency
+	if req.Category != "" {
+		message.Category = req.Category
+	}
+
+	h.createMessage(c, &message)
+}
+
+// GetThread returns every message in a conversation, oldest first
+func (h *MessageHandler) GetThread(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	var messages []models.Message
+	result := h.DB.Where("thread_id = ?", c.Param("id")).
+		Preload("Patient").
+		Preload("Physician").
+		Preload("Receipts").
+		Preload("Attachments").
+		Order("sent_at ASC").
+		Find(&messages)
+
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch thread",
+		})
+		return
+	}
+
+	// Every message in a thread has the same participants
+	if len(messages) == 0 || !canAccessThread(h.DB, principal, &messages[0]) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Thread not found",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":   true,
+		"thread_id": c.Param("id"),
+		"messages":  messages,
+	})
+}
+
+// ThreadUnread is the number of unread messages in one conversation
+type ThreadUnread struct {
+	ThreadID string `json:"thread_id"`
+	Unread   int64  `json:"unread"`
+}
+
+// markRead stamps the caller's unread receipts matched by scope and flags
+// messages read once none of their recipients have them unread. It returns
+// the IDs of the messages it marked.
+func (h *MessageHandler) markRead(principal *auth.Principal, scope func(*gorm.DB) *gorm.DB) ([]string, error) {
+	var marked []string
+	readAt := time.Now()
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		var messageIDs []string
+		if err := scope(tx.Model(&models.MessageReceipt{})).
+			Where("recipient_id = ? AND recipient_type = ? AND read_at IS NULL", principal.ID, principal.Role).
+			Pluck("message_id", &messageIDs).Error; err != nil {
+			return err
+		}
+		if len(messageIDs) == 0 {
+			return nil
+		}
+
+		result := tx.Model(&models.MessageReceipt{}).
+			Where("message_id IN ? AND recipient_id = ? AND recipient_type = ? AND read_at IS NULL", messageIDs, principal.ID, principal.Role).
+			Update("read_at", readAt)
+		if result.Error != nil {
+			return result.Error
+		}
+		marked = messageIDs
+
+		return tx.Model(&models.Message{}).
+			Where("id IN ?", messageIDs).
+			Where("NOT EXISTS (SELECT 1 FROM message_receipts WHERE message_receipts.message_id = messages.id AND message_receipts.read_at IS NULL)").
+			Update("read", true).Error
+	})
+	if err != nil || len(marked) == 0 {
+		return marked, err
+	}
+
+	// Let the sender know their messages were read
+	var message models.Message
+	if err := h.DB.Where("id = ?", marked[0]).First(&message).Error; err != nil {
+		log.Printf("Failed to load message %s for read event: %v", marked[0], err)
+		return marked, nil
+	}
+	publishToThread(h.DB, h.Hub, &message, principal, false, realtime.EventMessageRead, gin.H{
+		"thread_id":   message.ThreadID,
+		"message_ids": marked,
+		"reader_id":   principal.ID,
+		"reader_type": principal.Role,
+		"read_at":     readAt,
+	})
+	return marked, nil
+}
+
+// MarkMessageRead records that the caller has read a message sent to them
+func (h *MessageHandler) MarkMessageRead(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+	messageID := c.Param("id")
+
+	var receipt models.MessageReceipt
+	err := h.DB.Where("message_id = ? AND recipient_id = ? AND recipient_type = ?", messageID, principal.ID, principal.Role).
+		First(&receipt).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Message not found",
+		})
+		return
+	}
+
+	if _, err := h.markRead(principal, func(db *gorm.DB) *gorm.DB {
+		return db.Where("message_id = ?", messageID)
+	}); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to mark message read",
+		})
+		return
+	}
+
+	h.DB.Where("id = ?", receipt.ID).First(&receipt)
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"receipt": receipt,
+	})
+}
+
+// MarkThreadRead records that the caller has read every message sent to
+// them in a conversation
+func (h *MessageHandler) MarkThreadRead(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+	threadID := c.Param("id")
+
+	root, err := findThreadRoot(h.DB, threadID)
+	if err != nil || !canAccessThread(h.DB, principal, root) {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Thread not found",
+		})
+		return
+	}
+
+	marked, err := h.markRead(principal, func(db *gorm.DB) *gorm.DB {
+		return db.Where("thread_id = ?", threadID)
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to mark thread read",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"marked":  len(marked),
+	})
+}
+
+// GetUnreadCounts returns the caller's unread message count per conversation and in total
+func (h *MessageHandler) GetUnreadCounts(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	threads := []ThreadUnread{}
+	result := h.DB.Model(&models.MessageReceipt{}).
+		Select("message_receipts.thread_id, COUNT(*) AS unread").
+		Joins("JOIN messages ON messages.id = message_receipts.message_id AND messages.deleted_at IS NULL").
+		Where("message_receipts.recipient_id = ? AND message_receipts.recipient_type = ? AND message_receipts.read_at IS NULL", principal.ID, principal.Role).
+		Group("message_receipts.thread_id").
+		Order("message_receipts.thread_id").
+		Scan(&threads)
+
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to count unread messages",
+		})
+		return
+	}
+
+	var total int64
+	for _, thread := range threads {
+		total += thread.Unread
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"total":   total,
+		"threads": threads,
+	})
+}
@@ -0,0 +1,331 @@
+package handlers
+
+import (
+	"log"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/yourusername/health-connect/internal/auth"
+	"github.com/yourusername/health-connect/internal/models"
+)
+
+const (
+	mfaIssuer         = "Health Connect"
+	recoveryCodeCount = 10
+)
+
+type MFACodeRequest struct {
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+type MFAVerifyRequest struct {
+	MFAToken     string `json:"mfa_token" binding:"required"`
+	Code         string `json:"code"`
+	RecoveryCode string `json:"recovery_code"`
+}
+
+// checkMFACode accepts either a fresh TOTP code or an unused recovery code.
+// Accepted codes are consumed so they cannot be replayed.
+func (h *AuthHandler) checkMFACode(enrollment *models.MFAEnrollment, code, recoveryCode string) (bool, error) {
+	if code != "" {
+		step, ok := auth.ValidateTOTP(enrollment.Secret, code, time.Now())
+		if !ok {
+			return false, nil
+		}
+		result := h.DB.Model(&models.MFAEnrollment{}).
+			Where("id = ? AND last_used_step < ?", enrollment.ID, step).
+			Update("last_used_step", step)
+		if result.Error != nil {
+			return false, result.Error
+		}
+		enrollment.LastUsedStep = step
+		return result.RowsAffected > 0, nil
+	}
+
+	if recoveryCode != "" {
+		result := h.DB.Model(&models.MFARecoveryCode{}).
+			Where("user_id = ? AND role = ? AND code_hash = ? AND used_at IS NULL",
+				enrollment.UserID, enrollment.Role, auth.HashRecoveryCode(recoveryCode)).
+			Update("used_at", time.Now())
+		if result.Error != nil {
+			return false, result.Error
+		}
+		return result.RowsAffected > 0, nil
+	}
+
+	return false, nil
+}
+
+// replaceRecoveryCodes discards any existing recovery codes and stores new ones
+func replaceRecoveryCodes(tx *gorm.DB, accountID, role string) ([]string, error) {
+	codes, err := auth.GenerateRecoveryCodes(recoveryCodeCount)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := tx.Where("user_id = ? AND role = ?", accountID, role).Delete(&models.MFARecoveryCode{}).Error; err != nil {
+		return nil, err
+	}
+	for _, code := range codes {
+		if err := tx.Create(&models.MFARecoveryCode{
+			UserID:   accountID,
+			Role:     role,
+			CodeHash: auth.HashRecoveryCode(code),
+		}).Error; err != nil {
+			return nil, err
+		}
+	}
+	return codes, nil
+}
+
+// EnrollMFA creates a new TOTP secret for the caller. MFA is not enforced
+// until the secret is confirmed with ConfirmMFA.
+func (h *AuthHandler) EnrollMFA(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	var enrollment models.MFAEnrollment
+	err := h.DB.Where("user_id = ? AND role = ?", principal.AccountID, principal.Role).First(&enrollment).Error
+	if err == nil && enrollment.Enabled {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": "MFA is already enabled",
+		})
+		return
+	}
+
+	secret, err := auth.GenerateTOTPSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to start MFA enrollment",
+		})
+		return
+	}
+
+	enrollment.UserID = principal.AccountID
+	enrollment.Role = principal.Role
+	enrollment.Secret = secret
+	enrollment.LastUsedStep = 0
+	if err := h.DB.Save(&enrollment).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to start MFA enrollment",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":          true,
+		"secret":           secret,
+		"provisioning_uri": auth.TOTPProvisioningURI(mfaIssuer, principal.Email, secret),
+		"message":          "Scan the QR code with your authenticator app, then confirm with a code",
+	})
+}
+
+// ConfirmMFA enables MFA after the caller proves their authenticator works
+// and returns recovery codes, which are only shown once
+func (h *AuthHandler) ConfirmMFA(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	var req MFACodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil || req.Code == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "A code from your authenticator app is required",
+		})
+		return
+	}
+
+	var enrollment models.MFAEnrollment
+	if err := h.DB.Where("user_id = ? AND role = ? AND enabled = ?", principal.AccountID, principal.Role, false).First(&enrollment).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "No pending MFA enrollment; start with /auth/mfa/enroll",
+		})
+		return
+	}
+
+	ok, err := h.checkMFACode(&enrollment, req.Code, "")
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to confirm MFA",
+		})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "Invalid code",
+		})
+		return
+	}
+
+	var codes []string
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		now := time.Now()
+		if err := tx.Model(&enrollment).Updates(map[string]interface{}{
+			"enabled":      true,
+			"confirmed_at": now,
+		}).Error; err != nil {
+			return err
+		}
+		codes, err = replaceRecoveryCodes(tx, principal.AccountID, principal.Role)
+		return err
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to confirm MFA",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":        true,
+		"recovery_codes": codes,
+		"message":        "MFA enabled. Store these recovery codes somewhere safe; they will not be shown again.",
+	})
+}
+
+// DisableMFA turns MFA off after checking a current code. Physicians cannot
+// disable it when it is mandatory.
+func (h *AuthHandler) DisableMFA(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	if principal.IsPhysician() && auth.MFARequiredForPhysicians() {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": "MFA is required for physicians",
+		})
+		return
+	}
+
+	var req MFACodeRequest
+	_ = c.ShouldBindJSON(&req)
+
+	var enrollment models.MFAEnrollment
+	if err := h.DB.Where("user_id = ? AND role = ? AND enabled = ?", principal.AccountID, principal.Role, true).First(&enrollment).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"success": false,
+			"message": "MFA is not enabled",
+		})
+		return
+	}
+
+	ok, err := h.checkMFACode(&enrollment, req.Code, req.RecoveryCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to disable MFA",
+		})
+		return
+	}
+	if !ok {
+		c.JSON(http.StatusUnauthorized, gin.H{
+			"success": false,
+			"message": "Invalid code",
+		})
+		return
+	}
+
+	err = h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(&enrollment).Error; err != nil {
+			return err
+		}
+		return tx.Where("user_id = ? AND role = ?", principal.AccountID, principal.Role).Delete(&models.MFARecoveryCode{}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to disable MFA",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "MFA disabled",
+	})
+}
+
+// VerifyMFA completes the second login step and issues session tokens
+func (h *AuthHandler) VerifyMFA(c *gin.Context) {
+	var req MFAVerifyRequest
+	if err := c.ShouldBindJSON(&req); err != nil || (req.Code == "" && req.RecoveryCode == "") {
+		c.JSON(http.StatusBadRequest, LoginResponse{
+			Success: false,
+			Message: "mfa_token and either code or recovery_code are required",
+		})
+		return
+	}
+
+	claims, err := auth.ParseMFAChallenge(req.MFAToken)
+	if err != nil {
+		c.JSON(http.StatusUnauthorized, LoginResponse{
+			Success: false,
+			Message: "MFA challenge expired; please sign in again",
+		})
+		return
+	}
+	revoked, err := auth.IsTokenRevoked(h.DB, claims.ID)
+	if err != nil || revoked {
+		c.JSON(http.StatusUnauthorized, LoginResponse{
+			Success: false,
+			Message: "MFA challenge expired; please sign in again",
+		})
+		return
+	}
+
+	if !h.loginAllowed(c, claims.Email) {
+		return
+	}
+
+	var enrollment models.MFAEnrollment
+	if err := h.DB.Where("user_id = ? AND role = ? AND enabled = ?", claims.Subject, claims.Role, true).First(&enrollment).Error; err != nil {
+		c.JSON(http.StatusUnauthorized, LoginResponse{
+			Success: false,
+			Message: "MFA challenge expired; please sign in again",
+		})
+		return
+	}
+
+	ok, err := h.checkMFACode(&enrollment, req.Code, req.RecoveryCode)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, LoginResponse{
+			Success: false,
+			Message: "Failed to verify code",
+		})
+		return
+	}
+	if !ok {
+		locked, err := auth.RecordMFAFailure(h.DB, claims, auth.IPThrottleKey(c.ClientIP()))
+		if err != nil {
+			log.Printf("Failed to record MFA failure: %v", err)
+		}
+		if locked {
+			h.sendLockoutEmail(claims.Email)
+		}
+		c.JSON(http.StatusUnauthorized, LoginResponse{
+			Success: false,
+			Message: "Invalid code",
+		})
+		return
+	}
+
+	// A challenge signs in once
+	if err := auth.RevokeMFAChallenge(h.DB, claims); err != nil {
+		c.JSON(http.StatusInternalServerError, LoginResponse{
+			Success: false,
+			Message: "Failed to verify code",
+		})
+		return
+	}
+
+	h.recordSuccessfulLogin(claims.Email)
+	h.respondWithTokens(c, claims.Subject, claims.Email, claims.Role)
+}
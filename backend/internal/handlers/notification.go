@@ -0,0 +1,218 @@
+package handlers
+
+import (
+	"net/http"
+	"regexp"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/yourusername/health-connect/internal/auth"
+	"github.com/yourusername/health-connect/internal/models"
+)
+
+type NotificationHandler struct {
+	DB *gorm.DB
+}
+
+func NewNotificationHandler(db *gorm.DB) *NotificationHandler {
+	return &NotificationHandler{DB: db}
+}
+
+type NotificationPreferenceRequest struct {
+	EmailEnabled    bool   `json:"email_enabled"`
+	SMSEnabled      bool   `json:"sms_enabled"`
+	InAppEnabled    bool   `json:"in_app_enabled"`
+	Phone           string `json:"phone"`
+	QuietHoursStart string `json:"quiet_hours_start"`
+	QuietHoursEnd   string `json:"quiet_hours_end"`
+	TimeZone        string `json:"time_zone"`
+	DigestMinutes   int    `json:"digest_minutes" binding:"min=0,max=1440"`
+}
+
+// e164 matches international phone numbers such as +15555550123
+var e164 = regexp.MustCompile(`^\+[1-9][0-9]{7,14}$`)
+
+const (
+	defaultNotificationLimit = 50
+	maxNotificationLimit     = 100
+)
+
+// GetNotifications lists the caller's in-app notifications, newest first.
+// Only unread ones are listed when unread=true.
+func (h *NotificationHandler) GetNotifications(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	limit := defaultNotificationLimit
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxNotificationLimit {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "limit must be between 1 and " + strconv.Itoa(maxNotificationLimit),
+			})
+			return
+		}
+		limit = n
+	}
+
+	mine := h.DB.Model(&models.Notification{}).Where("recipient_id = ? AND recipient_type = ?", principal.ID, principal.Role)
+
+	var unread int64
+	if err := mine.Session(&gorm.Session{}).Where("read_at IS NULL").Count(&unread).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch notifications",
+		})
+		return
+	}
+
+	query := mine.Session(&gorm.Session{})
+	if c.Query("unread") == "true" {
+		query = query.Where("read_at IS NULL")
+	}
+	var notifications []models.Notification
+	if err := query.Order("created_at DESC").Limit(limit).Find(&notifications).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch notifications",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":       true,
+		"notifications": notifications,
+		"unread":        unread,
+	})
+}
+
+// MarkNotificationRead marks one of the caller's notifications read
+func (h *NotificationHandler) MarkNotificationRead(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	var notification models.Notification
+	err := h.DB.Where("id = ? AND recipient_id = ? AND recipient_type = ?", c.Param("id"), principal.ID, principal.Role).
+		First(&notification).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Notification not found",
+		})
+		return
+	}
+
+	if notification.ReadAt == nil {
+		now := time.Now()
+		notification.ReadAt = &now
+		if err := h.DB.Model(&notification).Update("read_at", now).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{
+				"error": "Failed to mark notification read",
+			})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":      true,
+		"notification": notification,
+	})
+}
+
+// MarkAllNotificationsRead marks every one of the caller's notifications read
+func (h *NotificationHandler) MarkAllNotificationsRead(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	result := h.DB.Model(&models.Notification{}).
+		Where("recipient_id = ? AND recipient_type = ? AND read_at IS NULL", principal.ID, principal.Role).
+		Update("read_at", time.Now())
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to mark notifications read",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"marked":  result.RowsAffected,
+	})
+}
+
+// GetNotificationPreferences returns how the caller is notified
+func (h *NotificationHandler) GetNotificationPreferences(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	preference, err := models.FindNotificationPreference(h.DB, principal.Role, principal.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch notification preferences",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"preferences": preference,
+	})
+}
+
+// UpdateNotificationPreferences replaces how the caller is notified
+func (h *NotificationHandler) UpdateNotificationPreferences(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	var req NotificationPreferenceRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+
+	preference, err := models.FindNotificationPreference(h.DB, principal.Role, principal.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch notification preferences",
+		})
+		return
+	}
+	preference.EmailEnabled = req.EmailEnabled
+	preference.SMSEnabled = req.SMSEnabled
+	preference.InAppEnabled = req.InAppEnabled
+	preference.Phone = req.Phone
+	preference.QuietHoursStart = req.QuietHoursStart
+	preference.QuietHoursEnd = req.QuietHoursEnd
+	preference.TimeZone = req.TimeZone
+	preference.DigestMinutes = req.DigestMinutes
+	if preference.TimeZone == "" {
+		preference.TimeZone = "UTC"
+	}
+
+	message := ""
+	if err := preference.Validate(); err != nil {
+		message = err.Error()
+	} else if preference.Phone != "" && !e164.MatchString(preference.Phone) {
+		message = "phone must be in international format, e.g. +15555550123"
+	} else if preference.SMSEnabled && preference.Phone == "" {
+		message = "A phone number is required for SMS notifications"
+	}
+	if message != "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": message,
+		})
+		return
+	}
+
+	if err := h.DB.Save(preference).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update notification preferences",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":     true,
+		"preferences": preference,
+	})
+}
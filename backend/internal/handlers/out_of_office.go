@@ -0,0 +1,304 @@
+package handlers
+
+import (
+	"errors"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+	"gorm.io/gorm/clause"
+
+	"github.com/yourusername/health-connect/internal/auth"
+	"github.com/yourusername/health-connect/internal/models"
+	"github.com/yourusername/health-connect/internal/realtime"
+)
+
+type OutOfOfficeHandler struct {
+	DB *gorm.DB
+}
+
+func NewOutOfOfficeHandler(db *gorm.DB) *OutOfOfficeHandler {
+	return &OutOfOfficeHandler{DB: db}
+}
+
+type OutOfOfficeRequest struct {
+	StartsAt            time.Time `json:"starts_at" binding:"required"`
+	EndsAt              time.Time `json:"ends_at" binding:"required"`
+	AutoReply           string    `json:"auto_reply" binding:"required,max=2000"`
+	CoveringPhysicianID string    `json:"covering_physician_id"` // Optional
+}
+
+// validatePeriod checks an out-of-office request, returning a message for
+// the client when it is invalid. excludeID skips the period being edited
+// in the overlap check.
+func (h *OutOfOfficeHandler) validatePeriod(physicianID, excludeID string, req *OutOfOfficeRequest) (int, string) {
+	if !req.EndsAt.After(req.StartsAt) {
+		return http.StatusBadRequest, "ends_at must be after starts_at"
+	}
+	if !req.EndsAt.After(time.Now()) {
+		return http.StatusBadRequest, "ends_at must be in the future"
+	}
+
+	if req.CoveringPhysicianID != "" {
+		if req.CoveringPhysicianID == physicianID {
+			return http.StatusBadRequest, "You cannot cover for yourself"
+		}
+		var covering models.Physician
+		if err := h.DB.Where("id = ?", req.CoveringPhysicianID).First(&covering).Error; err != nil {
+			return http.StatusBadRequest, "Covering physician not found"
+		}
+		if covering.LicenseStatus != models.LicenseStatusApproved {
+			return http.StatusBadRequest, "Covering physician's license has not been approved"
+		}
+	}
+
+	var overlapping int64
+	err := h.DB.Model(&models.OutOfOffice{}).
+		Where("physician_id = ? AND id <> ? AND starts_at < ? AND ends_at > ?", physicianID, excludeID, req.EndsAt, req.StartsAt).
+		Count(&overlapping).Error
+	if err != nil {
+		return http.StatusInternalServerError, "Failed to check out-of-office periods"
+	}
+	if overlapping > 0 {
+		return http.StatusConflict, "This period overlaps another out-of-office period"
+	}
+	return 0, ""
+}
+
+// applyTo copies a validated request onto a period
+func (req *OutOfOfficeRequest) applyTo(period *models.OutOfOffice) {
+	period.StartsAt = req.StartsAt
+	period.EndsAt = req.EndsAt
+	period.AutoReply = strings.TrimSpace(req.AutoReply)
+	period.CoveringPhysicianID = nil
+	if req.CoveringPhysicianID != "" {
+		coveringID := req.CoveringPhysicianID
+		period.CoveringPhysicianID = &coveringID
+	}
+}
+
+// GetOutOfOffice lists a physician's current and upcoming out-of-office periods
+func (h *OutOfOfficeHandler) GetOutOfOffice(c *gin.Context) {
+	var periods []models.OutOfOffice
+	result := h.DB.Where("physician_id = ? AND ends_at > ?", c.Param("id"), time.Now()).
+		Preload("CoveringPhysician").
+		Order("starts_at ASC").
+		Find(&periods)
+
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch out-of-office periods",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"periods": periods,
+	})
+}
+
+// CreateOutOfOffice schedules an out-of-office period
+func (h *OutOfOfficeHandler) CreateOutOfOffice(c *gin.Context) {
+	physicianID := c.Param("id")
+
+	var req OutOfOfficeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+	if status, message := h.validatePeriod(physicianID, "", &req); status != 0 {
+		c.JSON(status, gin.H{
+			"success": false,
+			"message": message,
+		})
+		return
+	}
+
+	period := models.OutOfOffice{PhysicianID: physicianID}
+	req.applyTo(&period)
+	if err := h.DB.Create(&period).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create out-of-office period",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"period":  period,
+	})
+}
+
+// findPeriod loads one of the physician's periods, responding with 404 if missing
+func (h *OutOfOfficeHandler) findPeriod(c *gin.Context) (*models.OutOfOffice, bool) {
+	var period models.OutOfOffice
+	err := h.DB.Where("id = ? AND physician_id = ?", c.Param("periodId"), c.Param("id")).First(&period).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Out-of-office period not found",
+		})
+		return nil, false
+	}
+	return &period, true
+}
+
+// UpdateOutOfOffice changes an out-of-office period. Covering access to
+// conversations already delegated ends when the period does.
+func (h *OutOfOfficeHandler) UpdateOutOfOffice(c *gin.Context) {
+	period, ok := h.findPeriod(c)
+	if !ok {
+		return
+	}
+
+	var req OutOfOfficeRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+	if status, message := h.validatePeriod(period.PhysicianID, period.ID, &req); status != 0 {
+		c.JSON(status, gin.H{
+			"success": false,
+			"message": message,
+		})
+		return
+	}
+
+	req.applyTo(period)
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Save(period).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.ThreadCoverage{}).
+			Where("out_of_office_id = ?", period.ID).
+			Updates(map[string]interface{}{
+				"expires_at":            period.EndsAt,
+				"covering_physician_id": period.CoveringPhysicianID,
+			}).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update out-of-office period",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"period":  period,
+	})
+}
+
+// DeleteOutOfOffice cancels an out-of-office period and ends the covering
+// physician's access to conversations delegated during it
+func (h *OutOfOfficeHandler) DeleteOutOfOffice(c *gin.Context) {
+	period, ok := h.findPeriod(c)
+	if !ok {
+		return
+	}
+
+	err := h.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Delete(period).Error; err != nil {
+			return err
+		}
+		return tx.Model(&models.ThreadCoverage{}).
+			Where("out_of_office_id = ? AND expires_at > ?", period.ID, time.Now()).
+			Update("expires_at", time.Now()).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete out-of-office period",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Out-of-office period deleted",
+	})
+}
+
+// applyOutOfOffice handles a patient's message to a physician who is away:
+// the first message in a conversation during the period gets the
+// automatic reply, and every message is copied to the covering physician
+func (h *MessageHandler) applyOutOfOffice(message *models.Message) {
+	period, err := models.ActiveOutOfOffice(h.DB, *message.PhysicianID, message.SentAt)
+	if err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+		log.Printf("Failed to check out-of-office for physician %s: %v", *message.PhysicianID, err)
+		return
+	}
+
+	if period != nil {
+		coverage := models.ThreadCoverage{
+			ThreadID:            message.ThreadID,
+			OutOfOfficeID:       period.ID,
+			PhysicianID:         period.PhysicianID,
+			CoveringPhysicianID: period.CoveringPhysicianID,
+			ExpiresAt:           period.EndsAt,
+		}
+		result := h.DB.Clauses(clause.OnConflict{DoNothing: true}).Create(&coverage)
+		if result.Error != nil {
+			log.Printf("Failed to record coverage of thread %s: %v", message.ThreadID, result.Error)
+		} else if result.RowsAffected > 0 {
+			h.sendOutOfOfficeReply(message, period)
+		}
+	}
+
+	// Copy the message to every physician currently covering the conversation
+	var coveringIDs []string
+	err = h.DB.Model(&models.ThreadCoverage{}).
+		Where("thread_id = ? AND covering_physician_id IS NOT NULL AND expires_at > ?", message.ThreadID, message.SentAt).
+		Distinct().
+		Pluck("covering_physician_id", &coveringIDs).Error
+	if err != nil {
+		log.Printf("Failed to find covering physicians for thread %s: %v", message.ThreadID, err)
+		return
+	}
+	for _, coveringID := range coveringIDs {
+		receipt := models.MessageReceipt{
+			MessageID:     message.ID,
+			RecipientID:   coveringID,
+			RecipientType: auth.RolePhysician,
+			ThreadID:      message.ThreadID,
+		}
+		if err := h.DB.Create(&receipt).Error; err != nil {
+			log.Printf("Failed to copy message %s to covering physician %s: %v", message.ID, coveringID, err)
+			continue
+		}
+		h.publish(auth.RolePhysician, coveringID, realtime.EventMessageCreated, message)
+		h.notifyMessage(auth.RolePhysician, coveringID, message)
+	}
+}
+
+// sendOutOfOfficeReply answers a patient with the physician's auto-reply
+func (h *MessageHandler) sendOutOfOfficeReply(message *models.Message, period *models.OutOfOffice) {
+	content := period.AutoReply
+	if period.CoveringPhysician != nil {
+		content += "\n\nWhile I am away, " + period.CoveringPhysician.Name + " is covering my messages and can see this conversation."
+	}
+
+	parentID := message.ID
+	reply := models.Message{
+		PatientID:   message.PatientID,
+		PhysicianID: message.PhysicianID,
+		Subject:     "Out of office until " + period.EndsAt.Format("January 2, 2006"),
+		Content:     content,
+		SenderType:  models.SenderSystem,
+		Urgency:     message.Urgency,
+		Category:    message.Category,
+		ParentID:    &parentID,
+		ThreadID:    message.ThreadID,
+	}
+	if err := h.saveMessage(&reply); err != nil {
+		log.Printf("Failed to send out-of-office reply to message %s: %v", message.ID, err)
+	}
+}
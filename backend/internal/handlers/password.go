@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yourusername/health-connect/internal/auth"
+	"github.com/yourusername/health-connect/internal/mail"
+	"github.com/yourusername/health-connect/internal/models"
+)
+
+type ForgotPasswordRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+type ResetPasswordRequest struct {
+	Token    string `json:"token" binding:"required"`
+	Password string `json:"password" binding:"required,min=6"`
+}
+
+// AppBaseURL is the frontend origin used to build links sent by email
+func AppBaseURL() string {
+	if base := os.Getenv("APP_BASE_URL"); base != "" {
+		return base
+	}
+	return "http://localhost:5173"
+}
+
+// sendPasswordReset emails a reset link for the account
+func (h *AuthHandler) sendPasswordReset(account *models.Account) {
+	token, err := auth.CreatePasswordResetToken(h.DB, account.ID)
+	if err != nil {
+		log.Printf("Failed to create password reset token: %v", err)
+		return
+	}
+
+	link := fmt.Sprintf("%s/reset-password?token=%s", AppBaseURL(), url.QueryEscape(token))
+	err = h.Mailer.Send(mail.Message{
+		To:      account.Email,
+		Subject: "Reset your Health Connect password",
+		Body: fmt.Sprintf("We received a request to reset the password for your Health Connect account.\n\n"+
+			"Use the link below within %d minutes to choose a new password:\n%s\n\n"+
+			"If you did not request this, you can ignore this email.",
+			int(auth.PasswordResetTokenTTL.Minutes()), link),
+	})
+	if err != nil {
+		log.Printf("Failed to send password reset email: %v", err)
+	}
+}
+
+// ForgotPassword emails a password reset link. The response is the same
+// whether or not the account exists so emails cannot be enumerated.
+func (h *AuthHandler) ForgotPassword(c *gin.Context) {
+	var req ForgotPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+
+	var account models.Account
+	if err := h.DB.Where("email = ?", req.Email).First(&account).Error; err == nil {
+		h.sendPasswordReset(&account)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "If an account exists for that email, a reset link has been sent",
+	})
+}
+
+// ResetPassword sets a new password using a reset token and signs the user out everywhere
+func (h *AuthHandler) ResetPassword(c *gin.Context) {
+	var req ResetPasswordRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+
+	token, err := auth.ConsumePasswordResetToken(h.DB, req.Token)
+	if err != nil {
+		if errors.Is(err, auth.ErrInvalidResetToken) {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "Invalid or expired reset token",
+			})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to reset password",
+		})
+		return
+	}
+
+	hashedPassword, err := h.hashPassword(req.Password)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to process password",
+		})
+		return
+	}
+
+	if err := h.DB.Model(&models.Account{}).Where("id = ?", token.UserID).Update("password", hashedPassword).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to reset password",
+		})
+		return
+	}
+
+	if err := auth.RevokeAccountSessions(h.DB, token.UserID); err != nil {
+		log.Printf("Failed to revoke sessions after password reset: %v", err)
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Password has been reset. Please sign in again.",
+	})
+}
@@ -22,7 +22,7 @@ func (h *PatientHandler) GetPatientMedications(c *gin.Context) {
 	patientID := c.Param("id")
 
 	var medications []models.Medication
-	result := h.DB.Where("patient_id = ?", patientID).Find(&medications)
+	result := h.DB.Where("patient_id = ?", patientID).Preload("PrescribedBy").Find(&medications)
 
 	if result.Error != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{
@@ -44,6 +44,7 @@ func (h *PatientHandler) GetPatientMessages(c *gin.Context) {
 	var messages []models.Message
 	result := h.DB.Where("patient_id = ?", patientID).
 		Preload("Physician").
+		Preload("Attachments").
 		Order("sent_at DESC").
 		Find(&messages)
 
@@ -65,7 +66,7 @@ func (h *PatientHandler) GetPatientPhysicians(c *gin.Context) {
 	patientID := c.Param("id")
 
 	var patient models.Patient
-	result := h.DB.Preload("Physicians").Preload("Physicians.Specialties").First(&patient, patientID)
+	result := h.DB.Preload("Physicians").Preload("Physicians.Specialties").Where("id = ?", patientID).First(&patient)
 
 	if result.Error != nil {
 		c.JSON(http.StatusNotFound, gin.H{
@@ -2,11 +2,13 @@ package handlers
 
 import (
 	"net/http"
+	"strconv"
 
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 
 	"github.com/yourusername/health-connect/internal/models"
+	"github.com/yourusername/health-connect/internal/triage"
 )
 
 type PhysicianHandler struct {
@@ -22,7 +24,7 @@ func (h *PhysicianHandler) GetPhysicianPatients(c *gin.Context) {
 	physicianID := c.Param("id")
 
 	var physician models.Physician
-	result := h.DB.Preload("Patients").First(&physician, physicianID)
+	result := h.DB.Preload("Patients").Where("id = ?", physicianID).First(&physician)
 
 	if result.Error != nil {
 		c.JSON(http.StatusNotFound, gin.H{
@@ -42,8 +44,9 @@ func (h *PhysicianHandler) GetPhysicianMessages(c *gin.Context) {
 	physicianID := c.Param("id")
 
 	var messages []models.Message
-	result := h.DB.Where("physician_id = ?", physicianID).
+	result := h.DB.Scopes(models.VisibleToPhysician(physicianID)).
 		Preload("Patient").
+		Preload("Attachments").
 		Order("sent_at DESC").
 		Find(&messages)
 
@@ -60,6 +63,102 @@ func (h *PhysicianHandler) GetPhysicianMessages(c *gin.Context) {
 	})
 }
 
+// inboxPriority ranks messages for the inbox, most urgent first
+const inboxPriority = "CASE messages.urgency WHEN 'emergency' THEN 2 WHEN 'urgent' THEN 1 ELSE 0 END DESC"
+
+// GetPhysicianInbox lists messages patients sent to a physician, and to
+// colleagues they are covering for, most urgent first and longest waiting
+// first within each urgency. Only unread messages are listed unless
+// status=all.
+func (h *PhysicianHandler) GetPhysicianInbox(c *gin.Context) {
+	physicianID := c.Param("id")
+
+	limit, offset := 50, 0
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > 100 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "limit must be between 1 and 100",
+			})
+			return
+		}
+		limit = n
+	}
+	if value := c.Query("offset"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "offset must be a non-negative number",
+			})
+			return
+		}
+		offset = n
+	}
+
+	db := h.DB.Model(&models.Message{}).
+		Joins("JOIN message_receipts ON message_receipts.message_id = messages.id AND message_receipts.recipient_id = ? AND message_receipts.recipient_type = ?", physicianID, "physician")
+
+	switch c.DefaultQuery("status", "unread") {
+	case "unread":
+		db = db.Where("message_receipts.read_at IS NULL")
+	case "all":
+	default:
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "status must be unread or all",
+		})
+		return
+	}
+
+	if category := c.Query("category"); category != "" {
+		db = db.Where("messages.category = ?", category)
+	}
+
+	// Counts per urgency for inbox badges, before the urgency filter
+	counts := map[string]int64{triage.UrgencyEmergency: 0, triage.UrgencyUrgent: 0, triage.UrgencyRoutine: 0}
+	var rows []struct {
+		Urgency string
+		Count   int64
+	}
+	if err := db.Session(&gorm.Session{}).Select("messages.urgency, COUNT(*) AS count").Group("messages.urgency").Scan(&rows).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch inbox",
+		})
+		return
+	}
+	for _, row := range rows {
+		counts[row.Urgency] += row.Count
+	}
+
+	if urgency := c.Query("urgency"); urgency != "" {
+		db = db.Where("messages.urgency = ?", urgency)
+	}
+
+	var messages []models.Message
+	result := db.Preload("Patient").
+		Preload("Attachments").
+		Order(inboxPriority).
+		Order("messages.sent_at ASC").
+		Limit(limit).
+		Offset(offset).
+		Find(&messages)
+
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch inbox",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"counts":   counts,
+		"messages": messages,
+	})
+}
+
 // GetSpecialties gets all available medical specialties
 func (h *PhysicianHandler) GetSpecialties(c *gin.Context) {
 	var specialties []models.Specialty
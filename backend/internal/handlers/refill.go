@@ -0,0 +1,402 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/yourusername/health-connect/internal/auth"
+	"github.com/yourusername/health-connect/internal/models"
+	"github.com/yourusername/health-connect/internal/notify"
+	"github.com/yourusername/health-connect/internal/triage"
+)
+
+type RefillHandler struct {
+	DB       *gorm.DB
+	Messages *MessageHandler
+}
+
+func NewRefillHandler(db *gorm.DB, messages *MessageHandler) *RefillHandler {
+	return &RefillHandler{DB: db, Messages: messages}
+}
+
+type CreateRefillRequest struct {
+	PhysicianID string `json:"physician_id"` // Defaults to the prescriber
+	Pharmacy    string `json:"pharmacy" binding:"max=200"`
+	Note        string `json:"note" binding:"max=2000"`
+}
+
+type ReviewRefillRequest struct {
+	Note     string `json:"note" binding:"max=2000"`    // Required to deny; not used when sending to the pharmacy
+	Pharmacy string `json:"pharmacy" binding:"max=200"` // Replaces the patient's choice when sending
+}
+
+// medicationLabel names a medication in refill messages, e.g. "Lisinopril 10mg"
+func medicationLabel(medication *models.Medication) string {
+	return strings.TrimSpace(medication.Name + " " + medication.Dosage)
+}
+
+// refillStatusFilter reads the optional status query parameter, responding
+// with 400 if it is not a refill request state
+func refillStatusFilter(c *gin.Context) (string, bool) {
+	status := c.Query("status")
+	if status != "" && !models.IsRefillStatus(status) {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "status must be requested, approved, denied or sent_to_pharmacy",
+		})
+		return "", false
+	}
+	return status, true
+}
+
+// RequestRefill asks one of the patient's physicians to refill an active
+// medication, and messages them about it. The prescriber is asked unless
+// the patient chooses another linked physician.
+func (h *RefillHandler) RequestRefill(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	var req CreateRefillRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+
+	var medication models.Medication
+	if err := h.DB.Where("id = ? AND patient_id = ?", c.Param("medicationId"), principal.ID).First(&medication).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Medication not found",
+		})
+		return
+	}
+	if !medication.Active(time.Now()) {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": "Only active medications can be refilled",
+		})
+		return
+	}
+
+	physicianID := req.PhysicianID
+	if physicianID == "" && medication.PrescribedByID != nil {
+		physicianID = *medication.PrescribedByID
+	}
+	if physicianID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "physician_id is required",
+		})
+		return
+	}
+	linked, err := models.IsPatientLinked(h.DB, principal.ID, physicianID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to verify physician",
+		})
+		return
+	}
+	if !linked {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": "You can only request refills from physicians you are linked with",
+		})
+		return
+	}
+
+	var open int64
+	err = h.DB.Model(&models.RefillRequest{}).
+		Where("medication_id = ? AND status IN ?", medication.ID, models.OpenRefillStates).
+		Count(&open).Error
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to request refill",
+		})
+		return
+	}
+	if open > 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": "A refill of this medication is already in progress",
+		})
+		return
+	}
+
+	// The request's conversation starts with the message sent below
+	message := models.Message{
+		ID:          uuid.New().String(),
+		PatientID:   &principal.ID,
+		PhysicianID: &physicianID,
+		Subject:     "Refill request: " + medicationLabel(&medication),
+		SenderType:  principal.Role,
+		Category:    triage.CategoryRefill,
+	}
+	refill := models.RefillRequest{
+		PatientID:    principal.ID,
+		MedicationID: medication.ID,
+		PhysicianID:  physicianID,
+		Status:       models.RefillRequested,
+		Pharmacy:     strings.TrimSpace(req.Pharmacy),
+		PatientNote:  strings.TrimSpace(req.Note),
+		ThreadID:     message.ID,
+	}
+	// The open refill index catches a request made at the same time
+	if err := h.DB.Create(&refill).Error; errors.Is(err, gorm.ErrDuplicatedKey) {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": "A refill of this medication is already in progress",
+		})
+		return
+	} else if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to request refill",
+		})
+		return
+	}
+
+	message.Content = refillMessage(&refill, &medication)
+	if err := h.Messages.sendMessage(&message, false); err != nil {
+		h.DB.Delete(&refill)
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to send message",
+		})
+		return
+	}
+	h.Messages.Notifier.Notify(auth.RolePhysician, physicianID, notify.KindRefillReview, "/refills", false)
+
+	refill.Medication = &medication
+	c.JSON(http.StatusCreated, gin.H{
+		"success": true,
+		"refill":  refill,
+		"message": message,
+	})
+}
+
+// refillMessage is what is posted to a refill request's conversation when
+// it reaches its current state
+func refillMessage(refill *models.RefillRequest, medication *models.Medication) string {
+	label := medicationLabel(medication)
+
+	var text string
+	switch refill.Status {
+	case models.RefillRequested:
+		text = "Please refill my " + label + "."
+		if refill.Pharmacy != "" {
+			text += "\n\nPharmacy: " + refill.Pharmacy
+		}
+		if refill.PatientNote != "" {
+			text += "\n\n" + refill.PatientNote
+		}
+		return text
+	case models.RefillApproved:
+		text = "Your refill of " + label + " has been approved."
+	case models.RefillDenied:
+		text = "Your refill request for " + label + " was not approved."
+	case models.RefillSentToPharmacy:
+		pharmacy := refill.Pharmacy
+		if pharmacy == "" {
+			pharmacy = "your pharmacy"
+		}
+		text = fmt.Sprintf("Your refill of %s has been sent to %s.", label, pharmacy)
+	}
+	if refill.PhysicianNote != "" && refill.Status != models.RefillSentToPharmacy {
+		text += "\n\n" + refill.PhysicianNote
+	}
+	return text
+}
+
+// GetPatientRefills lists a patient's refill requests, newest first,
+// optionally only those in one state
+func (h *RefillHandler) GetPatientRefills(c *gin.Context) {
+	status, ok := refillStatusFilter(c)
+	if !ok {
+		return
+	}
+
+	query := h.DB.Where("patient_id = ?", c.Param("id"))
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var refills []models.RefillRequest
+	result := query.Preload("Medication").Preload("Physician").Order("created_at DESC").Find(&refills)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch refill requests",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"refills": refills,
+	})
+}
+
+// GetPhysicianRefills lists the refill requests a physician was asked to
+// review, oldest first so the longest waiting are at the top
+func (h *RefillHandler) GetPhysicianRefills(c *gin.Context) {
+	status, ok := refillStatusFilter(c)
+	if !ok {
+		return
+	}
+
+	query := h.DB.Where("physician_id = ?", c.Param("id"))
+	if status != "" {
+		query = query.Where("status = ?", status)
+	}
+
+	var refills []models.RefillRequest
+	result := query.Preload("Medication").Preload("Patient").Order("created_at ASC").Find(&refills)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch refill requests",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"refills": refills,
+	})
+}
+
+// ApproveRefill approves a requested refill
+func (h *RefillHandler) ApproveRefill(c *gin.Context) {
+	h.reviewRefill(c, models.RefillApproved)
+}
+
+// DenyRefill turns down a requested refill; the note tells the patient why
+func (h *RefillHandler) DenyRefill(c *gin.Context) {
+	h.reviewRefill(c, models.RefillDenied)
+}
+
+// SendRefillToPharmacy records that an approved refill was sent to the pharmacy
+func (h *RefillHandler) SendRefillToPharmacy(c *gin.Context) {
+	h.reviewRefill(c, models.RefillSentToPharmacy)
+}
+
+// reviewRefill moves one of the patient's refill requests to a new state,
+// then messages and notifies the patient
+func (h *RefillHandler) reviewRefill(c *gin.Context, status string) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	var req ReviewRefillRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+	note := strings.TrimSpace(req.Note)
+	if status == models.RefillDenied && note == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "A note explaining the denial is required",
+		})
+		return
+	}
+
+	var refill models.RefillRequest
+	err := h.DB.Where("id = ? AND patient_id = ?", c.Param("refillId"), c.Param("id")).
+		Preload("Medication").
+		First(&refill).Error
+	if err != nil || refill.Medication == nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Refill request not found",
+		})
+		return
+	}
+	if refill.PhysicianID != principal.ID {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": "This refill request was sent to another physician",
+		})
+		return
+	}
+	if !refill.CanMoveTo(status) {
+		message := "Refill request has already been " + strings.ReplaceAll(refill.Status, "_", " ")
+		if refill.Status == models.RefillRequested {
+			message = "Only approved refills can be sent to the pharmacy"
+		}
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": message,
+		})
+		return
+	}
+	if status == models.RefillApproved && !refill.Medication.Active(time.Now()) {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": "This medication is no longer active",
+		})
+		return
+	}
+
+	now := time.Now()
+	previous := refill.Status
+	refill.Status = status
+	if status == models.RefillSentToPharmacy {
+		refill.SentAt = &now
+		if pharmacy := strings.TrimSpace(req.Pharmacy); pharmacy != "" {
+			refill.Pharmacy = pharmacy
+		}
+	} else {
+		refill.PhysicianNote = note
+		refill.ReviewedByID = &principal.ID
+		refill.ReviewedAt = &now
+	}
+	// Only move the request on if nobody else reviewed it in the meantime
+	result := h.DB.Model(&refill).
+		Where("status = ?", previous).
+		Select("Status", "Pharmacy", "PhysicianNote", "ReviewedByID", "ReviewedAt", "SentAt", "UpdatedAt").
+		Updates(&refill)
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update refill request",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusConflict, gin.H{
+			"success": false,
+			"message": "Refill request was updated by someone else; reload and try again",
+		})
+		return
+	}
+
+	h.postRefillUpdate(principal, &refill)
+	h.Messages.Notifier.Notify(auth.RolePatient, refill.PatientID, notify.KindRefill, "/refills", false)
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"refill":  refill,
+	})
+}
+
+// postRefillUpdate replies in the refill request's conversation with its
+// new state. Failures are logged, since the review itself has been saved.
+func (h *RefillHandler) postRefillUpdate(principal *auth.Principal, refill *models.RefillRequest) {
+	var parent models.Message
+	if err := h.DB.Where("thread_id = ?", refill.ThreadID).Order("sent_at DESC").First(&parent).Error; err != nil {
+		log.Printf("Failed to find conversation for refill request %s: %v", refill.ID, err)
+		return
+	}
+
+	subject := "Re: Refill request: " + medicationLabel(refill.Medication)
+	message := newReply(principal, &parent, subject, refillMessage(refill, refill.Medication))
+	message.Category = triage.CategoryRefill
+	if err := h.Messages.sendMessage(&message, false); err != nil {
+		log.Printf("Failed to message patient about refill request %s: %v", refill.ID, err)
+	}
+}
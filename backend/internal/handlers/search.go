@@ -0,0 +1,248 @@
+package handlers
+
+import (
+	"html"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/yourusername/health-connect/internal/auth"
+	"github.com/yourusername/health-connect/internal/models"
+)
+
+const (
+	defaultSearchLimit = 20
+	maxSearchLimit     = 100
+)
+
+// Highlight markers are control characters that cannot appear in stored
+// text, so the surrounding text can be escaped before they become <mark> tags
+const (
+	highlightStart = "\x02"
+	highlightEnd   = "\x03"
+)
+
+// SearchResult is one matching message with its highlighted text
+type SearchResult struct {
+	Message models.Message `json:"message"`
+	Subject string         `json:"subject"` // Subject with matches in <mark>, HTML-escaped
+	Snippet string         `json:"snippet"` // Excerpt of the content around matches, HTML-escaped
+}
+
+// searchTerm keeps the letters and digits of a word and the characters
+// that join words, so user input cannot inject FTS5 operators
+func searchTerm(word string) string {
+	return strings.TrimSpace(strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '\'' || r == '-' || r == '.' {
+			return r
+		}
+		return ' '
+	}, word))
+}
+
+// ftsQuery turns a search box query into an FTS5 query. "Quoted text" is
+// matched as a phrase, a trailing * matches by prefix, and every term must
+// match. It returns "" when nothing searchable is left.
+func ftsQuery(input string) string {
+	var terms []string
+	for i, part := range strings.Split(input, `"`) {
+		if i%2 == 1 {
+			// Inside quotes: a phrase
+			if phrase := strings.Join(strings.Fields(searchTerm(part)), " "); phrase != "" {
+				terms = append(terms, `"`+phrase+`"`)
+			}
+			continue
+		}
+		for _, word := range strings.Fields(part) {
+			prefix := strings.HasSuffix(word, "*")
+			for _, term := range strings.Fields(searchTerm(word)) {
+				terms = append(terms, `"`+term+`"`)
+			}
+			if prefix && len(terms) > 0 {
+				terms[len(terms)-1] += "*"
+			}
+		}
+	}
+	return strings.Join(terms, " ")
+}
+
+// parseSearchTime accepts a date (YYYY-MM-DD) or an RFC 3339 timestamp. A
+// date used as an upper bound covers the whole day.
+func parseSearchTime(value string, endOfDay bool) (time.Time, error) {
+	if t, err := time.Parse("2006-01-02", value); err == nil {
+		if endOfDay {
+			t = t.AddDate(0, 0, 1).Add(-time.Nanosecond)
+		}
+		return t, nil
+	}
+	return time.Parse(time.RFC3339, value)
+}
+
+// highlighted escapes text for HTML and turns the match markers into <mark> tags
+func highlighted(text string) string {
+	text = html.EscapeString(text)
+	text = strings.ReplaceAll(text, highlightStart, "<mark>")
+	return strings.ReplaceAll(text, highlightEnd, "</mark>")
+}
+
+func searchParamError(c *gin.Context, message string) {
+	c.JSON(http.StatusBadRequest, gin.H{
+		"success": false,
+		"message": message,
+	})
+}
+
+// SearchMessages finds the caller's messages matching a full-text query,
+// best matches first unless sort=date is given
+func (h *MessageHandler) SearchMessages(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	query := ftsQuery(c.Query("q"))
+	if query == "" {
+		searchParamError(c, "q is required")
+		return
+	}
+
+	limit, offset := defaultSearchLimit, 0
+	if value := c.Query("limit"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 || n > maxSearchLimit {
+			searchParamError(c, "limit must be between 1 and "+strconv.Itoa(maxSearchLimit))
+			return
+		}
+		limit = n
+	}
+	if value := c.Query("offset"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 0 {
+			searchParamError(c, "offset must be a non-negative number")
+			return
+		}
+		offset = n
+	}
+
+	db := h.DB.Table(models.MessageSearchTable).
+		Joins("JOIN messages ON messages.id = messages_fts.message_id AND messages.deleted_at IS NULL").
+		Where("messages_fts MATCH ?", query)
+
+	// Only messages in conversations the caller can open, including those a
+	// physician covers for an absent colleague
+	if principal.IsPatient() {
+		db = db.Where("messages.patient_id = ?", principal.ID)
+	} else {
+		db = db.Scopes(models.AccessibleToPhysician(principal.ID))
+	}
+
+	if value := c.Query("from"); value != "" {
+		from, err := parseSearchTime(value, false)
+		if err != nil {
+			searchParamError(c, "from must be a date (YYYY-MM-DD) or RFC 3339 time")
+			return
+		}
+		db = db.Where("messages.sent_at >= ?", from)
+	}
+	if value := c.Query("to"); value != "" {
+		to, err := parseSearchTime(value, true)
+		if err != nil {
+			searchParamError(c, "to must be a date (YYYY-MM-DD) or RFC 3339 time")
+			return
+		}
+		db = db.Where("messages.sent_at <= ?", to)
+	}
+
+	switch sender := c.Query("sender"); sender {
+	case "":
+	case auth.RolePatient, auth.RolePhysician:
+		db = db.Where("messages.sender_type = ?", sender)
+	default:
+		searchParamError(c, "sender must be patient or physician")
+		return
+	}
+	if senderID := c.Query("sender_id"); senderID != "" {
+		db = db.Where("(messages.sender_type = ? AND messages.patient_id = ?) OR (messages.sender_type = ? AND messages.physician_id = ?)",
+			auth.RolePatient, senderID, auth.RolePhysician, senderID)
+	}
+
+	order := "bm25(messages_fts)"
+	switch c.Query("sort") {
+	case "", "relevance":
+	case "date":
+		order = "messages.sent_at DESC"
+	default:
+		searchParamError(c, "sort must be relevance or date")
+		return
+	}
+
+	var total int64
+	if err := db.Session(&gorm.Session{}).Count(&total).Error; err != nil {
+		h.searchFailed(c, err)
+		return
+	}
+
+	var matches []struct {
+		MessageID string
+		Subject   string
+		Snippet   string
+	}
+	err := db.Select("messages_fts.message_id AS message_id, highlight(messages_fts, 1, ?, ?) AS subject, snippet(messages_fts, 2, ?, ?, '…', 16) AS snippet",
+		highlightStart, highlightEnd, highlightStart, highlightEnd).
+		Order(order).
+		Limit(limit).
+		Offset(offset).
+		Scan(&matches).Error
+	if err != nil {
+		h.searchFailed(c, err)
+		return
+	}
+
+	ids := make([]string, len(matches))
+	for i, match := range matches {
+		ids[i] = match.MessageID
+	}
+	var messages []models.Message
+	if err := h.DB.Where("id IN ?", ids).Preload("Patient").Preload("Physician").Preload("Attachments").Find(&messages).Error; err != nil {
+		h.searchFailed(c, err)
+		return
+	}
+	byID := make(map[string]models.Message, len(messages))
+	for _, message := range messages {
+		byID[message.ID] = message
+	}
+
+	results := make([]SearchResult, 0, len(matches))
+	for _, match := range matches {
+		results = append(results, SearchResult{
+			Message: byID[match.MessageID],
+			Subject: highlighted(match.Subject),
+			Snippet: highlighted(match.Snippet),
+		})
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"total":   total,
+		"results": results,
+	})
+}
+
+// searchFailed reports a search error, telling the client when the server
+// was built without FTS5
+func (h *MessageHandler) searchFailed(c *gin.Context, err error) {
+	if !h.DB.Migrator().HasTable(models.MessageSearchTable) {
+		c.JSON(http.StatusServiceUnavailable, gin.H{
+			"success": false,
+			"message": "Message search is not available on this server",
+		})
+		return
+	}
+	log.Println("Message search failed:", err)
+	c.JSON(http.StatusInternalServerError, gin.H{
+		"error": "Failed to search messages",
+	})
+}
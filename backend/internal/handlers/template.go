@@ -0,0 +1,398 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/yourusername/health-connect/internal/auth"
+	"github.com/yourusername/health-connect/internal/models"
+	"github.com/yourusername/health-connect/internal/templates"
+)
+
+type TemplateHandler struct {
+	DB       *gorm.DB
+	Messages *MessageHandler
+}
+
+func NewTemplateHandler(db *gorm.DB, messages *MessageHandler) *TemplateHandler {
+	return &TemplateHandler{DB: db, Messages: messages}
+}
+
+type TemplateRequest struct {
+	Name     string `json:"name" binding:"required,max=100"`
+	Subject  string `json:"subject" binding:"max=200"`
+	Content  string `json:"content" binding:"required,max=10000"`
+	Category string `json:"category" binding:"omitempty,oneof=refill billing symptoms appointment other"`
+	Shared   bool   `json:"shared"`
+}
+
+type RenderTemplateRequest struct {
+	PatientID    string `json:"patient_id"`    // Required unless reply_to_id is given
+	MedicationID string `json:"medication_id"` // Required when the template uses medication placeholders
+	ReplyToID    string `json:"reply_to_id"`   // Optional; send as a reply in this message's conversation
+	Urgency      string `json:"urgency" binding:"omitempty,oneof=routine urgent emergency"`
+}
+
+// findTemplate loads a template the caller may use: their own or a shared one
+func (h *TemplateHandler) findTemplate(c *gin.Context, principal *auth.Principal) (*models.MessageTemplate, bool) {
+	var template models.MessageTemplate
+	err := h.DB.Where("id = ? AND (physician_id = ? OR shared = ?)", c.Param("id"), principal.ID, true).First(&template).Error
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{
+			"error": "Template not found",
+		})
+		return nil, false
+	}
+	return &template, true
+}
+
+// findOwnTemplate loads a template the caller owns, for changes
+func (h *TemplateHandler) findOwnTemplate(c *gin.Context, principal *auth.Principal) (*models.MessageTemplate, bool) {
+	template, ok := h.findTemplate(c, principal)
+	if ok && template.PhysicianID != principal.ID {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": "Only the physician who created a shared template can change it",
+		})
+		return nil, false
+	}
+	return template, ok
+}
+
+// bindTemplate reads a template request and rejects unknown placeholders
+func bindTemplate(c *gin.Context) (*TemplateRequest, bool) {
+	var req TemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request format: " + err.Error(),
+		})
+		return nil, false
+	}
+
+	if unknown := templates.Unknown(req.Subject + "\n" + req.Content); len(unknown) > 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success":      false,
+			"message":      "Unknown placeholders: {{" + strings.Join(unknown, "}}, {{") + "}}",
+			"placeholders": templates.Placeholders,
+		})
+		return nil, false
+	}
+	return &req, true
+}
+
+func (req *TemplateRequest) applyTo(template *models.MessageTemplate) {
+	template.Name = strings.TrimSpace(req.Name)
+	template.Subject = strings.TrimSpace(req.Subject)
+	template.Content = req.Content
+	template.Category = req.Category
+	template.Shared = req.Shared
+}
+
+// GetTemplates lists the caller's templates and those shared by other physicians
+func (h *TemplateHandler) GetTemplates(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	var list []models.MessageTemplate
+	result := h.DB.Where("physician_id = ? OR shared = ?", principal.ID, true).
+		Order("name ASC").
+		Find(&list)
+
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to fetch templates",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":      true,
+		"templates":    list,
+		"placeholders": templates.Placeholders,
+	})
+}
+
+// GetTemplate returns one template
+func (h *TemplateHandler) GetTemplate(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	template, ok := h.findTemplate(c, principal)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"template": template,
+	})
+}
+
+// CreateTemplate saves a new template owned by the caller
+func (h *TemplateHandler) CreateTemplate(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	req, ok := bindTemplate(c)
+	if !ok {
+		return
+	}
+
+	template := models.MessageTemplate{PhysicianID: principal.ID}
+	req.applyTo(&template)
+	if err := h.DB.Create(&template).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to create template",
+		})
+		return
+	}
+
+	c.JSON(http.StatusCreated, gin.H{
+		"success":  true,
+		"template": template,
+	})
+}
+
+// UpdateTemplate changes one of the caller's templates
+func (h *TemplateHandler) UpdateTemplate(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	template, ok := h.findOwnTemplate(c, principal)
+	if !ok {
+		return
+	}
+	req, ok := bindTemplate(c)
+	if !ok {
+		return
+	}
+
+	req.applyTo(template)
+	if err := h.DB.Save(template).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to update template",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success":  true,
+		"template": template,
+	})
+}
+
+// DeleteTemplate removes one of the caller's templates
+func (h *TemplateHandler) DeleteTemplate(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	template, ok := h.findOwnTemplate(c, principal)
+	if !ok {
+		return
+	}
+
+	if err := h.DB.Delete(template).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to delete template",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Template deleted",
+	})
+}
+
+// renderedTemplate is a template filled in for one patient
+type renderedTemplate struct {
+	Subject string
+	Content string
+	Parent  *models.Message
+	Patient *models.Patient
+}
+
+// render fills in a template for the patient and medication in the
+// request, responding with an error and returning false when it cannot
+func (h *TemplateHandler) render(c *gin.Context, principal *auth.Principal, template *models.MessageTemplate, req *RenderTemplateRequest) (*renderedTemplate, bool) {
+	out := &renderedTemplate{}
+
+	patientID := req.PatientID
+	if req.ReplyToID != "" {
+		var parent models.Message
+		if err := h.DB.Where("id = ?", req.ReplyToID).First(&parent).Error; err != nil || !canAccessThread(h.DB, principal, &parent) {
+			c.JSON(http.StatusNotFound, gin.H{
+				"error": "Message not found",
+			})
+			return nil, false
+		}
+		// Conversations without a patient have no details to fill in
+		if parent.PatientID == nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "cannot send a template to this conversation",
+			})
+			return nil, false
+		}
+		out.Parent = &parent
+		patientID = *parent.PatientID
+	}
+	if patientID == "" {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "patient_id or reply_to_id is required",
+		})
+		return nil, false
+	}
+
+	// Only linked patients (or patients in a conversation the caller covers)
+	// may have their details filled in
+	linked, err := models.IsPatientLinked(h.DB, patientID, principal.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to verify recipient",
+		})
+		return nil, false
+	}
+	var patient models.Patient
+	if (!linked && out.Parent == nil) || h.DB.Where("id = ?", patientID).First(&patient).Error != nil {
+		c.JSON(http.StatusForbidden, gin.H{
+			"success": false,
+			"message": "You can only message patients or physicians you are linked with",
+		})
+		return nil, false
+	}
+	out.Patient = &patient
+
+	var physician models.Physician
+	if err := h.DB.Where("id = ?", principal.ID).First(&physician).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to load physician",
+		})
+		return nil, false
+	}
+
+	firstName := patient.Name
+	if fields := strings.Fields(patient.Name); len(fields) > 0 {
+		firstName = fields[0]
+	}
+	values := map[string]string{
+		"patient.name":       patient.Name,
+		"patient.first_name": firstName,
+		"patient.email":      patient.Email,
+		"physician.name":     physician.Name,
+		"physician.office":   physician.OfficeLocation,
+		"date":               time.Now().Format("January 2, 2006"),
+	}
+
+	if req.MedicationID != "" {
+		var medication models.Medication
+		if err := h.DB.Where("id = ? AND patient_id = ?", req.MedicationID, patient.ID).First(&medication).Error; err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "Medication not found for this patient",
+			})
+			return nil, false
+		}
+		values["medication.name"] = medication.Name
+		values["medication.dosage"] = medication.Dosage
+		values["medication.frequency"] = medication.Frequency
+		values["medication.instructions"] = medication.Instructions
+	}
+
+	text := template.Subject + "\n" + template.Content
+	if _, missing := templates.Render(text, values); len(missing) > 0 {
+		message := "Missing values for {{" + strings.Join(missing, "}}, {{") + "}}"
+		if templates.NeedsMedication(text) && req.MedicationID == "" {
+			message = "medication_id is required for this template"
+		}
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": message,
+		})
+		return nil, false
+	}
+	out.Subject, _ = templates.Render(template.Subject, values)
+	out.Content, _ = templates.Render(template.Content, values)
+
+	return out, true
+}
+
+// PreviewTemplate renders a template for a patient without sending it
+func (h *TemplateHandler) PreviewTemplate(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	var req RenderTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+
+	template, ok := h.findTemplate(c, principal)
+	if !ok {
+		return
+	}
+	rendered, ok := h.render(c, principal, template, &req)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"subject": rendered.Subject,
+		"content": rendered.Content,
+	})
+}
+
+// SendTemplate renders a template and sends it through the normal message
+// path, as a new conversation or as a reply
+func (h *TemplateHandler) SendTemplate(c *gin.Context) {
+	principal, _ := auth.CurrentPrincipal(c)
+
+	var req RenderTemplateRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+
+	template, ok := h.findTemplate(c, principal)
+	if !ok {
+		return
+	}
+	rendered, ok := h.render(c, principal, template, &req)
+	if !ok {
+		return
+	}
+
+	var message models.Message
+	if rendered.Parent != nil {
+		message = newReply(principal, rendered.Parent, rendered.Subject, rendered.Content)
+	} else {
+		if rendered.Subject == "" {
+			c.JSON(http.StatusBadRequest, gin.H{
+				"success": false,
+				"message": "This template has no subject, so it can only be sent as a reply",
+			})
+			return
+		}
+		message = models.Message{
+			PatientID:   &rendered.Patient.ID,
+			PhysicianID: &principal.ID,
+			Subject:     rendered.Subject,
+			Content:     rendered.Content,
+			SenderType:  principal.Role,
+		}
+	}
+	message.Urgency = req.Urgency
+	if template.Category != "" {
+		message.Category = template.Category
+	}
+
+	h.Messages.createMessage(c, &message)
+}
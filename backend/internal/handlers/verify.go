@@ -0,0 +1,122 @@
+package handlers
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+
+	"github.com/yourusername/health-connect/internal/auth"
+	"github.com/yourusername/health-connect/internal/mail"
+	"github.com/yourusername/health-connect/internal/models"
+)
+
+// verificationResendCooldown is the minimum time between verification emails to one account
+const verificationResendCooldown = time.Minute
+
+type VerifyEmailRequest struct {
+	Token string `json:"token" binding:"required"`
+}
+
+type ResendVerificationRequest struct {
+	Email string `json:"email" binding:"required,email"`
+}
+
+// sendVerificationEmail emails a signed verification link and records when it was sent
+func (h *AuthHandler) sendVerificationEmail(accountID, email string) error {
+	token, err := auth.GenerateVerificationToken(accountID, email)
+	if err != nil {
+		return err
+	}
+
+	link := fmt.Sprintf("%s/verify-email?token=%s", AppBaseURL(), url.QueryEscape(token))
+	err = h.Mailer.Send(mail.Message{
+		To:      email,
+		Subject: "Verify your Health Connect email address",
+		Body: fmt.Sprintf("Welcome to Health Connect!\n\n"+
+			"Please confirm your email address within %d hours using the link below:\n%s\n\n"+
+			"If you did not create an account, you can ignore this email.",
+			int(auth.VerificationTokenTTL.Hours()), link),
+	})
+	if err != nil {
+		return err
+	}
+
+	return h.DB.Model(&models.Account{}).Where("id = ?", accountID).Update("verification_sent_at", time.Now()).Error
+}
+
+// VerifyEmail confirms an email address using the signed link from the verification email
+func (h *AuthHandler) VerifyEmail(c *gin.Context) {
+	var req VerifyEmailRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+
+	claims, err := auth.ParseVerificationToken(req.Token)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid or expired verification link",
+		})
+		return
+	}
+
+	// The email must still match so a link cannot verify a changed address
+	result := h.DB.Model(&models.Account{}).
+		Where("id = ? AND email = ?", claims.Subject, claims.Email).
+		Update("verified", true)
+
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{
+			"success": false,
+			"message": "Failed to verify email",
+		})
+		return
+	}
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid or expired verification link",
+		})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "Email verified. You can now sign in.",
+	})
+}
+
+// ResendVerification emails a new verification link to an unverified account.
+// The response does not reveal whether the account exists.
+func (h *AuthHandler) ResendVerification(c *gin.Context) {
+	var req ResendVerificationRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{
+			"success": false,
+			"message": "Invalid request format: " + err.Error(),
+		})
+		return
+	}
+
+	var account models.Account
+	if err := h.DB.Where("email = ? AND verified = ?", req.Email, false).First(&account).Error; err == nil {
+		if account.VerificationSentAt == nil || time.Since(*account.VerificationSentAt) >= verificationResendCooldown {
+			if err := h.sendVerificationEmail(account.ID, account.Email); err != nil {
+				log.Printf("Failed to resend verification email: %v", err)
+			}
+		}
+	}
+
+	c.JSON(http.StatusOK, gin.H{
+		"success": true,
+		"message": "If an unverified account exists for that email, a new verification link has been sent",
+	})
+}
@@ -0,0 +1,350 @@
+// Package interactions checks medications against a table of known
+// drug–drug interactions and against patients' allergies. Medication names,
+// including brand names and combination products, are normalized to their
+// generic ingredients first.
+package interactions
+
+import (
+	"embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"sort"
+	"strings"
+)
+
+// Interaction severities, from least to most serious
+const (
+	SeverityMinor           = "minor"
+	SeverityModerate        = "moderate"
+	SeverityMajor           = "major"
+	SeverityContraindicated = "contraindicated"
+)
+
+var severityRank = map[string]int{
+	SeverityMinor:           1,
+	SeverityModerate:        2,
+	SeverityMajor:           3,
+	SeverityContraindicated: 4,
+}
+
+// Blocks reports whether an interaction is serious enough that prescribing
+// needs the physician to override it
+func Blocks(severity string) bool {
+	return severityRank[severity] >= severityRank[SeverityMajor]
+}
+
+//go:embed data/*.csv
+var bundled embed.FS
+
+// Drug is a medication to check
+type Drug struct {
+	ID   string `json:"id"`
+	Name string `json:"name"`
+}
+
+// Warning is an interaction between an ingredient of one medication and an
+// ingredient of another
+type Warning struct {
+	Severity            string    `json:"severity"`
+	Description         string    `json:"description"`
+	Ingredients         [2]string `json:"ingredients"`             // Of the medication, then of the other medication
+	MedicationID        string    `json:"medication_id,omitempty"` // Empty for a medication not yet prescribed
+	MedicationName      string    `json:"medication_name"`
+	OtherMedicationID   string    `json:"other_medication_id"`
+	OtherMedicationName string    `json:"other_medication_name"`
+}
+
+type interaction struct {
+	severity    string
+	description string
+}
+
+// Checker finds interactions between medications. It is safe for
+// concurrent use once loaded.
+type Checker struct {
+	names        map[string][]string // Normalized name to generic ingredients
+	classNames   map[string][]string // Normalized class name or alias to drug class
+	classes      map[string][]string // Generic ingredient to its drug classes
+	longestName  int                 // Most words in any name or class name
+	interactions map[[2]string]interaction
+}
+
+// Default loads the interaction table shipped with Health Connect
+func Default() (*Checker, error) {
+	return Load(bundled, "data")
+}
+
+// NewCheckerFromEnv loads names.csv, interactions.csv and classes.csv from
+// INTERACTIONS_DIR when it is set, and the bundled tables otherwise
+func NewCheckerFromEnv() (*Checker, error) {
+	if dir := os.Getenv("INTERACTIONS_DIR"); dir != "" {
+		return Load(os.DirFS(dir), ".")
+	}
+	return Default()
+}
+
+// Load reads names.csv (name,ingredients), interactions.csv
+// (ingredient_a,ingredient_b,severity,description) and classes.csv
+// (class,aliases,ingredients) from dir. Lists within a field are separated
+// by semicolons.
+func Load(fsys fs.FS, dir string) (*Checker, error) {
+	c := &Checker{
+		names:        map[string][]string{},
+		classNames:   map[string][]string{},
+		classes:      map[string][]string{},
+		interactions: map[[2]string]interaction{},
+	}
+
+	err := readCSV(fsys, dir+"/interactions.csv", 4, func(row []string) error {
+		a, b := normalize(row[0]), normalize(row[1])
+		severity := strings.TrimSpace(row[2])
+		if a == "" || b == "" || a == b {
+			return fmt.Errorf("invalid ingredient pair %q, %q", row[0], row[1])
+		}
+		if severityRank[severity] == 0 {
+			return fmt.Errorf("unknown severity %q", severity)
+		}
+		c.interactions[pair(a, b)] = interaction{severity: severity, description: strings.TrimSpace(row[3])}
+		c.addName(a, []string{a})
+		c.addName(b, []string{b})
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = readCSV(fsys, dir+"/names.csv", 2, func(row []string) error {
+		name := normalize(row[0])
+		var ingredients []string
+		for _, ingredient := range strings.Split(row[1], ";") {
+			if ingredient = normalize(ingredient); ingredient != "" {
+				ingredients = append(ingredients, ingredient)
+				c.addName(ingredient, []string{ingredient})
+			}
+		}
+		if name == "" || len(ingredients) == 0 {
+			return fmt.Errorf("invalid name %q", row[0])
+		}
+		c.addName(name, ingredients)
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	err = readCSV(fsys, dir+"/classes.csv", 3, func(row []string) error {
+		class := normalize(row[0])
+		if class == "" {
+			return fmt.Errorf("invalid class %q", row[0])
+		}
+		c.addClassName(class, class)
+		for _, alias := range strings.Split(row[1], ";") {
+			if alias = normalize(alias); alias != "" {
+				c.addClassName(alias, class)
+			}
+		}
+		for _, ingredient := range strings.Split(row[2], ";") {
+			if ingredient = normalize(ingredient); ingredient != "" {
+				c.classes[ingredient] = append(c.classes[ingredient], class)
+				if _, ok := c.names[ingredient]; !ok {
+					c.addName(ingredient, []string{ingredient})
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// readCSV calls fn with each row of a CSV file after its header
+func readCSV(fsys fs.FS, path string, fields int, fn func(row []string) error) error {
+	file, err := fsys.Open(path)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = fields
+	if _, err := reader.Read(); err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	for {
+		row, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+		if err := fn(row); err != nil {
+			line, _ := reader.FieldPos(0)
+			return fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+	}
+}
+
+func (c *Checker) addName(name string, ingredients []string) {
+	c.names[name] = ingredients
+	c.longestName = max(c.longestName, len(strings.Fields(name)))
+}
+
+func (c *Checker) addClassName(name, class string) {
+	c.classNames[name] = []string{class}
+	c.longestName = max(c.longestName, len(strings.Fields(name)))
+}
+
+// normalize lowercases a name and turns everything but letters into single
+// spaces, so "K-Dur 20mEq" and "k dur" compare equal
+func normalize(name string) string {
+	return strings.Join(strings.FieldsFunc(strings.ToLower(name), func(r rune) bool {
+		return r < 'a' || r > 'z'
+	}), " ")
+}
+
+func pair(a, b string) [2]string {
+	if a > b {
+		a, b = b, a
+	}
+	return [2]string{a, b}
+}
+
+// Ingredients returns the generic ingredients named in a medication name,
+// such as ["hydrocodone", "acetaminophen"] for "Norco 5/325 mg". Words that
+// are not known drug names, like doses and forms, are ignored. A name with
+// no known drug returns nil.
+func (c *Checker) Ingredients(name string) []string {
+	return c.scan(name, c.names)
+}
+
+// Classes returns the drug classes named in text, such as ["sulfonamides"]
+// for "sulfa drugs"
+func (c *Checker) Classes(text string) []string {
+	return c.scan(text, c.classNames)
+}
+
+// scan looks up the longest runs of words in text that are in dict and
+// returns what they map to, without duplicates
+func (c *Checker) scan(text string, dict map[string][]string) []string {
+	words := strings.Fields(normalize(text))
+	var found []string
+	seen := map[string]bool{}
+	for i := 0; i < len(words); {
+		matched := 1
+		for n := min(c.longestName, len(words)-i); n > 0; n-- {
+			if values, ok := dict[strings.Join(words[i:i+n], " ")]; ok {
+				for _, value := range values {
+					if !seen[value] {
+						seen[value] = true
+						found = append(found, value)
+					}
+				}
+				matched = n
+				break
+			}
+		}
+		i += matched
+	}
+	return found
+}
+
+// AllergyMatch is why a medication conflicts with an allergy
+type AllergyMatch struct {
+	Ingredient string `json:"ingredient"`
+	Class      string `json:"class,omitempty"` // Set when it matched another drug of the same class
+}
+
+// MatchAllergy reports whether a medication contains the substance a
+// patient is allergic to, or a drug of the same class. The substance may be
+// a drug, a brand or a class such as "sulfa" or "NSAIDs". Substances not in
+// the tables match medications whose name contains them.
+func (c *Checker) MatchAllergy(medication, substance string) (AllergyMatch, bool) {
+	ingredients := c.Ingredients(medication)
+	allergens := c.Ingredients(substance)
+	classes := map[string]bool{}
+	for _, class := range c.Classes(substance) {
+		classes[class] = true
+	}
+	for _, allergen := range allergens {
+		for _, class := range c.classes[allergen] {
+			classes[class] = true
+		}
+	}
+
+	for _, ingredient := range ingredients {
+		for _, allergen := range allergens {
+			if ingredient == allergen {
+				return AllergyMatch{Ingredient: ingredient}, true
+			}
+		}
+	}
+	for _, ingredient := range ingredients {
+		for _, class := range c.classes[ingredient] {
+			if classes[class] {
+				return AllergyMatch{Ingredient: ingredient, Class: class}, true
+			}
+		}
+	}
+
+	if substance = normalize(substance); len(allergens) == 0 && len(classes) == 0 && substance != "" {
+		if strings.Contains(" "+normalize(medication)+" ", " "+substance+" ") {
+			return AllergyMatch{Ingredient: substance}, true
+		}
+	}
+	return AllergyMatch{}, false
+}
+
+// Check returns the interactions between a medication and each of others,
+// most serious first
+func (c *Checker) Check(drug Drug, others []Drug) []Warning {
+	ingredients := c.Ingredients(drug.Name)
+	var warnings []Warning
+	for _, other := range others {
+		if other.ID != "" && other.ID == drug.ID {
+			continue
+		}
+		for _, otherIngredient := range c.Ingredients(other.Name) {
+			for _, ingredient := range ingredients {
+				found, ok := c.interactions[pair(ingredient, otherIngredient)]
+				if !ok {
+					continue
+				}
+				warnings = append(warnings, Warning{
+					Severity:            found.severity,
+					Description:         found.description,
+					Ingredients:         [2]string{ingredient, otherIngredient},
+					MedicationID:        drug.ID,
+					MedicationName:      drug.Name,
+					OtherMedicationID:   other.ID,
+					OtherMedicationName: other.Name,
+				})
+			}
+		}
+	}
+	sortWarnings(warnings)
+	return warnings
+}
+
+// CheckAll returns the interactions between every pair of drugs, most
+// serious first
+func (c *Checker) CheckAll(drugs []Drug) []Warning {
+	var warnings []Warning
+	for i := range drugs {
+		warnings = append(warnings, c.Check(drugs[i], drugs[i+1:])...)
+	}
+	sortWarnings(warnings)
+	return warnings
+}
+
+func sortWarnings(warnings []Warning) {
+	sort.SliceStable(warnings, func(i, j int) bool {
+		return severityRank[warnings[i].Severity] > severityRank[warnings[j].Severity]
+	})
+}
@@ -0,0 +1,110 @@
+package interactions
+
+import (
+	"reflect"
+	"testing"
+)
+
+func defaultChecker(t *testing.T) *Checker {
+	t.Helper()
+	checker, err := Default()
+	if err != nil {
+		t.Fatal(err)
+	}
+	return checker
+}
+
+func TestIngredients(t *testing.T) {
+	checker := defaultChecker(t)
+	tests := []struct {
+		name string
+		want []string
+	}{
+		{"Norco 5/325 mg", []string{"hydrocodone", "acetaminophen"}},
+		{"Coumadin 5 mg tablet", []string{"warfarin"}},
+		{"ibuprofen", []string{"ibuprofen"}},
+		{"Tylenol with Codeine #3", []string{"acetaminophen", "codeine"}},
+		{"vitamin water", nil},
+	}
+	for _, tt := range tests {
+		if got := checker.Ingredients(tt.name); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Ingredients(%q) = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMatchAllergy(t *testing.T) {
+	checker := defaultChecker(t)
+	tests := []struct {
+		medication, substance string
+		want                  AllergyMatch
+		ok                    bool
+	}{
+		{"Bactrim DS", "sulfa", AllergyMatch{Ingredient: "sulfamethoxazole", Class: "sulfonamides"}, true},
+		{"Norco 5/325 mg", "Tylenol", AllergyMatch{Ingredient: "acetaminophen"}, true},
+		{"Norco 5/325 mg", "sulfa", AllergyMatch{}, false},
+		{"Bactrim DS", "latex", AllergyMatch{}, false},
+	}
+	for _, tt := range tests {
+		got, ok := checker.MatchAllergy(tt.medication, tt.substance)
+		if ok != tt.ok || got != tt.want {
+			t.Errorf("MatchAllergy(%q, %q) = %+v, %v, want %+v, %v", tt.medication, tt.substance, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	checker := defaultChecker(t)
+	warnings := checker.Check(Drug{Name: "warfarin 5 mg"}, []Drug{{ID: "b", Name: "Advil 200 mg"}})
+	if len(warnings) != 1 {
+		t.Fatalf("Check(warfarin, Advil) = %+v, want one warning", warnings)
+	}
+	got := warnings[0]
+	if got.Severity != SeverityMajor || got.Ingredients != [2]string{"warfarin", "ibuprofen"} || got.OtherMedicationID != "b" {
+		t.Errorf("Check(warfarin, Advil) = %+v", got)
+	}
+	if !Blocks(got.Severity) {
+		t.Errorf("Blocks(%q) = false, want true", got.Severity)
+	}
+}
+
+func TestBlocks(t *testing.T) {
+	tests := []struct {
+		severity string
+		want     bool
+	}{
+		{SeverityMinor, false},
+		{SeverityModerate, false},
+		{SeverityMajor, true},
+		{SeverityContraindicated, true},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := Blocks(tt.severity); got != tt.want {
+			t.Errorf("Blocks(%q) = %v, want %v", tt.severity, got, tt.want)
+		}
+	}
+}
+
+func TestCheckAllOrdersBySeverity(t *testing.T) {
+	checker := defaultChecker(t)
+	warnings := checker.CheckAll([]Drug{
+		{ID: "a", Name: "Tylenol"},
+		{ID: "b", Name: "Lisinopril"},
+		{ID: "c", Name: "Coumadin"},
+		{ID: "d", Name: "Advil"},
+	})
+
+	// Most serious first; equally serious warnings keep the order of the drugs
+	want := [][2]string{{"c", "d"}, {"a", "c"}, {"b", "d"}}
+	got := make([][2]string, len(warnings))
+	for i, warning := range warnings {
+		got[i] = [2]string{warning.MedicationID, warning.OtherMedicationID}
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("CheckAll pairs = %v, want %v", got, want)
+	}
+	if len(warnings) > 0 && warnings[0].Severity != SeverityMajor {
+		t.Errorf("CheckAll first severity = %q, want %q", warnings[0].Severity, SeverityMajor)
+	}
+}
@@ -0,0 +1,322 @@
+package middleware
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+
+	"github.com/yourusername/health-connect/internal/auth"
+	"github.com/yourusername/health-connect/internal/models"
+)
+
+type AuthMiddleware struct {
+	DB *gorm.DB
+}
+
+func NewAuthMiddleware(db *gorm.DB) *AuthMiddleware {
+	return &AuthMiddleware{DB: db}
+}
+
+// abortUnauthorized stops the request with a 401 the frontend can detect
+func abortUnauthorized(c *gin.Context, message string) {
+	c.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{
+		"success": false,
+		"error":   "unauthorized",
+		"message": message,
+	})
+}
+
+// abortForbidden stops the request with a 403 the frontend can detect
+func abortForbidden(c *gin.Context, message string) {
+	c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+		"success": false,
+		"error":   "forbidden",
+		"message": message,
+	})
+}
+
+// RequireAuth verifies the bearer token and loads the caller
+func (m *AuthMiddleware) RequireAuth() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		header := c.GetHeader("Authorization")
+		tokenString, found := strings.CutPrefix(header, "Bearer ")
+		if !found || tokenString == "" {
+			abortUnauthorized(c, "Missing or malformed authorization header")
+			return
+		}
+
+		claims, err := auth.ParseToken(tokenString)
+		if err != nil {
+			abortUnauthorized(c, "Invalid or expired token")
+			return
+		}
+
+		m.authenticate(c, claims)
+	}
+}
+
+// RequireStreamTicket verifies the ?ticket= that opens the event stream, for
+// EventSource clients that cannot set headers, and loads the caller of the
+// session it was issued for
+func (m *AuthMiddleware) RequireStreamTicket() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		ticket := c.Query("ticket")
+		if ticket == "" {
+			abortUnauthorized(c, "Missing stream ticket")
+			return
+		}
+
+		claims, err := auth.ParseStreamTicket(ticket)
+		if err != nil {
+			abortUnauthorized(c, "Invalid or expired stream ticket")
+			return
+		}
+
+		m.authenticate(c, claims.Session())
+	}
+}
+
+// authenticate checks that the session behind verified claims is still
+// valid, loads the caller and continues the chain
+func (m *AuthMiddleware) authenticate(c *gin.Context, claims *auth.Claims) {
+	revoked, err := auth.IsTokenRevoked(m.DB, claims.ID)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+			"error": "Failed to verify token",
+		})
+		return
+	}
+	if revoked {
+		abortUnauthorized(c, "Token has been revoked")
+		return
+	}
+
+	// Load the caller so deleted accounts lose access immediately
+	var account models.Account
+	if err := m.DB.Where("id = ?", claims.Subject).First(&account).Error; err != nil {
+		abortUnauthorized(c, "Account not found")
+		return
+	}
+
+	principal := &auth.Principal{
+		AccountID: account.ID,
+		Email:     account.Email,
+		Role:      claims.Role,
+		Verified:  account.Verified,
+		Approved:  true,
+		Claims:    claims,
+	}
+
+	// The token's role must still be backed by a profile on the account
+	switch claims.Role {
+	case auth.RolePatient:
+		var patient models.Patient
+		if err := m.DB.Where("account_id = ?", account.ID).First(&patient).Error; err != nil {
+			abortUnauthorized(c, "Account not found")
+			return
+		}
+		principal.ID = patient.ID
+	case auth.RolePhysician:
+		var physician models.Physician
+		if err := m.DB.Where("account_id = ?", account.ID).First(&physician).Error; err != nil {
+			abortUnauthorized(c, "Account not found")
+			return
+		}
+		principal.ID = physician.ID
+		principal.Approved = physician.LicenseStatus == models.LicenseStatusApproved
+	case auth.RoleAdmin:
+		var admin models.Admin
+		if err := m.DB.Where("account_id = ?", account.ID).First(&admin).Error; err != nil {
+			abortUnauthorized(c, "Account not found")
+			return
+		}
+		principal.ID = admin.ID
+	}
+
+	auth.SetPrincipal(c, principal)
+	c.Next()
+}
+
+// RequireVerified blocks callers who have not confirmed their email address.
+// Must run after RequireAuth.
+func (m *AuthMiddleware) RequireVerified() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := auth.CurrentPrincipal(c)
+		if !ok {
+			abortUnauthorized(c, "Authentication required")
+			return
+		}
+
+		if !principal.Verified {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error":   "unverified",
+				"message": "Please verify your email address to continue",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireApprovedLicense blocks physicians whose license is still pending
+// review or was rejected. Must run after RequireAuth.
+func (m *AuthMiddleware) RequireApprovedLicense() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := auth.CurrentPrincipal(c)
+		if !ok {
+			abortUnauthorized(c, "Authentication required")
+			return
+		}
+
+		if !principal.Approved {
+			c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+				"success": false,
+				"error":   "license_pending",
+				"message": "Your medical license has not been approved yet",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequireMFAEnrollment blocks physicians who have not enabled MFA when
+// MFA_REQUIRED_FOR_PHYSICIANS is set. Must run after RequireAuth.
+func (m *AuthMiddleware) RequireMFAEnrollment() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := auth.CurrentPrincipal(c)
+		if !ok {
+			abortUnauthorized(c, "Authentication required")
+			return
+		}
+
+		if principal.IsPhysician() && auth.MFARequiredForPhysicians() {
+			var count int64
+			err := m.DB.Model(&models.MFAEnrollment{}).
+				Where("user_id = ? AND role = ? AND enabled = ?", principal.AccountID, principal.Role, true).
+				Count(&count).Error
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+					"error": "Failed to verify MFA enrollment",
+				})
+				return
+			}
+			if count == 0 {
+				c.AbortWithStatusJSON(http.StatusForbidden, gin.H{
+					"success": false,
+					"error":   "mfa_enrollment_required",
+					"message": "Set up multi-factor authentication to access patient data",
+				})
+				return
+			}
+		}
+
+		c.Next()
+	}
+}
+
+// RequireRole allows only callers holding one of the given roles.
+// Must run after RequireAuth.
+func (m *AuthMiddleware) RequireRole(roles ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := auth.CurrentPrincipal(c)
+		if !ok {
+			abortUnauthorized(c, "Authentication required")
+			return
+		}
+
+		for _, role := range roles {
+			if principal.Role == role {
+				c.Next()
+				return
+			}
+		}
+
+		abortForbidden(c, "You do not have permission to access this resource")
+	}
+}
+
+// RequirePHIAccess returns the checks every caller must pass before reading
+// patient data: a valid token, a verified email, an approved license for
+// physicians and MFA where it is mandatory
+func (m *AuthMiddleware) RequirePHIAccess() []gin.HandlerFunc {
+	return m.phiChecks(m.RequireAuth())
+}
+
+// RequireStreamPHIAccess is RequirePHIAccess for the event stream, which is
+// opened with a stream ticket instead of the access token
+func (m *AuthMiddleware) RequireStreamPHIAccess() []gin.HandlerFunc {
+	return m.phiChecks(m.RequireStreamTicket())
+}
+
+func (m *AuthMiddleware) phiChecks(authenticate gin.HandlerFunc) []gin.HandlerFunc {
+	return []gin.HandlerFunc{
+		authenticate,
+		m.RequireVerified(),
+		m.RequireApprovedLicense(),
+		m.RequireMFAEnrollment(),
+	}
+}
+
+// RequirePatientAccess allows the patient themself or a linked physician
+// to access /patients/:id routes. Must run after RequireAuth.
+func (m *AuthMiddleware) RequirePatientAccess() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := auth.CurrentPrincipal(c)
+		if !ok {
+			abortUnauthorized(c, "Authentication required")
+			return
+		}
+
+		patientID := c.Param("id")
+
+		switch {
+		case principal.IsPatient():
+			if principal.ID != patientID {
+				abortForbidden(c, "You may only access your own records")
+				return
+			}
+		case principal.IsPhysician():
+			linked, err := models.IsPatientLinked(m.DB, patientID, principal.ID)
+			if err != nil {
+				c.AbortWithStatusJSON(http.StatusInternalServerError, gin.H{
+					"error": "Failed to verify access",
+				})
+				return
+			}
+			if !linked {
+				abortForbidden(c, "Patient is not under your care")
+				return
+			}
+		default:
+			abortForbidden(c, "Access denied")
+			return
+		}
+
+		c.Next()
+	}
+}
+
+// RequirePhysicianSelf allows only the physician themself to access
+// /physicians/:id routes. Must run after RequireAuth.
+func (m *AuthMiddleware) RequirePhysicianSelf() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		principal, ok := auth.CurrentPrincipal(c)
+		if !ok {
+			abortUnauthorized(c, "Authentication required")
+			return
+		}
+
+		if !principal.IsPhysician() || principal.ID != c.Param("id") {
+			abortForbidden(c, "You may only access your own records")
+			return
+		}
+
+		c.Next()
+	}
+}
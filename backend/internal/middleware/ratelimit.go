@@ -0,0 +1,56 @@
+package middleware
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// RateLimit allows at most limit requests per client IP in each window.
+// Counts are kept in memory and reset when the server restarts.
+func RateLimit(limit int, window time.Duration) gin.HandlerFunc {
+	var mu sync.Mutex
+	windows := make(map[string]*rateWindow)
+
+	return func(c *gin.Context) {
+		now := time.Now()
+		key := c.ClientIP()
+
+		mu.Lock()
+		w, ok := windows[key]
+		if !ok || now.Sub(w.start) >= window {
+			// Drop stale entries so the map does not grow without bound
+			for k, v := range windows {
+				if now.Sub(v.start) >= window {
+					delete(windows, k)
+				}
+			}
+			w = &rateWindow{start: now}
+			windows[key] = w
+		}
+		w.count++
+		count := w.count
+		retryAfter := w.start.Add(window).Sub(now)
+		mu.Unlock()
+
+		if count > limit {
+			c.Header("Retry-After", strconv.Itoa(int(retryAfter.Seconds())+1))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, gin.H{
+				"success": false,
+				"error":   "rate_limited",
+				"message": "Too many requests, please try again later",
+			})
+			return
+		}
+
+		c.Next()
+	}
+}
@@ -0,0 +1,203 @@
+package models
+
+import (
+	"database/sql"
+	"log"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Account is a person's login identity. A single account can hold several
+// roles, each backed by a profile (Patient, Physician or Admin) that points
+// back to it through AccountID.
+type Account struct {
+	ID                 string         `gorm:"type:char(36);primary_key" json:"id"`
+	Email              string         `gorm:"uniqueIndex;not null" json:"email"`
+	Password           string         `gorm:"not null" json:"-"`
+	Verified           bool           `gorm:"default:false" json:"verified"` // Set once the email address is confirmed
+	VerificationSentAt *time.Time     `json:"-"`
+	CreatedAt          time.Time      `json:"created_at"`
+	UpdatedAt          time.Time      `json:"updated_at"`
+	DeletedAt          gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+}
+
+// BeforeCreate hook to generate UUID
+func (a *Account) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == "" {
+		a.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// profileModels maps each role to the profile model that backs it, in the
+// order roles are listed and picked by default at login
+var profileModels = []struct {
+	role  string
+	model func() interface{}
+}{
+	{"patient", func() interface{} { return &Patient{} }},
+	{"physician", func() interface{} { return &Physician{} }},
+	{"admin", func() interface{} { return &Admin{} }},
+}
+
+// AccountRoles lists the roles an account holds, in a stable order
+func AccountRoles(db *gorm.DB, accountID string) ([]string, error) {
+	var roles []string
+	for _, profile := range profileModels {
+		var count int64
+		if err := db.Model(profile.model()).Where("account_id = ?", accountID).Count(&count).Error; err != nil {
+			return nil, err
+		}
+		if count > 0 {
+			roles = append(roles, profile.role)
+		}
+	}
+	return roles, nil
+}
+
+// FindProfileID returns the ID of the account's profile for role, or
+// gorm.ErrRecordNotFound if the account does not hold that role
+func FindProfileID(db *gorm.DB, accountID, role string) (string, error) {
+	for _, profile := range profileModels {
+		if profile.role != role {
+			continue
+		}
+		var ids []string
+		if err := db.Model(profile.model()).Where("account_id = ?", accountID).Limit(1).Pluck("id", &ids).Error; err != nil {
+			return "", err
+		}
+		if len(ids) == 0 {
+			return "", gorm.ErrRecordNotFound
+		}
+		return ids[0], nil
+	}
+	return "", gorm.ErrRecordNotFound
+}
+
+// legacyCredentialTables are the profile tables that stored their own
+// credentials before accounts existed, with the role each one represents
+var legacyCredentialTables = []struct {
+	table string
+	role  string
+	model interface{}
+}{
+	{"patients", "patient", &Patient{}},
+	{"physicians", "physician", &Physician{}},
+	{"admins", "admin", &Admin{}},
+}
+
+// MigrateAccounts moves credentials stored on profile rows into accounts.
+// Profiles sharing an email are linked to one account; the earliest
+// profile's password is kept. Tokens and MFA rows keyed by profile ID are
+// re-keyed to the account, then the old credential columns are dropped.
+func MigrateAccounts(db *gorm.DB) error {
+	for _, legacy := range legacyCredentialTables {
+		if !db.Migrator().HasColumn(legacy.table, "password") {
+			continue
+		}
+
+		hasVerified := db.Migrator().HasColumn(legacy.table, "verified")
+		columns := "id, email, password"
+		if hasVerified {
+			columns += ", verified, verification_sent_at"
+		}
+
+		rows, err := db.Table(legacy.table).
+			Select(columns).
+			Where("account_id IS NULL OR account_id = ''").
+			Order("created_at ASC").
+			Rows()
+		if err != nil {
+			return err
+		}
+
+		type legacyProfile struct {
+			ID, Email, Password string
+			Verified            bool
+			VerificationSentAt  sql.NullTime
+		}
+		var profiles []legacyProfile
+		for rows.Next() {
+			var p legacyProfile
+			if hasVerified {
+				var verified sql.NullBool
+				err = rows.Scan(&p.ID, &p.Email, &p.Password, &verified, &p.VerificationSentAt)
+				p.Verified = verified.Bool
+			} else {
+				err = rows.Scan(&p.ID, &p.Email, &p.Password)
+				p.Verified = true // admins were never email-verified
+			}
+			if err != nil {
+				rows.Close()
+				return err
+			}
+			profiles = append(profiles, p)
+		}
+		rows.Close()
+
+		for _, p := range profiles {
+			err := db.Transaction(func(tx *gorm.DB) error {
+				var account Account
+				err := tx.Where("email = ?", p.Email).First(&account).Error
+				if err == gorm.ErrRecordNotFound {
+					account = Account{Email: p.Email, Password: p.Password, Verified: p.Verified}
+					if p.VerificationSentAt.Valid {
+						account.VerificationSentAt = &p.VerificationSentAt.Time
+					}
+					if err := tx.Create(&account).Error; err != nil {
+						return err
+					}
+				} else if err != nil {
+					return err
+				} else if account.Password != p.Password {
+					log.Printf("Account %s already exists; keeping its password for the %s profile", p.Email, legacy.role)
+				}
+
+				if err := tx.Table(legacy.table).Where("id = ?", p.ID).Update("account_id", account.ID).Error; err != nil {
+					return err
+				}
+
+				for _, table := range []string{"refresh_tokens", "password_reset_tokens", "mfa_enrollments", "mfa_recovery_codes"} {
+					if !tx.Migrator().HasTable(table) {
+						continue
+					}
+					if err := tx.Table(table).
+						Where("user_id = ? AND role = ?", p.ID, legacy.role).
+						Update("user_id", account.ID).Error; err != nil {
+						return err
+					}
+				}
+				return nil
+			})
+			if err != nil {
+				return err
+			}
+		}
+
+		for _, column := range []string{"password", "verified", "verification_sent_at"} {
+			if db.Migrator().HasColumn(legacy.model, column) {
+				if err := db.Migrator().DropColumn(legacy.model, column); err != nil {
+					return err
+				}
+			}
+		}
+		// SQLite drops columns by rebuilding the table, which loses its indexes
+		if err := db.AutoMigrate(legacy.model); err != nil {
+			return err
+		}
+		log.Printf("Migrated %d %s credentials to accounts", len(profiles), legacy.role)
+	}
+
+	// Password resets now apply to the whole account rather than one role
+	if db.Migrator().HasColumn(&PasswordResetToken{}, "role") {
+		if err := db.Migrator().DropColumn(&PasswordResetToken{}, "role"); err != nil {
+			return err
+		}
+		if err := db.AutoMigrate(&PasswordResetToken{}); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,27 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Admin is a staff account that reviews physician licenses
+type Admin struct {
+	ID        string         `gorm:"type:char(36);primary_key" json:"id"`
+	AccountID string         `gorm:"type:char(36);uniqueIndex" json:"-"`
+	Email     string         `gorm:"uniqueIndex;not null" json:"email"`
+	Name      string         `json:"name"`
+	CreatedAt time.Time      `json:"created_at"`
+	UpdatedAt time.Time      `json:"updated_at"`
+	DeletedAt gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+}
+
+// BeforeCreate hook to generate UUID
+func (a *Admin) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == "" {
+		a.ID = uuid.New().String()
+	}
+	return nil
+}
@@ -0,0 +1,67 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Allergy severities
+const (
+	AllergySeverityMild     = "mild"
+	AllergySeverityModerate = "moderate"
+	AllergySeveritySevere   = "severe"
+	AllergySeverityUnknown  = "unknown"
+)
+
+// Allergy is a substance a patient reacts to. Allergies recorded or changed
+// by a physician are verified by them. Patients can only change or delete
+// allergies that have not been verified.
+type Allergy struct {
+	ID             string         `gorm:"type:char(36);primary_key" json:"id"`
+	PatientID      string         `gorm:"type:char(36);index;not null" json:"patient_id"`
+	Substance      string         `gorm:"not null" json:"substance"` // A drug, brand, drug class or anything else
+	Reaction       string         `json:"reaction"`
+	Severity       string         `gorm:"not null;default:unknown" json:"severity"`
+	Onset          *time.Time     `json:"onset,omitempty"` // When the reaction first happened
+	RecordedByID   string         `gorm:"type:char(36);not null" json:"recorded_by_id"`
+	RecordedByType string         `gorm:"not null" json:"recorded_by_type"` // "patient" or "physician"
+	VerifiedByID   *string        `gorm:"type:char(36)" json:"verified_by_id"`
+	VerifiedBy     *Physician     `gorm:"foreignKey:VerifiedByID" json:"verified_by,omitempty"`
+	VerifiedAt     *time.Time     `json:"verified_at,omitempty"`
+	CreatedAt      time.Time      `json:"created_at"`
+	UpdatedAt      time.Time      `json:"updated_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+}
+
+// BeforeCreate hook to generate UUID
+func (a *Allergy) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == "" {
+		a.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// AllergyOverride records that a physician prescribed a medication despite
+// a matching allergy, and why
+type AllergyOverride struct {
+	ID             string     `gorm:"type:char(36);primary_key" json:"id"`
+	PatientID      string     `gorm:"type:char(36);index;not null" json:"patient_id"`
+	AllergyID      string     `gorm:"type:char(36);index;not null" json:"allergy_id"`
+	MedicationID   string     `gorm:"type:char(36);index;not null" json:"medication_id"`
+	Ingredient     string     `gorm:"not null" json:"ingredient"`
+	Class          string     `json:"class,omitempty"`
+	Reason         string     `gorm:"not null" json:"reason"`
+	OverriddenByID string     `gorm:"type:char(36);not null" json:"overridden_by_id"`
+	OverriddenBy   *Physician `gorm:"foreignKey:OverriddenByID" json:"overridden_by,omitempty"`
+	CreatedAt      time.Time  `json:"created_at"`
+}
+
+// BeforeCreate hook to generate UUID
+func (o *AllergyOverride) BeforeCreate(tx *gorm.DB) error {
+	if o.ID == "" {
+		o.ID = uuid.New().String()
+	}
+	return nil
+}
@@ -0,0 +1,32 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Attachment is a file sent with a message. The content lives in blob
+// storage under StorageKey; only metadata is kept in the database.
+type Attachment struct {
+	ID             string         `gorm:"type:char(36);primary_key" json:"id"`
+	MessageID      string         `gorm:"type:char(36);index;not null" json:"message_id"`
+	Filename       string         `gorm:"not null" json:"filename"`
+	ContentType    string         `gorm:"not null" json:"content_type"`
+	Size           int64          `gorm:"not null" json:"size"`
+	Checksum       string         `gorm:"type:char(64);not null" json:"checksum"` // SHA-256, hex encoded
+	StorageKey     string         `gorm:"not null" json:"-"`
+	UploadedByID   string         `gorm:"type:char(36);not null" json:"uploaded_by_id"`
+	UploadedByType string         `gorm:"not null" json:"uploaded_by_type"` // "patient" or "physician"
+	CreatedAt      time.Time      `json:"created_at"`
+	DeletedAt      gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// BeforeCreate hook to generate UUID
+func (a *Attachment) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == "" {
+		a.ID = uuid.New().String()
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package models
+
+import (
+	"errors"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Conversation roles. Owners manage membership; the patient is always a
+// member and cannot be removed.
+const (
+	ConversationRoleOwner  = "owner"
+	ConversationRoleMember = "member"
+)
+
+// Conversation is a care-team discussion between a patient and several
+// physicians. Its ID is the thread_id of its messages.
+type Conversation struct {
+	ID            string                    `gorm:"type:char(36);primary_key" json:"id"`
+	PatientID     string                    `gorm:"type:char(36);index;not null" json:"patient_id"`
+	Subject       string                    `gorm:"not null" json:"subject"`
+	CreatedByID   string                    `gorm:"type:char(36);not null" json:"created_by_id"`
+	CreatedByType string                    `gorm:"not null" json:"created_by_type"` // "patient" or "physician"
+	Participants  []ConversationParticipant `gorm:"foreignKey:ConversationID" json:"participants,omitempty"`
+	CreatedAt     time.Time                 `json:"created_at"`
+	UpdatedAt     time.Time                 `json:"updated_at"`
+}
+
+// BeforeCreate hook to generate UUID
+func (c *Conversation) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == "" {
+		c.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// ConversationParticipant is one member of a conversation. Members who
+// leave keep their row with LeftAt set.
+type ConversationParticipant struct {
+	ID              string     `gorm:"type:char(36);primary_key" json:"id"`
+	ConversationID  string     `gorm:"type:char(36);index;not null" json:"conversation_id"`
+	ParticipantID   string     `gorm:"type:char(36);index;not null" json:"participant_id"`
+	ParticipantType string     `gorm:"not null" json:"participant_type"`    // "patient" or "physician"
+	Role            string     `gorm:"not null;default:member" json:"role"` // "owner" or "member"
+	AddedByID       string     `gorm:"type:char(36)" json:"added_by_id,omitempty"`
+	JoinedAt        time.Time  `json:"joined_at"`
+	LeftAt          *time.Time `json:"left_at,omitempty"`
+}
+
+// BeforeCreate hook to generate UUID
+func (p *ConversationParticipant) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == "" {
+		p.ID = uuid.New().String()
+	}
+	if p.JoinedAt.IsZero() {
+		p.JoinedAt = time.Now()
+	}
+	return nil
+}
+
+// FindConversation returns the conversation for a thread, or
+// gorm.ErrRecordNotFound for a one-to-one thread
+func FindConversation(db *gorm.DB, threadID string) (*Conversation, error) {
+	var conversation Conversation
+	if err := db.Where("id = ?", threadID).First(&conversation).Error; err != nil {
+		return nil, err
+	}
+	return &conversation, nil
+}
+
+// IsGroupThread reports whether a thread belongs to a care-team conversation
+func IsGroupThread(db *gorm.DB, threadID string) (bool, error) {
+	_, err := FindConversation(db, threadID)
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return false, nil
+	}
+	return err == nil, err
+}
+
+// ActiveParticipants returns the current members of a conversation
+func ActiveParticipants(db *gorm.DB, conversationID string) ([]ConversationParticipant, error) {
+	var participants []ConversationParticipant
+	err := db.Where("conversation_id = ? AND left_at IS NULL", conversationID).
+		Order("joined_at ASC").
+		Find(&participants).Error
+	return participants, err
+}
+
+// FindParticipant returns a current member of a conversation, or gorm.ErrRecordNotFound
+func FindParticipant(db *gorm.DB, conversationID, participantID, participantType string) (*ConversationParticipant, error) {
+	var participant ConversationParticipant
+	err := db.Where("conversation_id = ? AND participant_id = ? AND participant_type = ? AND left_at IS NULL",
+		conversationID, participantID, participantType).
+		First(&participant).Error
+	if err != nil {
+		return nil, err
+	}
+	return &participant, nil
+}
+
+// ParticipatingThreads is a subquery of the conversation IDs (thread IDs)
+// someone is currently a member of
+func ParticipatingThreads(db *gorm.DB, participantID, participantType string) *gorm.DB {
+	return db.Model(&ConversationParticipant{}).
+		Select("conversation_id").
+		Where("participant_id = ? AND participant_type = ? AND left_at IS NULL", participantID, participantType)
+}
+
+// VisibleToPhysician scopes messages to those a physician can see: their
+// one-to-one conversations and the group conversations they currently
+// belong to
+func VisibleToPhysician(physicianID string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("(messages.physician_id = ? AND messages.thread_id NOT IN (?)) OR messages.thread_id IN (?)",
+			physicianID,
+			db.Session(&gorm.Session{NewDB: true}).Model(&Conversation{}).Select("id"),
+			ParticipatingThreads(db.Session(&gorm.Session{NewDB: true}), physicianID, "physician"))
+	}
+}
+
+// AccessibleToPhysician scopes messages to those the thread access check
+// lets a physician open: those VisibleToPhysician and the one-to-one
+// conversations they currently cover for an absent colleague
+func AccessibleToPhysician(physicianID string) func(*gorm.DB) *gorm.DB {
+	return func(db *gorm.DB) *gorm.DB {
+		return db.Where("(messages.thread_id NOT IN (?) AND (messages.physician_id = ? OR messages.thread_id IN (?))) OR messages.thread_id IN (?)",
+			db.Session(&gorm.Session{NewDB: true}).Model(&Conversation{}).Select("id"),
+			physicianID,
+			CoveredThreads(db.Session(&gorm.Session{NewDB: true}), physicianID),
+			ParticipatingThreads(db.Session(&gorm.Session{NewDB: true}), physicianID, "physician"))
+	}
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// Event is a real-time notification kept for a while so clients that
+// reconnect can catch up from the last event ID they saw
+type Event struct {
+	ID            uint64    `gorm:"primaryKey;autoIncrement" json:"id"`
+	RecipientID   string    `gorm:"type:char(36);index:idx_event_recipient;not null" json:"recipient_id"`
+	RecipientType string    `gorm:"index:idx_event_recipient;not null" json:"recipient_type"` // "patient" or "physician"
+	Type          string    `gorm:"not null" json:"type"`
+	Payload       string    `gorm:"type:text" json:"payload"` // JSON; only IDs for messages, attachments and notifications
+	CreatedAt     time.Time `gorm:"index" json:"created_at"`
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// InteractionAcknowledgment records that a physician reviewed an
+// interaction between two of a patient's medications and chose to continue.
+// Each pair is stored once, with the medication IDs in ascending order and
+// each ingredient next to its medication.
+type InteractionAcknowledgment struct {
+	ID                string     `gorm:"type:char(36);primary_key" json:"id"`
+	PatientID         string     `gorm:"type:char(36);index;not null" json:"patient_id"`
+	MedicationID      string     `gorm:"type:char(36);uniqueIndex:idx_interaction_acknowledgment;not null" json:"medication_id"`
+	Ingredient        string     `gorm:"uniqueIndex:idx_interaction_acknowledgment;not null" json:"ingredient"`
+	OtherMedicationID string     `gorm:"type:char(36);uniqueIndex:idx_interaction_acknowledgment;not null" json:"other_medication_id"`
+	OtherIngredient   string     `gorm:"uniqueIndex:idx_interaction_acknowledgment;not null" json:"other_ingredient"`
+	Severity          string     `gorm:"not null" json:"severity"` // As graded when acknowledged
+	Reason            string     `gorm:"not null" json:"reason"`
+	AcknowledgedByID  string     `gorm:"type:char(36);not null" json:"acknowledged_by_id"`
+	AcknowledgedBy    *Physician `gorm:"foreignKey:AcknowledgedByID" json:"acknowledged_by,omitempty"`
+	CreatedAt         time.Time  `json:"created_at"`
+}
+
+// BeforeCreate hook to generate UUID
+func (a *InteractionAcknowledgment) BeforeCreate(tx *gorm.DB) error {
+	if a.ID == "" {
+		a.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// InteractionKey identifies an interaction between two medications'
+// ingredients regardless of which is given first
+func InteractionKey(medicationID, ingredient, otherMedicationID, otherIngredient string) [4]string {
+	if medicationID > otherMedicationID {
+		medicationID, ingredient, otherMedicationID, otherIngredient = otherMedicationID, otherIngredient, medicationID, ingredient
+	}
+	return [4]string{medicationID, ingredient, otherMedicationID, otherIngredient}
+}
+
+// Key returns the acknowledged interaction's InteractionKey
+func (a *InteractionAcknowledgment) Key() [4]string {
+	return InteractionKey(a.MedicationID, a.Ingredient, a.OtherMedicationID, a.OtherIngredient)
+}
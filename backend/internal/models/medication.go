@@ -1,26 +1,36 @@
 package models
 
 import (
+	"log"
+	"regexp"
+	"strings"
 	"time"
 
 	"github.com/google/uuid"
 	"gorm.io/gorm"
+
+	"github.com/yourusername/health-connect/internal/dosing"
 )
 
 type Medication struct {
-	ID           string     `gorm:"type:char(36);primary_key" json:"id"`
-	PatientID    string     `gorm:"type:char(36);not null" json:"patient_id"`
-	Patient      Patient    `gorm:"foreignKey:PatientID" json:"-"`
-	Name         string     `gorm:"not null" json:"name"`
-	Dosage       string     `json:"dosage"`
-	Frequency    string     `json:"frequency"`
-	Instructions string     `json:"instructions"`
-	StartDate    time.Time  `json:"start_date"`
-	EndDate      *time.Time `json:"end_date,omitempty"`
-	PrescribedBy string     `json:"prescribed_by"`
-	CreatedAt    time.Time  `json:"created_at"`
-	UpdatedAt    time.Time `json:"updated_at"`
-	DeletedAt    gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
+	ID                 string           `gorm:"type:char(36);primary_key" json:"id"`
+	PatientID          string           `gorm:"type:char(36);not null" json:"patient_id"`
+	Patient            Patient          `gorm:"foreignKey:PatientID" json:"-"`
+	Name               string           `gorm:"not null" json:"name"`
+	Dosage             string           `json:"dosage"`
+	Frequency          string           `json:"frequency"`
+	Instructions       string           `json:"instructions"`
+	Schedule           *dosing.Schedule `gorm:"type:text;serializer:json" json:"schedule"` // Nil when the frequency could not be read
+	StartDate          time.Time        `json:"start_date"`
+	EndDate            *time.Time       `json:"end_date,omitempty"`
+	PrescribedByID     *string          `gorm:"type:char(36);index" json:"prescribed_by_id"`
+	PrescribedBy       *Physician       `gorm:"foreignKey:PrescribedByID" json:"prescribed_by,omitempty"`
+	DiscontinuedReason string           `json:"discontinued_reason,omitempty"`
+	DiscontinuedByID   *string          `gorm:"type:char(36)" json:"discontinued_by_id,omitempty"`
+	Version            int              `gorm:"not null;default:1" json:"version"` // Incremented on every change; see MedicationVersion
+	CreatedAt          time.Time        `json:"created_at"`
+	UpdatedAt          time.Time        `json:"updated_at"`
+	DeletedAt          gorm.DeletedAt   `gorm:"index" json:"deleted_at,omitempty"`
 }
 
 // BeforeCreate hook to generate UUID
@@ -31,3 +41,145 @@ func (m *Medication) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+// Active reports whether a medication is still being taken at a time. The
+// end date decides: discontinuing always sets it, so a discontinuation
+// dated in the future only takes effect then.
+func (m *Medication) Active(at time.Time) bool {
+	return m.EndDate == nil || m.EndDate.After(at)
+}
+
+// ActiveDuring reports whether a medication is taken at any time from from
+// until until, including one that starts or ends in between
+func (m *Medication) ActiveDuring(from, until time.Time) bool {
+	return m.StartDate.Before(until) && m.Active(from)
+}
+
+// Medication history actions
+const (
+	MedicationPrescribed   = "prescribed"
+	MedicationUpdated      = "updated"
+	MedicationDiscontinued = "discontinued"
+	MedicationImported     = "imported" // Created before history was kept
+)
+
+// MedicationVersion is a medication as it was after one change. Versions
+// are never edited or deleted.
+type MedicationVersion struct {
+	ID                 string           `gorm:"type:char(36);primary_key" json:"id"`
+	MedicationID       string           `gorm:"type:char(36);uniqueIndex:idx_medication_version;not null" json:"medication_id"`
+	Version            int              `gorm:"uniqueIndex:idx_medication_version;not null" json:"version"`
+	Action             string           `gorm:"not null" json:"action"`
+	Name               string           `json:"name"`
+	Dosage             string           `json:"dosage"`
+	Frequency          string           `json:"frequency"`
+	Instructions       string           `json:"instructions"`
+	Schedule           *dosing.Schedule `gorm:"type:text;serializer:json" json:"schedule"`
+	StartDate          time.Time        `json:"start_date"`
+	EndDate            *time.Time       `json:"end_date,omitempty"`
+	PrescribedByID     *string          `gorm:"type:char(36)" json:"prescribed_by_id"`
+	DiscontinuedReason string           `json:"discontinued_reason,omitempty"`
+	ChangedByID        *string          `gorm:"type:char(36)" json:"changed_by_id"` // Physician who made the change
+	ChangedBy          *Physician       `gorm:"foreignKey:ChangedByID" json:"changed_by,omitempty"`
+	Reason             string           `json:"reason,omitempty"` // Why the change was made
+	CreatedAt          time.Time        `json:"created_at"`
+}
+
+// BeforeCreate hook to generate UUID
+func (v *MedicationVersion) BeforeCreate(tx *gorm.DB) error {
+	if v.ID == "" {
+		v.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// Snapshot records the medication's current state as its latest version
+func (m *Medication) Snapshot(action string, changedByID *string, reason string) MedicationVersion {
+	return MedicationVersion{
+		MedicationID:       m.ID,
+		Version:            m.Version,
+		Action:             action,
+		Name:               m.Name,
+		Dosage:             m.Dosage,
+		Frequency:          m.Frequency,
+		Instructions:       m.Instructions,
+		Schedule:           m.Schedule,
+		StartDate:          m.StartDate,
+		EndDate:            m.EndDate,
+		PrescribedByID:     m.PrescribedByID,
+		DiscontinuedReason: m.DiscontinuedReason,
+		ChangedByID:        changedByID,
+		Reason:             reason,
+	}
+}
+
+// prescriberTitle matches titles older versions stored before prescriber names
+var prescriberTitle = regexp.MustCompile(`(?i)^dr\.?\s+`)
+
+// MigrateMedicationPrescribers links medications from older versions, whose
+// prescriber was free text, to the physician with that ID or name, records
+// them as the first version of their history, and drops the text column.
+// Prescribers that match no physician are kept in the history's reason.
+func MigrateMedicationPrescribers(db *gorm.DB) error {
+	if !db.Migrator().HasColumn(&Medication{}, "prescribed_by") {
+		return nil
+	}
+
+	var physicians []Physician
+	if err := db.Find(&physicians).Error; err != nil {
+		return err
+	}
+	byName := make(map[string]string, len(physicians))
+	for _, physician := range physicians {
+		byName[strings.ToLower(prescriberTitle.ReplaceAllString(strings.TrimSpace(physician.Name), ""))] = physician.ID
+		byName[strings.ToLower(physician.ID)] = physician.ID
+	}
+
+	var legacy []struct {
+		ID           string
+		PrescribedBy string
+	}
+	if err := db.Table("medications").Select("id, prescribed_by").Scan(&legacy).Error; err != nil {
+		return err
+	}
+
+	unmatched := 0
+	err := db.Transaction(func(tx *gorm.DB) error {
+		for _, row := range legacy {
+			var medication Medication
+			if err := tx.Unscoped().Where("id = ?", row.ID).First(&medication).Error; err != nil {
+				return err
+			}
+
+			reason := "Recorded before medication history was kept"
+			text := strings.TrimSpace(row.PrescribedBy)
+			if physicianID, ok := byName[strings.ToLower(prescriberTitle.ReplaceAllString(text, ""))]; ok && text != "" {
+				medication.PrescribedByID = &physicianID
+				if err := tx.Unscoped().Model(&medication).Update("prescribed_by_id", physicianID).Error; err != nil {
+					return err
+				}
+			} else if text != "" {
+				reason += "; prescribed by " + text
+				unmatched++
+			}
+
+			version := medication.Snapshot(MedicationImported, nil, reason)
+			if err := tx.Where("medication_id = ? AND version = ?", medication.ID, medication.Version).FirstOrCreate(&version).Error; err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	if err := db.Migrator().DropColumn(&Medication{}, "prescribed_by"); err != nil {
+		return err
+	}
+	// SQLite drops columns by rebuilding the table, which loses its indexes
+	if err := db.AutoMigrate(&Medication{}); err != nil {
+		return err
+	}
+	log.Printf("Migrated %d medication prescribers (%d did not match a physician)", len(legacy), unmatched)
+	return nil
+}
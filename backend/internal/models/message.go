@@ -17,17 +17,118 @@ type Message struct {
 	Content     string     `gorm:"type:text" json:"content"`
 	SentAt      time.Time  `json:"sent_at"`
 	Read        bool       `gorm:"default:false" json:"read"`
-	SenderType  string     `gorm:"not null" json:"sender_type"` // "patient" or "physician"
+	SenderType  string     `gorm:"not null" json:"sender_type"` // "patient", "physician" or "system" for automatic replies
+	SentByID    *string    `gorm:"type:char(36)" json:"sent_by_id,omitempty"` // Physician who sent it when not PhysicianID: a covering or care-team physician
+	Urgency     string     `gorm:"default:routine;index" json:"urgency"` // "routine", "urgent" or "emergency"
+	Category    string     `gorm:"default:other" json:"category"`        // "refill", "billing", "symptoms", "appointment" or "other"
+	UrgencyReason string   `json:"urgency_reason,omitempty"`             // Phrases that raised the urgency
+	ParentID    *string    `gorm:"type:char(36);index" json:"parent_id,omitempty"` // Message this one replies to
+	ThreadID    string     `gorm:"type:char(36);index" json:"thread_id"`           // ID of the first message in the conversation
+	Receipts    []MessageReceipt `gorm:"foreignKey:MessageID" json:"receipts,omitempty"`
+	Attachments []Attachment `gorm:"foreignKey:MessageID" json:"attachments,omitempty"`
 	CreatedAt   time.Time  `json:"created_at"`
 	UpdatedAt   time.Time `json:"updated_at"`
 	DeletedAt   gorm.DeletedAt `gorm:"index" json:"deleted_at,omitempty"`
 }
 
+// SenderSystem marks messages sent automatically by Health Connect
+const SenderSystem = "system"
+
 // BeforeCreate hook to generate UUID
 func (m *Message) BeforeCreate(tx *gorm.DB) error {
 	if m.ID == "" {
 		m.ID = uuid.New().String()
 	}
+	if m.ThreadID == "" {
+		m.ThreadID = m.ID
+	}
+	return nil
+}
+
+// BackfillMessageThreads makes messages created before threading the root
+// of their own thread
+func BackfillMessageThreads(db *gorm.DB) error {
+	return db.Model(&Message{}).
+		Where("thread_id IS NULL OR thread_id = ''").
+		Update("thread_id", gorm.Expr("id")).Error
+}
+
+// MessageReceipt tracks whether one recipient has read a message
+type MessageReceipt struct {
+	ID            string     `gorm:"type:char(36);primary_key" json:"id"`
+	MessageID     string     `gorm:"type:char(36);uniqueIndex:idx_receipt_message_recipient;not null" json:"message_id"`
+	RecipientID   string     `gorm:"type:char(36);uniqueIndex:idx_receipt_message_recipient;index;not null" json:"recipient_id"`
+	RecipientType string     `gorm:"not null" json:"recipient_type"` // "patient" or "physician"
+	ThreadID      string     `gorm:"type:char(36);index" json:"thread_id"`
+	ReadAt        *time.Time `json:"read_at"`
+	CreatedAt     time.Time  `json:"created_at"`
+}
+
+// BeforeCreate hook to generate UUID
+func (r *MessageReceipt) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
 	return nil
 }
 
+// SenderID returns the ID of the patient or physician who sent a message,
+// or "" for automatic replies
+func (m *Message) SenderID() string {
+	switch {
+	case m.SenderType == "patient" && m.PatientID != nil:
+		return *m.PatientID
+	case m.SenderType == "physician" && m.SentByID != nil:
+		return *m.SentByID
+	case m.SenderType == "physician" && m.PhysicianID != nil:
+		return *m.PhysicianID
+	}
+	return ""
+}
+
+// Recipient returns the ID and type of the participant who receives a
+// one-to-one message. Automatic replies go to the patient.
+func (m *Message) Recipient() (string, string) {
+	if m.SenderType == SenderSystem && m.PatientID != nil {
+		return *m.PatientID, "patient"
+	}
+	if m.SenderType == "patient" && m.PhysicianID != nil {
+		return *m.PhysicianID, "physician"
+	}
+	if m.SenderType == "physician" && m.PatientID != nil {
+		return *m.PatientID, "patient"
+	}
+	return "", ""
+}
+
+// BackfillMessageReceipts creates receipts for messages sent before read
+// receipts existed, treating messages already marked read as read now
+func BackfillMessageReceipts(db *gorm.DB) error {
+	var messages []Message
+	err := db.Where("NOT EXISTS (SELECT 1 FROM message_receipts WHERE message_receipts.message_id = messages.id)").
+		Find(&messages).Error
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, message := range messages {
+		recipientID, recipientType := message.Recipient()
+		if recipientID == "" {
+			continue
+		}
+		receipt := MessageReceipt{
+			MessageID:     message.ID,
+			RecipientID:   recipientID,
+			RecipientType: recipientType,
+			ThreadID:      message.ThreadID,
+		}
+		if message.Read {
+			receipt.ReadAt = &now
+		}
+		if err := db.Create(&receipt).Error; err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,48 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MFAEnrollment holds the TOTP secret protecting one role of an account. It
+// only protects logins once the user has confirmed it with a valid code.
+type MFAEnrollment struct {
+	ID           string     `gorm:"type:char(36);primary_key" json:"id"`
+	UserID       string     `gorm:"type:char(36);uniqueIndex:idx_mfa_user_role;not null" json:"user_id"`
+	Role         string     `gorm:"uniqueIndex:idx_mfa_user_role;not null" json:"role"`
+	Secret       string     `gorm:"not null" json:"-"`
+	Enabled      bool       `gorm:"default:false" json:"enabled"`
+	ConfirmedAt  *time.Time `json:"confirmed_at,omitempty"`
+	LastUsedStep int64      `json:"-"` // TOTP time step of the last accepted code, to stop replays
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+}
+
+// BeforeCreate hook to generate UUID
+func (e *MFAEnrollment) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == "" {
+		e.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// MFARecoveryCode is a hashed single-use code for signing in without the authenticator
+type MFARecoveryCode struct {
+	ID        string     `gorm:"type:char(36);primary_key" json:"id"`
+	UserID    string     `gorm:"type:char(36);index;not null" json:"user_id"`
+	Role      string     `gorm:"not null" json:"role"`
+	CodeHash  string     `gorm:"uniqueIndex;not null" json:"-"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+}
+
+// BeforeCreate hook to generate UUID
+func (r *MFARecoveryCode) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	return nil
+}
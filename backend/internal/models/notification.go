@@ -0,0 +1,177 @@
+package models
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+
+	"github.com/yourusername/health-connect/internal/dosing"
+)
+
+// Notification delivery statuses
+const (
+	DeliveryPending = "pending"
+	DeliverySent    = "sent"
+	DeliveryFailed  = "failed"
+)
+
+// Notification tells a patient or physician that something happened. Titles
+// and bodies never contain health information; the details are only shown
+// after signing in.
+type Notification struct {
+	ID            string     `gorm:"type:char(36);primary_key" json:"id"`
+	RecipientID   string     `gorm:"type:char(36);index:idx_notification_recipient;not null" json:"recipient_id"`
+	RecipientType string     `gorm:"index:idx_notification_recipient;not null" json:"recipient_type"` // "patient" or "physician"
+	Kind          string     `gorm:"not null" json:"kind"`
+	Title         string     `gorm:"not null" json:"title"`
+	Body          string     `json:"body"`
+	Link          string     `json:"link,omitempty"` // App path to open, e.g. /threads/:id
+	Urgent        bool       `json:"urgent"`
+	ReadAt        *time.Time `json:"read_at,omitempty"`
+	CreatedAt     time.Time  `gorm:"index" json:"created_at"`
+}
+
+// BeforeCreate hook to generate UUID
+func (n *Notification) BeforeCreate(tx *gorm.DB) error {
+	if n.ID == "" {
+		n.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// NotificationDelivery queues a notification for an outside channel (email
+// or SMS). Pending deliveries for the same recipient and channel are sent
+// together as a digest once the earliest is due.
+type NotificationDelivery struct {
+	ID             string        `gorm:"type:char(36);primary_key" json:"id"`
+	NotificationID string        `gorm:"type:char(36);index;not null" json:"notification_id"`
+	Notification   *Notification `gorm:"foreignKey:NotificationID" json:"notification,omitempty"`
+	RecipientID    string        `gorm:"type:char(36);index:idx_delivery_queue;not null" json:"recipient_id"`
+	RecipientType  string        `gorm:"index:idx_delivery_queue;not null" json:"recipient_type"`
+	Channel        string        `gorm:"index:idx_delivery_queue;not null" json:"channel"`
+	Status         string        `gorm:"index;not null;default:pending" json:"status"`
+	DueAt          time.Time     `gorm:"index" json:"due_at"`
+	Attempts       int           `json:"attempts"`
+	LastError      string        `json:"last_error,omitempty"`
+	SentAt         *time.Time    `json:"sent_at,omitempty"`
+	CreatedAt      time.Time     `json:"created_at"`
+}
+
+// BeforeCreate hook to generate UUID
+func (d *NotificationDelivery) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == "" {
+		d.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// NotificationPreference is how a patient or physician wants to be
+// notified. Quiet hours are local times in TimeZone ("22:00" to "07:00");
+// email and SMS wait until they end, except for urgent notifications.
+type NotificationPreference struct {
+	ID              string    `gorm:"type:char(36);primary_key" json:"-"`
+	OwnerID         string    `gorm:"type:char(36);uniqueIndex:idx_notification_preference_owner;not null" json:"-"`
+	OwnerType       string    `gorm:"uniqueIndex:idx_notification_preference_owner;not null" json:"-"`
+	EmailEnabled    bool      `json:"email_enabled"`
+	SMSEnabled      bool      `json:"sms_enabled"`
+	InAppEnabled    bool      `json:"in_app_enabled"`    // Live push only; notifications are listed either way
+	Phone           string    `json:"phone"`             // E.164, required for SMS
+	QuietHoursStart string    `json:"quiet_hours_start"` // "HH:MM", empty for none
+	QuietHoursEnd   string    `json:"quiet_hours_end"`
+	TimeZone        string    `json:"time_zone"`      // IANA name, e.g. America/New_York
+	DigestMinutes   int       `json:"digest_minutes"` // How long to collect notifications into one email or SMS
+	UpdatedAt       time.Time `json:"updated_at"`
+}
+
+// BeforeCreate hook to generate UUID
+func (p *NotificationPreference) BeforeCreate(tx *gorm.DB) error {
+	if p.ID == "" {
+		p.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// DefaultDigestMinutes batches bursts of activity into a single email
+const DefaultDigestMinutes = 15
+
+// FindNotificationPreference returns someone's preferences, or the
+// defaults (email and in-app on, SMS off, no quiet hours) if they have
+// not saved any
+func FindNotificationPreference(db *gorm.DB, ownerType, ownerID string) (*NotificationPreference, error) {
+	var preference NotificationPreference
+	err := db.Where("owner_id = ? AND owner_type = ?", ownerID, ownerType).First(&preference).Error
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return &NotificationPreference{
+			OwnerID:       ownerID,
+			OwnerType:     ownerType,
+			EmailEnabled:  true,
+			InAppEnabled:  true,
+			TimeZone:      "UTC",
+			DigestMinutes: DefaultDigestMinutes,
+		}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &preference, nil
+}
+
+// Location returns the preference's time zone, falling back to UTC
+func (p *NotificationPreference) Location() *time.Location {
+	if loc, err := time.LoadLocation(p.TimeZone); err == nil && p.TimeZone != "" {
+		return loc
+	}
+	return time.UTC
+}
+
+// Validate checks the quiet hours and time zone
+func (p *NotificationPreference) Validate() error {
+	if p.TimeZone != "" {
+		if _, err := time.LoadLocation(p.TimeZone); err != nil {
+			return fmt.Errorf("unknown time zone %q", p.TimeZone)
+		}
+	}
+	if (p.QuietHoursStart == "") != (p.QuietHoursEnd == "") {
+		return errors.New("quiet_hours_start and quiet_hours_end must be set together")
+	}
+	if p.QuietHoursStart != "" {
+		if _, err := dosing.ParseClock(p.QuietHoursStart); err != nil {
+			return err
+		}
+		if _, err := dosing.ParseClock(p.QuietHoursEnd); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// QuietUntil returns the end of the quiet hours that contain the time at.
+// Outside quiet hours it returns at unchanged. Quiet hours may span midnight.
+func (p *NotificationPreference) QuietUntil(at time.Time) time.Time {
+	if p.QuietHoursStart == "" {
+		return at
+	}
+	start, err1 := dosing.ParseClock(p.QuietHoursStart)
+	end, err2 := dosing.ParseClock(p.QuietHoursEnd)
+	if err1 != nil || err2 != nil || start == end {
+		return at
+	}
+
+	local := at.In(p.Location())
+	now := local.Hour()*60 + local.Minute()
+	endOn := func(days int) time.Time {
+		return time.Date(local.Year(), local.Month(), local.Day()+days, end/60, end%60, 0, 0, local.Location())
+	}
+	switch {
+	case start < end && now >= start && now < end:
+		return endOn(0)
+	case start > end && now >= start:
+		return endOn(1)
+	case start > end && now < end:
+		return endOn(0)
+	}
+	return at
+}
@@ -0,0 +1,46 @@
+package models
+
+import (
+	"testing"
+	"time"
+	_ "time/tzdata"
+)
+
+func TestQuietUntil(t *testing.T) {
+	loc, err := time.LoadLocation("America/New_York")
+	if err != nil {
+		t.Fatal(err)
+	}
+	at := func(day, hour, minute int) time.Time {
+		return time.Date(2026, 6, day, hour, minute, 0, 0, loc)
+	}
+
+	overnight := &NotificationPreference{QuietHoursStart: "22:00", QuietHoursEnd: "07:00", TimeZone: "America/New_York"}
+	daytime := &NotificationPreference{QuietHoursStart: "09:00", QuietHoursEnd: "17:30", TimeZone: "America/New_York"}
+	tests := []struct {
+		name       string
+		preference *NotificationPreference
+		at, want   time.Time
+	}{
+		{"overnight, before start", overnight, at(10, 21, 59), at(10, 21, 59)},
+		{"overnight, at start", overnight, at(10, 22, 0), at(11, 7, 0)},
+		{"overnight, before midnight", overnight, at(10, 23, 30), at(11, 7, 0)},
+		{"overnight, after midnight", overnight, at(11, 2, 15), at(11, 7, 0)},
+		{"overnight, at end", overnight, at(11, 7, 0), at(11, 7, 0)},
+		{"daytime, inside", daytime, at(10, 12, 0), at(10, 17, 30)},
+		{"daytime, after end", daytime, at(10, 18, 0), at(10, 18, 0)},
+		{"none", &NotificationPreference{TimeZone: "America/New_York"}, at(10, 23, 0), at(10, 23, 0)},
+		{"same start and end", &NotificationPreference{QuietHoursStart: "08:00", QuietHoursEnd: "08:00"}, at(10, 8, 0), at(10, 8, 0)},
+	}
+	for _, tt := range tests {
+		if got := tt.preference.QuietUntil(tt.at); !got.Equal(tt.want) {
+			t.Errorf("%s: QuietUntil(%v) = %v, want %v", tt.name, tt.at, got, tt.want)
+		}
+	}
+
+	// Times are read in the preference's time zone, not the caller's
+	utc := at(11, 2, 15).UTC()
+	if got := overnight.QuietUntil(utc); !got.Equal(at(11, 7, 0)) {
+		t.Errorf("QuietUntil(%v) = %v, want %v", utc, got, at(11, 7, 0))
+	}
+}
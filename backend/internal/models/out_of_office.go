@@ -0,0 +1,80 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// OutOfOffice is a period when a physician is away. Patient messages sent
+// during it get an automatic reply and are copied to the covering physician.
+type OutOfOffice struct {
+	ID                  string         `gorm:"type:char(36);primary_key" json:"id"`
+	PhysicianID         string         `gorm:"type:char(36);index;not null" json:"physician_id"`
+	StartsAt            time.Time      `gorm:"index;not null" json:"starts_at"`
+	EndsAt              time.Time      `gorm:"index;not null" json:"ends_at"`
+	AutoReply           string         `gorm:"type:text;not null" json:"auto_reply"`
+	CoveringPhysicianID *string        `gorm:"type:char(36);index" json:"covering_physician_id,omitempty"`
+	CoveringPhysician   *Physician     `gorm:"foreignKey:CoveringPhysicianID" json:"covering_physician,omitempty"`
+	CreatedAt           time.Time      `json:"created_at"`
+	UpdatedAt           time.Time      `json:"updated_at"`
+	DeletedAt           gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// BeforeCreate hook to generate UUID
+func (o *OutOfOffice) BeforeCreate(tx *gorm.DB) error {
+	if o.ID == "" {
+		o.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// ActiveOutOfOffice returns the physician's out-of-office period covering
+// the given time, or gorm.ErrRecordNotFound
+func ActiveOutOfOffice(db *gorm.DB, physicianID string, at time.Time) (*OutOfOffice, error) {
+	var period OutOfOffice
+	err := db.Where("physician_id = ? AND starts_at <= ? AND ends_at > ?", physicianID, at, at).
+		Preload("CoveringPhysician").
+		First(&period).Error
+	if err != nil {
+		return nil, err
+	}
+	return &period, nil
+}
+
+// ThreadCoverage records that a conversation received an out-of-office
+// reply, and gives the covering physician access to it until ExpiresAt
+type ThreadCoverage struct {
+	ID                  string    `gorm:"type:char(36);primary_key" json:"id"`
+	ThreadID            string    `gorm:"type:char(36);uniqueIndex:idx_coverage_thread_period;not null" json:"thread_id"`
+	OutOfOfficeID       string    `gorm:"type:char(36);uniqueIndex:idx_coverage_thread_period;not null" json:"out_of_office_id"`
+	PhysicianID         string    `gorm:"type:char(36);not null" json:"physician_id"`
+	CoveringPhysicianID *string   `gorm:"type:char(36);index" json:"covering_physician_id,omitempty"`
+	ExpiresAt           time.Time `gorm:"index;not null" json:"expires_at"`
+	CreatedAt           time.Time `json:"created_at"`
+}
+
+// BeforeCreate hook to generate UUID
+func (t *ThreadCoverage) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// CoveredThreads selects the IDs of the conversations a physician currently covers
+func CoveredThreads(db *gorm.DB, physicianID string) *gorm.DB {
+	return db.Model(&ThreadCoverage{}).
+		Select("thread_id").
+		Where("covering_physician_id = ? AND expires_at > ?", physicianID, time.Now())
+}
+
+// IsCoveringThread reports whether a physician currently covers a conversation
+func IsCoveringThread(db *gorm.DB, physicianID, threadID string) (bool, error) {
+	var count int64
+	err := db.Model(&ThreadCoverage{}).
+		Where("thread_id = ? AND covering_physician_id = ? AND expires_at > ?", threadID, physicianID, time.Now()).
+		Count(&count).Error
+	return count > 0, err
+}
@@ -11,11 +11,10 @@ type Patient struct {
 	ID           string       `gorm:"type:char(36);primary_key" json:"id"`
 	Username     string       `gorm:"uniqueIndex;not null" json:"username"`
 	Email        string       `gorm:"uniqueIndex;not null" json:"email"`
-	Password     string       `gorm:"not null" json:"-"`
+	AccountID    string       `gorm:"type:char(36);uniqueIndex" json:"-"`
 	Name         string       `json:"name"`
 	Address      string       `json:"address"`
 	HasInsurance bool         `gorm:"default:false" json:"has_insurance"`
-	Verified     bool         `gorm:"default:true" json:"verified"` // Auto-verified for now
 	Medications  []Medication `gorm:"foreignKey:PatientID" json:"medications,omitempty"`
 	Messages     []Message    `gorm:"foreignKey:PatientID" json:"messages,omitempty"`
 	Physicians   []Physician  `gorm:"many2many:patient_physicians;" json:"physicians,omitempty"`
@@ -32,3 +31,12 @@ func (p *Patient) BeforeCreate(tx *gorm.DB) error {
 	return nil
 }
 
+
+// IsPatientLinked reports whether a patient and physician are linked through patient_physicians
+func IsPatientLinked(db *gorm.DB, patientID, physicianID string) (bool, error) {
+	var count int64
+	err := db.Table("patient_physicians").
+		Where("patient_id = ? AND physician_id = ?", patientID, physicianID).
+		Count(&count).Error
+	return count > 0, err
+}
@@ -7,16 +7,27 @@ import (
 	"gorm.io/gorm"
 )
 
+const (
+	LicenseStatusPending  = "pending"
+	LicenseStatusApproved = "approved"
+	LicenseStatusRejected = "rejected"
+)
+
 type Physician struct {
 	ID            string     `gorm:"type:char(36);primary_key" json:"id"`
 	Username      string     `gorm:"uniqueIndex;not null" json:"username"`
 	Email         string     `gorm:"uniqueIndex;not null" json:"email"`
-	Password      string     `gorm:"not null" json:"-"`
+	AccountID     string     `gorm:"type:char(36);uniqueIndex" json:"-"`
 	Name          string     `json:"name"`
 	Address       string     `json:"address"`
 	License       string     `gorm:"uniqueIndex;not null" json:"license"`
+	LicenseState  string     `gorm:"size:2" json:"license_state"`
+	NPI           *string    `gorm:"uniqueIndex" json:"npi,omitempty"`
+	LicenseStatus string     `gorm:"default:pending;index" json:"license_status"` // pending, approved or rejected
+	LicenseReviewedBy *string    `gorm:"type:char(36)" json:"license_reviewed_by,omitempty"`
+	LicenseReviewedAt *time.Time `json:"license_reviewed_at,omitempty"`
+	LicenseRejectionReason string `json:"license_rejection_reason,omitempty"`
 	OfficeLocation string    `json:"office_location"`
-	Verified      bool       `gorm:"default:true" json:"verified"` // Auto-verified for now
 	Messages      []Message  `gorm:"foreignKey:PhysicianID" json:"messages,omitempty"`
 	Patients      []Patient  `gorm:"many2many:patient_physicians;" json:"patients,omitempty"`
 	Specialties   []Specialty `gorm:"many2many:physician_specialties;" json:"specialties,omitempty"`
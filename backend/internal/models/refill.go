@@ -0,0 +1,83 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// Refill request states
+const (
+	RefillRequested      = "requested"
+	RefillApproved       = "approved"
+	RefillDenied         = "denied"
+	RefillSentToPharmacy = "sent_to_pharmacy"
+)
+
+// refillTransitions lists the states each refill request state can move to
+var refillTransitions = map[string][]string{
+	RefillRequested: {RefillApproved, RefillDenied},
+	RefillApproved:  {RefillSentToPharmacy},
+}
+
+// RefillRequest is a patient's request for more of one of their
+// medications, reviewed by one of their physicians. Each step is also
+// posted to the conversation in ThreadID.
+type RefillRequest struct {
+	ID            string      `gorm:"type:char(36);primary_key" json:"id"`
+	PatientID     string      `gorm:"type:char(36);index;not null" json:"patient_id"`
+	Patient       *Patient    `gorm:"foreignKey:PatientID" json:"patient,omitempty"`
+	MedicationID  string      `gorm:"type:char(36);index;not null" json:"medication_id"`
+	Medication    *Medication `gorm:"foreignKey:MedicationID" json:"medication,omitempty"`
+	PhysicianID   string      `gorm:"type:char(36);index;not null" json:"physician_id"` // Physician asked to review it
+	Physician     *Physician  `gorm:"foreignKey:PhysicianID" json:"physician,omitempty"`
+	Status        string      `gorm:"index;not null;default:requested" json:"status"`
+	Pharmacy      string      `json:"pharmacy,omitempty"`
+	PatientNote   string      `json:"patient_note,omitempty"`
+	PhysicianNote string      `json:"physician_note,omitempty"` // Why it was approved or denied
+	ReviewedByID  *string     `gorm:"type:char(36)" json:"reviewed_by_id,omitempty"`
+	ReviewedAt    *time.Time  `json:"reviewed_at,omitempty"`
+	SentAt        *time.Time  `json:"sent_at,omitempty"` // When it was sent to the pharmacy
+	ThreadID      string      `gorm:"type:char(36);index" json:"thread_id"`
+	CreatedAt     time.Time   `json:"created_at"`
+	UpdatedAt     time.Time   `json:"updated_at"`
+}
+
+// BeforeCreate hook to generate UUID
+func (r *RefillRequest) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// IsRefillStatus reports whether status is a refill request state
+func IsRefillStatus(status string) bool {
+	switch status {
+	case RefillRequested, RefillApproved, RefillDenied, RefillSentToPharmacy:
+		return true
+	}
+	return false
+}
+
+// CanMoveTo reports whether a refill request may move to a state
+func (r *RefillRequest) CanMoveTo(status string) bool {
+	for _, next := range refillTransitions[r.Status] {
+		if next == status {
+			return true
+		}
+	}
+	return false
+}
+
+// OpenRefillStates are the states of refill requests still in progress
+var OpenRefillStates = []string{RefillRequested, RefillApproved}
+
+// SetupOpenRefillIndex makes sure a medication has at most one refill
+// request in progress, even when two are made at once
+func SetupOpenRefillIndex(db *gorm.DB) error {
+	return db.Exec(`CREATE UNIQUE INDEX IF NOT EXISTS idx_refill_requests_open
+		ON refill_requests (medication_id)
+		WHERE status IN ('` + RefillRequested + `', '` + RefillApproved + `')`).Error
+}
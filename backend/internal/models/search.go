@@ -0,0 +1,69 @@
+package models
+
+import (
+	"errors"
+	"strings"
+
+	"gorm.io/gorm"
+)
+
+// ErrSearchUnavailable means SQLite was built without FTS5. Build with
+// -tags sqlite_fts5 to enable message search.
+var ErrSearchUnavailable = errors.New("message search requires SQLite FTS5 (build with -tags sqlite_fts5)")
+
+// MessageSearchTable is the FTS5 index over message subjects and content
+const MessageSearchTable = "messages_fts"
+
+// messageSearchTriggers keep the index in step with messages. Soft-deleted
+// messages are removed from the index.
+var messageSearchTriggers = []string{
+	`CREATE TRIGGER IF NOT EXISTS messages_fts_insert AFTER INSERT ON messages
+	WHEN new.deleted_at IS NULL
+	BEGIN
+		INSERT INTO messages_fts (message_id, subject, content) VALUES (new.id, new.subject, new.content);
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS messages_fts_update AFTER UPDATE OF subject, content, deleted_at ON messages
+	BEGIN
+		DELETE FROM messages_fts WHERE message_id = old.id;
+		INSERT INTO messages_fts (message_id, subject, content)
+		SELECT new.id, new.subject, new.content WHERE new.deleted_at IS NULL;
+	END`,
+	`CREATE TRIGGER IF NOT EXISTS messages_fts_delete AFTER DELETE ON messages
+	BEGIN
+		DELETE FROM messages_fts WHERE message_id = old.id;
+	END`,
+}
+
+// SetupMessageSearch creates the FTS5 index and its triggers, indexing
+// existing messages the first time. It returns ErrSearchUnavailable when
+// SQLite has no FTS5 support.
+func SetupMessageSearch(db *gorm.DB) error {
+	exists := db.Migrator().HasTable(MessageSearchTable)
+
+	return db.Transaction(func(tx *gorm.DB) error {
+		err := tx.Exec(`CREATE VIRTUAL TABLE IF NOT EXISTS messages_fts USING fts5(
+			message_id UNINDEXED,
+			subject,
+			content,
+			tokenize = 'porter unicode61 remove_diacritics 2'
+		)`).Error
+		if err != nil {
+			if strings.Contains(err.Error(), "no such module: fts5") {
+				return ErrSearchUnavailable
+			}
+			return err
+		}
+
+		for _, trigger := range messageSearchTriggers {
+			if err := tx.Exec(trigger).Error; err != nil {
+				return err
+			}
+		}
+
+		if exists {
+			return nil
+		}
+		return tx.Exec(`INSERT INTO messages_fts (message_id, subject, content)
+			SELECT id, subject, content FROM messages WHERE deleted_at IS NULL`).Error
+	})
+}
@@ -0,0 +1,31 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// MessageTemplate is reusable message text with {{placeholders}}. Shared
+// templates can be used by every physician but only changed by their owner.
+type MessageTemplate struct {
+	ID          string         `gorm:"type:char(36);primary_key" json:"id"`
+	PhysicianID string         `gorm:"type:char(36);index;not null" json:"physician_id"` // Owner
+	Name        string         `gorm:"not null" json:"name"`
+	Subject     string         `json:"subject"`
+	Content     string         `gorm:"type:text;not null" json:"content"`
+	Category    string         `json:"category,omitempty"`
+	Shared      bool           `gorm:"default:false;index" json:"shared"`
+	CreatedAt   time.Time      `json:"created_at"`
+	UpdatedAt   time.Time      `json:"updated_at"`
+	DeletedAt   gorm.DeletedAt `gorm:"index" json:"-"`
+}
+
+// BeforeCreate hook to generate UUID
+func (t *MessageTemplate) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+	return nil
+}
@@ -0,0 +1,14 @@
+package models
+
+import "time"
+
+// LoginThrottle counts failed logins for one account or one client IP so
+// backoff and lockouts survive server restarts
+type LoginThrottle struct {
+	Key           string     `gorm:"primary_key" json:"key"` // "account:<email>" or "ip:<address>"
+	Failures      int        `gorm:"default:0" json:"failures"`
+	LastFailureAt time.Time  `json:"last_failure_at"`
+	LockedUntil   *time.Time `gorm:"index" json:"locked_until,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
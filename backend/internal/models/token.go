@@ -0,0 +1,57 @@
+package models
+
+import (
+	"time"
+
+	"github.com/google/uuid"
+	"gorm.io/gorm"
+)
+
+// RefreshToken is a single-use refresh token. Tokens rotated from the same
+// login share a FamilyID so reuse of an old token can revoke the whole chain.
+type RefreshToken struct {
+	ID            string     `gorm:"type:char(36);primary_key" json:"id"`
+	UserID        string     `gorm:"type:char(36);index;not null" json:"user_id"` // Account ID
+	Role          string     `gorm:"not null" json:"role"`                        // Role the session was signed in as
+	FamilyID      string     `gorm:"type:char(36);index;not null" json:"family_id"`
+	TokenHash     string     `gorm:"uniqueIndex;not null" json:"-"`
+	AccessTokenID string     `gorm:"type:char(36)" json:"-"` // jti of the access token issued alongside
+	ExpiresAt     time.Time  `json:"expires_at"`
+	RevokedAt     *time.Time `json:"revoked_at,omitempty"`
+	CreatedAt     time.Time  `json:"created_at"`
+	UpdatedAt     time.Time  `json:"updated_at"`
+}
+
+// BeforeCreate hook to generate UUID
+func (t *RefreshToken) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+	return nil
+}
+
+// RevokedToken records an access token (by jti) that must no longer be accepted
+type RevokedToken struct {
+	JTI       string    `gorm:"type:char(36);primary_key" json:"jti"`
+	ExpiresAt time.Time `gorm:"index" json:"expires_at"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// PasswordResetToken is a single-use token emailed to a user who forgot their password
+type PasswordResetToken struct {
+	ID        string     `gorm:"type:char(36);primary_key" json:"id"`
+	UserID    string     `gorm:"type:char(36);index;not null" json:"user_id"` // Account ID
+	TokenHash string     `gorm:"uniqueIndex;not null" json:"-"`
+	ExpiresAt time.Time  `json:"expires_at"`
+	UsedAt    *time.Time `json:"used_at,omitempty"`
+	CreatedAt time.Time  `json:"created_at"`
+	UpdatedAt time.Time  `json:"updated_at"`
+}
+
+// BeforeCreate hook to generate UUID
+func (t *PasswordResetToken) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == "" {
+		t.ID = uuid.New().String()
+	}
+	return nil
+}
@@ -0,0 +1,128 @@
+package notify
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/yourusername/health-connect/internal/mail"
+	"github.com/yourusername/health-connect/internal/models"
+	"github.com/yourusername/health-connect/internal/realtime"
+)
+
+// Channel names
+const (
+	ChannelEmail = "email"
+	ChannelSMS   = "sms"
+	ChannelInApp = "in_app"
+)
+
+// Recipient is who a notification is for and how to reach them
+type Recipient struct {
+	Type  string
+	ID    string
+	Email string
+	Phone string
+}
+
+// Digest is one or more notifications sent together
+type Digest struct {
+	Subject       string
+	Body          string
+	Notifications []models.Notification
+}
+
+// Channel delivers notifications to a recipient. Implementations must be
+// safe for concurrent use.
+type Channel interface {
+	Name() string
+	Send(to Recipient, digest Digest) error
+}
+
+// EmailChannel sends digests through a mail.Sender
+type EmailChannel struct {
+	Sender mail.Sender
+}
+
+func (EmailChannel) Name() string { return ChannelEmail }
+
+func (ch EmailChannel) Send(to Recipient, digest Digest) error {
+	if to.Email == "" {
+		return errors.New("no email address")
+	}
+	return ch.Sender.Send(mail.Message{To: to.Email, Subject: digest.Subject, Body: digest.Body})
+}
+
+// SMSSender delivers text messages. Implementations must be safe for concurrent use.
+type SMSSender interface {
+	Send(to, body string) error
+}
+
+// LogSMSSender writes text messages to the server log instead of sending them
+type LogSMSSender struct{}
+
+func (LogSMSSender) Send(to, body string) error {
+	log.Printf("sms: to=%s %q", to, body)
+	return nil
+}
+
+// FileSMSSender writes each text message to its own file in Dir, for local development
+type FileSMSSender struct {
+	Dir string
+	mu  sync.Mutex
+}
+
+func (s *FileSMSSender) Send(to, body string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err := os.MkdirAll(s.Dir, 0o700); err != nil {
+		return err
+	}
+	name := fmt.Sprintf("%d-%s.txt", time.Now().UnixNano(), strings.TrimPrefix(to, "+"))
+	return os.WriteFile(filepath.Join(s.Dir, name), []byte("To: "+to+"\n\n"+body+"\n"), 0o600)
+}
+
+// NewSMSSenderFromEnv writes text messages to SMS_DIR when it is set and
+// logs them otherwise
+func NewSMSSenderFromEnv() SMSSender {
+	if dir := os.Getenv("SMS_DIR"); dir != "" {
+		return &FileSMSSender{Dir: dir}
+	}
+	return LogSMSSender{}
+}
+
+// SMSChannel sends a one-line summary of each digest by text message
+type SMSChannel struct {
+	Sender SMSSender
+}
+
+func (SMSChannel) Name() string { return ChannelSMS }
+
+func (ch SMSChannel) Send(to Recipient, digest Digest) error {
+	if to.Phone == "" {
+		return errors.New("no phone number")
+	}
+	return ch.Sender.Send(to.Phone, "Health Connect: "+digest.Subject+". Sign in to view.")
+}
+
+// InAppChannel pushes notifications to the recipient's open sessions
+type InAppChannel struct {
+	Hub *realtime.Hub
+}
+
+func (InAppChannel) Name() string { return ChannelInApp }
+
+func (ch InAppChannel) Send(to Recipient, digest Digest) error {
+	for _, notification := range digest.Notifications {
+		if err := ch.Hub.Publish(to.Type, to.ID, realtime.EventNotification, notification); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,305 @@
+package notify
+
+import (
+	"fmt"
+	"log"
+	"sort"
+	"strings"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/yourusername/health-connect/internal/auth"
+	"github.com/yourusername/health-connect/internal/models"
+)
+
+// Notification kinds
+const (
+	KindMessage      = "message"
+	KindConversation = "conversation"
+	KindMedication   = "medication"
+	KindRefill       = "refill"        // To the patient when their request changes
+	KindRefillReview = "refill_review" // To the physician asked to review a request
+)
+
+// kindText is what each kind of notification says. It must never include
+// health information: notifications go to inboxes and phones that others
+// may see.
+var kindText = map[string]struct{ title, body string }{
+	KindMessage:      {"You have a new message", "Sign in to Health Connect to read it."},
+	KindConversation: {"You were added to a care-team conversation", "Sign in to Health Connect to view it."},
+	KindMedication:   {"Your medication list was updated", "Sign in to Health Connect to review it."},
+	KindRefill:       {"Your refill request was updated", "Sign in to Health Connect to see its status."},
+	KindRefillReview: {"You have a new refill request", "Sign in to Health Connect to review it."},
+}
+
+// urgentTitle replaces the title of urgent notifications
+const urgentTitle = "You have an urgent message"
+
+const (
+	// maxAttempts is how many times a delivery is tried before it fails
+	maxAttempts = 5
+
+	// retryDelay is multiplied by the attempt number between retries
+	retryDelay = 5 * time.Minute
+)
+
+// Dispatcher records notifications, pushes them in the app straight away
+// and queues email and SMS deliveries, which a background loop sends as
+// digests outside the recipient's quiet hours
+type Dispatcher struct {
+	DB       *gorm.DB
+	BaseURL  string // Frontend origin for links in emails
+	channels map[string]Channel
+}
+
+func NewDispatcher(db *gorm.DB, baseURL string, channels ...Channel) *Dispatcher {
+	d := &Dispatcher{DB: db, BaseURL: baseURL, channels: map[string]Channel{}}
+	for _, channel := range channels {
+		d.channels[channel.Name()] = channel
+	}
+	return d
+}
+
+// Notify tells a patient or physician that something happened. link is the
+// app path to open. Urgent notifications skip the digest delay and quiet
+// hours. Failures are logged, since notifications never block the action
+// that caused them.
+func (d *Dispatcher) Notify(recipientType, recipientID, kind, link string, urgent bool) {
+	if err := d.notify(recipientType, recipientID, kind, link, urgent); err != nil {
+		log.Printf("Failed to notify %s %s of %s: %v", recipientType, recipientID, kind, err)
+	}
+}
+
+func (d *Dispatcher) notify(recipientType, recipientID, kind, link string, urgent bool) error {
+	text, ok := kindText[kind]
+	if !ok {
+		return fmt.Errorf("unknown notification kind %q", kind)
+	}
+	preference, err := models.FindNotificationPreference(d.DB, recipientType, recipientID)
+	if err != nil {
+		return err
+	}
+
+	notification := models.Notification{
+		RecipientID:   recipientID,
+		RecipientType: recipientType,
+		Kind:          kind,
+		Title:         text.title,
+		Body:          text.body,
+		Link:          link,
+		Urgent:        urgent,
+	}
+	if urgent {
+		notification.Title = urgentTitle
+	}
+
+	now := time.Now()
+	due := preference.QuietUntil(now.Add(time.Duration(preference.DigestMinutes) * time.Minute))
+	if urgent {
+		due = now
+	}
+
+	var deliveries []models.NotificationDelivery
+	for name, enabled := range map[string]bool{
+		ChannelEmail: preference.EmailEnabled,
+		ChannelSMS:   preference.SMSEnabled && preference.Phone != "",
+	} {
+		if enabled && d.channels[name] != nil {
+			deliveries = append(deliveries, models.NotificationDelivery{
+				RecipientID:   recipientID,
+				RecipientType: recipientType,
+				Channel:       name,
+				Status:        models.DeliveryPending,
+				DueAt:         due,
+			})
+		}
+	}
+
+	err = d.DB.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Create(&notification).Error; err != nil {
+			return err
+		}
+		for i := range deliveries {
+			deliveries[i].NotificationID = notification.ID
+		}
+		if len(deliveries) == 0 {
+			return nil
+		}
+		return tx.Create(&deliveries).Error
+	})
+	if err != nil {
+		return err
+	}
+
+	// The notification is stored above regardless; turning in-app off only
+	// stops the live push
+	if inApp := d.channels[ChannelInApp]; inApp != nil && preference.InAppEnabled {
+		to := Recipient{Type: recipientType, ID: recipientID}
+		return inApp.Send(to, Digest{Subject: notification.Title, Body: notification.Body, Notifications: []models.Notification{notification}})
+	}
+	return nil
+}
+
+// Start sends due digests every interval in the background
+func (d *Dispatcher) Start(interval time.Duration) {
+	if interval <= 0 {
+		return
+	}
+	go func() {
+		for range time.Tick(interval) {
+			if err := d.Flush(time.Now()); err != nil {
+				log.Printf("Failed to send notifications: %v", err)
+			}
+		}
+	}()
+}
+
+// Flush sends every queued delivery whose recipient and channel has one
+// due, batching the rest of that recipient's pending notifications into
+// the same digest
+func (d *Dispatcher) Flush(now time.Time) error {
+	var queues []struct {
+		RecipientType string
+		RecipientID   string
+		Channel       string
+	}
+	err := d.DB.Model(&models.NotificationDelivery{}).
+		Select("recipient_type, recipient_id, channel").
+		Where("status = ? AND due_at <= ?", models.DeliveryPending, now).
+		Group("recipient_type, recipient_id, channel").
+		Scan(&queues).Error
+	if err != nil {
+		return err
+	}
+
+	for _, queue := range queues {
+		if err := d.sendQueue(queue.RecipientType, queue.RecipientID, queue.Channel, now); err != nil {
+			log.Printf("Failed to send %s notifications to %s %s: %v", queue.Channel, queue.RecipientType, queue.RecipientID, err)
+		}
+	}
+	return nil
+}
+
+// sendQueue sends one recipient's pending deliveries on a channel as a single digest
+func (d *Dispatcher) sendQueue(recipientType, recipientID, channelName string, now time.Time) error {
+	var deliveries []models.NotificationDelivery
+	err := d.DB.Where("recipient_type = ? AND recipient_id = ? AND channel = ? AND status = ?",
+		recipientType, recipientID, channelName, models.DeliveryPending).
+		Preload("Notification").
+		Order("created_at ASC").
+		Find(&deliveries).Error
+	if err != nil || len(deliveries) == 0 {
+		return err
+	}
+	ids := make([]string, len(deliveries))
+	notifications := make([]models.Notification, 0, len(deliveries))
+	urgent := false
+	for i, delivery := range deliveries {
+		ids[i] = delivery.ID
+		if delivery.Notification != nil {
+			notifications = append(notifications, *delivery.Notification)
+			urgent = urgent || delivery.Notification.Urgent
+		}
+	}
+	pending := d.DB.Model(&models.NotificationDelivery{}).Where("id IN ?", ids)
+
+	// Preferences may have changed since the notifications were queued
+	preference, err := models.FindNotificationPreference(d.DB, recipientType, recipientID)
+	if err != nil {
+		return err
+	}
+	channel := d.channels[channelName]
+	enabled := (channelName == ChannelEmail && preference.EmailEnabled) ||
+		(channelName == ChannelSMS && preference.SMSEnabled && preference.Phone != "")
+	if channel == nil || !enabled {
+		return pending.Updates(map[string]interface{}{"status": models.DeliveryFailed, "last_error": "channel disabled"}).Error
+	}
+	if quietUntil := preference.QuietUntil(now); quietUntil.After(now) && !urgent {
+		return pending.Update("due_at", quietUntil).Error
+	}
+
+	to, err := d.recipient(recipientType, recipientID, preference)
+	if err != nil {
+		return err
+	}
+
+	sendErr := channel.Send(*to, d.digest(notifications))
+	if sendErr == nil {
+		return pending.Updates(map[string]interface{}{"status": models.DeliverySent, "sent_at": now, "attempts": gorm.Expr("attempts + 1")}).Error
+	}
+
+	attempts := deliveries[0].Attempts + 1
+	updates := map[string]interface{}{"attempts": attempts, "last_error": sendErr.Error(), "due_at": now.Add(time.Duration(attempts) * retryDelay)}
+	if attempts >= maxAttempts {
+		updates["status"] = models.DeliveryFailed
+	}
+	if err := pending.Updates(updates).Error; err != nil {
+		return err
+	}
+	return sendErr
+}
+
+// recipient looks up where to send a patient's or physician's notifications
+func (d *Dispatcher) recipient(recipientType, recipientID string, preference *models.NotificationPreference) (*Recipient, error) {
+	to := &Recipient{Type: recipientType, ID: recipientID, Phone: preference.Phone}
+
+	var err error
+	switch recipientType {
+	case auth.RolePatient:
+		var patient models.Patient
+		err = d.DB.Where("id = ?", recipientID).First(&patient).Error
+		to.Email = patient.Email
+	case auth.RolePhysician:
+		var physician models.Physician
+		err = d.DB.Where("id = ?", recipientID).First(&physician).Error
+		to.Email = physician.Email
+	default:
+		err = fmt.Errorf("unknown recipient type %q", recipientType)
+	}
+	if err != nil {
+		return nil, err
+	}
+	return to, nil
+}
+
+// digest summarises notifications for email or SMS. Like the notifications
+// themselves it only says what kind of thing happened.
+func (d *Dispatcher) digest(notifications []models.Notification) Digest {
+	link := strings.TrimRight(d.BaseURL, "/")
+	if len(notifications) == 1 {
+		n := notifications[0]
+		return Digest{
+			Subject:       n.Title,
+			Body:          n.Title + ".\n\n" + n.Body + "\n\n" + link + n.Link,
+			Notifications: notifications,
+		}
+	}
+
+	counts := map[string]int{}
+	for _, n := range notifications {
+		counts[n.Title]++
+	}
+	titles := make([]string, 0, len(counts))
+	for title := range counts {
+		titles = append(titles, title)
+	}
+	sort.Strings(titles)
+
+	var body strings.Builder
+	body.WriteString("Here is what happened since we last wrote:\n\n")
+	for _, title := range titles {
+		if counts[title] > 1 {
+			fmt.Fprintf(&body, "- %s (%d)\n", title, counts[title])
+		} else {
+			fmt.Fprintf(&body, "- %s\n", title)
+		}
+	}
+	body.WriteString("\nSign in to Health Connect to see them: " + link)
+
+	return Digest{
+		Subject:       fmt.Sprintf("You have %d new notifications", len(notifications)),
+		Body:          body.String(),
+		Notifications: notifications,
+	}
+}
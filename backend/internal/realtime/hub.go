@@ -0,0 +1,216 @@
+package realtime
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"time"
+
+	"gorm.io/gorm"
+
+	"github.com/yourusername/health-connect/internal/models"
+)
+
+const (
+	EventMessageCreated = "message.created"
+	EventMessageRead    = "message.read"
+	EventTyping         = "typing"
+
+	EventAttachmentsAdded = "message.attachments"
+
+	EventConversationUpdated = "conversation.updated"
+	EventNotification        = "notification"
+)
+
+const (
+	// EventRetention is how long delivered events stay available for replay
+	EventRetention = 7 * 24 * time.Hour
+
+	// subscriberBuffer is how many events a slow connection may fall behind
+	// before it is dropped and has to reconnect with its cursor
+	subscriberBuffer = 64
+
+	replayPageSize = 500
+
+	// purgeInterval is how often expired events are deleted
+	purgeInterval = time.Hour
+)
+
+// Event is pushed to connected clients. Transient events (typing
+// indicators) have no ID and are not replayed.
+type Event struct {
+	ID   uint64          `json:"id,omitempty"`
+	Type string          `json:"type"`
+	Data json.RawMessage `json:"data"`
+}
+
+// Hub fans events out to the connections of each recipient. Events are
+// stored first so a reconnecting client can replay what it missed.
+type Hub struct {
+	DB *gorm.DB
+
+	// publishMu keeps stored events broadcast in ID order, since streams
+	// skip any event at or below the last ID they delivered
+	publishMu sync.Mutex
+
+	mu          sync.Mutex
+	subscribers map[string]map[chan Event]struct{}
+}
+
+func NewHub(db *gorm.DB) *Hub {
+	return &Hub{DB: db, subscribers: map[string]map[chan Event]struct{}{}}
+}
+
+func recipientKey(recipientType, recipientID string) string {
+	return recipientType + ":" + recipientID
+}
+
+// Subscribe registers a connection for a recipient. The channel is closed
+// when cancel is called or when the connection falls too far behind.
+func (h *Hub) Subscribe(recipientType, recipientID string) (<-chan Event, func()) {
+	key := recipientKey(recipientType, recipientID)
+	ch := make(chan Event, subscriberBuffer)
+
+	h.mu.Lock()
+	if h.subscribers[key] == nil {
+		h.subscribers[key] = map[chan Event]struct{}{}
+	}
+	h.subscribers[key][ch] = struct{}{}
+	h.mu.Unlock()
+
+	cancel := func() {
+		h.mu.Lock()
+		h.remove(key, ch)
+		h.mu.Unlock()
+	}
+	return ch, cancel
+}
+
+// remove unregisters and closes a subscriber channel. Callers hold h.mu.
+func (h *Hub) remove(key string, ch chan Event) {
+	subs, ok := h.subscribers[key]
+	if !ok {
+		return
+	}
+	if _, ok := subs[ch]; !ok {
+		return
+	}
+	delete(subs, ch)
+	close(ch)
+	if len(subs) == 0 {
+		delete(h.subscribers, key)
+	}
+}
+
+func (h *Hub) broadcast(recipientType, recipientID string, event Event) {
+	key := recipientKey(recipientType, recipientID)
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for ch := range h.subscribers[key] {
+		select {
+		case ch <- event:
+		default:
+			// Too slow; the client reconnects and replays from its cursor
+			h.remove(key, ch)
+		}
+	}
+}
+
+// Publish stores an event for a recipient and pushes it to their
+// connections. Only the IDs of messages, attachments and notifications are
+// stored; replay loads them again.
+func (h *Hub) Publish(recipientType, recipientID, eventType string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+	kept, err := storedPayload(eventType, data)
+	if err != nil {
+		return err
+	}
+	keptPayload, err := json.Marshal(kept)
+	if err != nil {
+		return err
+	}
+
+	stored := models.Event{
+		RecipientID:   recipientID,
+		RecipientType: recipientType,
+		Type:          eventType,
+		Payload:       string(keptPayload),
+	}
+	h.publishMu.Lock()
+	defer h.publishMu.Unlock()
+	if err := h.DB.Create(&stored).Error; err != nil {
+		return err
+	}
+
+	h.broadcast(recipientType, recipientID, Event{ID: stored.ID, Type: eventType, Data: payload})
+	return nil
+}
+
+// PublishTransient pushes an event to connected clients without storing it
+func (h *Hub) PublishTransient(recipientType, recipientID, eventType string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		return err
+	}
+
+	h.broadcast(recipientType, recipientID, Event{Type: eventType, Data: payload})
+	return nil
+}
+
+// Replay returns up to one page of stored events for a recipient after the
+// cursor, with their data loaded again, and the ID of the last event read
+// (0 when there were none). Events whose message or notification has since
+// been deleted are left out.
+func (h *Hub) Replay(recipientType, recipientID string, after uint64) ([]Event, uint64, error) {
+	var stored []models.Event
+	err := h.DB.Where("recipient_type = ? AND recipient_id = ? AND id > ?", recipientType, recipientID, after).
+		Order("id ASC").
+		Limit(replayPageSize).
+		Find(&stored).Error
+	if err != nil || len(stored) == 0 {
+		return nil, 0, err
+	}
+
+	events := make([]Event, 0, len(stored))
+	for _, e := range stored {
+		data, err := loadPayload(h.DB, e.Type, e.Payload)
+		if err != nil {
+			return nil, 0, err
+		}
+		if data != nil {
+			events = append(events, Event{ID: e.ID, Type: e.Type, Data: data})
+		}
+	}
+	return events, stored[len(stored)-1].ID, nil
+}
+
+// Latest returns the ID of the newest stored event for a recipient, or 0 if
+// they have none
+func (h *Hub) Latest(recipientType, recipientID string) (uint64, error) {
+	var latest uint64
+	err := h.DB.Model(&models.Event{}).
+		Select("COALESCE(MAX(id), 0)").
+		Where("recipient_type = ? AND recipient_id = ?", recipientType, recipientID).
+		Scan(&latest).Error
+	return latest, err
+}
+
+// StartPurge deletes expired events every hour in the background
+func (h *Hub) StartPurge() {
+	go func() {
+		for range time.Tick(purgeInterval) {
+			if err := PurgeExpiredEvents(h.DB); err != nil {
+				log.Printf("Failed to purge expired events: %v", err)
+			}
+		}
+	}()
+}
+
+// PurgeExpiredEvents deletes stored events older than EventRetention
+func PurgeExpiredEvents(db *gorm.DB) error {
+	return db.Where("created_at < ?", time.Now().Add(-EventRetention)).Delete(&models.Event{}).Error
+}
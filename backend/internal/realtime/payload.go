@@ -0,0 +1,98 @@
+package realtime
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+
+	"gorm.io/gorm"
+
+	"github.com/yourusername/health-connect/internal/models"
+)
+
+// AttachmentsAdded is the data of an EventAttachmentsAdded event
+type AttachmentsAdded struct {
+	MessageID   string              `json:"message_id"`
+	ThreadID    string              `json:"thread_id"`
+	Attachments []models.Attachment `json:"attachments"`
+}
+
+// reference is what is stored of an event that carries patient
+// information, so that the events table holds no message content
+type reference struct {
+	MessageID      string   `json:"message_id,omitempty"`
+	ThreadID       string   `json:"thread_id,omitempty"`
+	AttachmentIDs  []string `json:"attachment_ids,omitempty"`
+	NotificationID string   `json:"notification_id,omitempty"`
+}
+
+// storedPayload returns what is kept of an event's data for replay.
+// Messages, attachments and notifications are kept as their IDs and
+// loaded again by loadPayload; other events only carry IDs already.
+func storedPayload(eventType string, data interface{}) (interface{}, error) {
+	switch eventType {
+	case EventMessageCreated:
+		message, ok := data.(*models.Message)
+		if !ok {
+			return nil, fmt.Errorf("%s event data must be a message, got %T", eventType, data)
+		}
+		return reference{MessageID: message.ID}, nil
+	case EventAttachmentsAdded:
+		added, ok := data.(AttachmentsAdded)
+		if !ok {
+			return nil, fmt.Errorf("%s event data must be AttachmentsAdded, got %T", eventType, data)
+		}
+		ref := reference{MessageID: added.MessageID, ThreadID: added.ThreadID, AttachmentIDs: []string{}}
+		for _, attachment := range added.Attachments {
+			ref.AttachmentIDs = append(ref.AttachmentIDs, attachment.ID)
+		}
+		return ref, nil
+	case EventNotification:
+		notification, ok := data.(models.Notification)
+		if !ok {
+			return nil, fmt.Errorf("%s event data must be a notification, got %T", eventType, data)
+		}
+		return reference{NotificationID: notification.ID}, nil
+	}
+	return data, nil
+}
+
+// loadPayload rebuilds the data of a stored event. It returns nil when
+// what the event refers to has since been deleted.
+func loadPayload(db *gorm.DB, eventType, payload string) (json.RawMessage, error) {
+	if eventType != EventMessageCreated && eventType != EventAttachmentsAdded && eventType != EventNotification {
+		return json.RawMessage(payload), nil
+	}
+
+	var ref reference
+	if err := json.Unmarshal([]byte(payload), &ref); err != nil {
+		return nil, err
+	}
+
+	var data interface{}
+	var err error
+	switch eventType {
+	case EventMessageCreated:
+		var message models.Message
+		err = db.Where("id = ?", ref.MessageID).First(&message).Error
+		data = &message
+	case EventAttachmentsAdded:
+		added := AttachmentsAdded{MessageID: ref.MessageID, ThreadID: ref.ThreadID}
+		err = db.Where("id IN ?", ref.AttachmentIDs).Order("created_at ASC").Find(&added.Attachments).Error
+		if err == nil && len(added.Attachments) == 0 {
+			err = gorm.ErrRecordNotFound
+		}
+		data = added
+	case EventNotification:
+		var notification models.Notification
+		err = db.Where("id = ?", ref.NotificationID).First(&notification).Error
+		data = notification
+	}
+	if errors.Is(err, gorm.ErrRecordNotFound) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(data)
+}
@@ -0,0 +1,78 @@
+package templates
+
+import (
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Placeholders lists every variable a template may use, with a description
+var Placeholders = map[string]string{
+	"patient.name":            "Patient's full name",
+	"patient.first_name":      "Patient's first name",
+	"patient.email":           "Patient's email address",
+	"physician.name":          "Sending physician's name",
+	"physician.office":        "Sending physician's office location",
+	"medication.name":         "Medication name",
+	"medication.dosage":       "Medication dosage",
+	"medication.frequency":    "How often the medication is taken",
+	"medication.instructions": "Medication instructions",
+	"date":                    "Today's date",
+}
+
+var placeholderPattern = regexp.MustCompile(`\{\{\s*([a-zA-Z_.]+)\s*\}\}`)
+
+// Used returns the placeholders referenced in text, sorted and without duplicates
+func Used(text string) []string {
+	seen := map[string]bool{}
+	var used []string
+	for _, match := range placeholderPattern.FindAllStringSubmatch(text, -1) {
+		name := match[1]
+		if !seen[name] {
+			seen[name] = true
+			used = append(used, name)
+		}
+	}
+	sort.Strings(used)
+	return used
+}
+
+// Unknown returns the placeholders in text that are not supported
+func Unknown(text string) []string {
+	var unknown []string
+	for _, name := range Used(text) {
+		if _, ok := Placeholders[name]; !ok {
+			unknown = append(unknown, name)
+		}
+	}
+	return unknown
+}
+
+// NeedsMedication reports whether text uses any medication placeholder
+func NeedsMedication(text string) bool {
+	for _, name := range Used(text) {
+		if strings.HasPrefix(name, "medication.") {
+			return true
+		}
+	}
+	return false
+}
+
+// Render replaces placeholders with values. Placeholders without a value
+// are left as they are and returned, sorted, as missing.
+func Render(text string, values map[string]string) (string, []string) {
+	rendered := placeholderPattern.ReplaceAllStringFunc(text, func(match string) string {
+		if value, ok := values[placeholderPattern.FindStringSubmatch(match)[1]]; ok {
+			return value
+		}
+		return match
+	})
+
+	var missing []string
+	for _, name := range Used(text) {
+		if _, ok := values[name]; !ok {
+			missing = append(missing, name)
+		}
+	}
+	return rendered, missing
+}
@@ -0,0 +1,46 @@
+package templates
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		text        string
+		values      map[string]string
+		want        string
+		wantMissing []string
+	}{
+		{"Hi {{patient.first_name}}, see you soon.", map[string]string{"patient.first_name": "Jane"}, "Hi Jane, see you soon.", nil},
+		{"{{ patient.name }} takes {{medication.name}} {{medication.dosage}}",
+			map[string]string{"patient.name": "Jane Doe", "medication.name": "Lisinopril", "medication.dosage": "10 mg"},
+			"Jane Doe takes Lisinopril 10 mg", nil},
+		{"Dear {{patient.name}}, from {{physician.name}} on {{date}}",
+			map[string]string{"physician.name": "Dr Smith"},
+			"Dear {{patient.name}}, from Dr Smith on {{date}}", []string{"date", "patient.name"}},
+		{"No placeholders", nil, "No placeholders", nil},
+	}
+	for _, tt := range tests {
+		got, missing := Render(tt.text, tt.values)
+		if got != tt.want || !reflect.DeepEqual(missing, tt.wantMissing) {
+			t.Errorf("Render(%q) = %q, %v, want %q, %v", tt.text, got, missing, tt.want, tt.wantMissing)
+		}
+	}
+}
+
+func TestUnknown(t *testing.T) {
+	tests := []struct {
+		text string
+		want []string
+	}{
+		{"Hi {{patient.name}}", nil},
+		{"Hi {{patient.nickname}} and {{ doctor }}, {{patient.nickname}}", []string{"doctor", "patient.nickname"}},
+		{"Take {{medication.name}} as {{medication.instructions}}", nil},
+	}
+	for _, tt := range tests {
+		if got := Unknown(tt.text); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Unknown(%q) = %v, want %v", tt.text, got, tt.want)
+		}
+	}
+}
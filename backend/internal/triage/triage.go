@@ -0,0 +1,133 @@
+package triage
+
+import (
+	"strings"
+	"unicode"
+)
+
+// Urgency levels, from least to most urgent
+const (
+	UrgencyRoutine   = "routine"
+	UrgencyUrgent    = "urgent"
+	UrgencyEmergency = "emergency"
+)
+
+// Message categories
+const (
+	CategoryRefill      = "refill"
+	CategoryBilling     = "billing"
+	CategorySymptoms    = "symptoms"
+	CategoryAppointment = "appointment"
+	CategoryOther       = "other"
+)
+
+// Rank orders urgency levels; higher is more urgent
+func Rank(urgency string) int {
+	switch urgency {
+	case UrgencyEmergency:
+		return 2
+	case UrgencyUrgent:
+		return 1
+	}
+	return 0
+}
+
+// emergencyPhrases suggest a patient may need emergency care now
+var emergencyPhrases = []string{
+	"chest pain", "chest pressure", "chest tightness", "crushing pain",
+	"can't breathe", "cannot breathe", "unable to breathe", "difficulty breathing", "trouble breathing", "struggling to breathe",
+	"severe shortness of breath",
+	"stroke", "face drooping", "slurred speech", "sudden numbness", "sudden weakness",
+	"unconscious", "passed out", "unresponsive", "seizure",
+	"severe bleeding", "bleeding heavily", "won't stop bleeding", "coughing up blood", "vomiting blood",
+	"throat swelling", "throat is swelling", "tongue swelling", "anaphylaxis", "anaphylactic",
+	"overdose", "overdosed", "took too many",
+	"suicidal", "suicide", "kill myself", "end my life", "want to die", "hurt myself",
+	"worst headache of my life",
+}
+
+// urgentPhrases should be seen soon but are not emergencies on their own
+var urgentPhrases = []string{
+	"high fever", "fever of 103", "fever of 104", "shortness of breath", "short of breath",
+	"severe pain", "severe headache", "severe vomiting", "can't keep anything down", "blood in my stool", "blood in my urine",
+	"allergic reaction", "hives", "fainted", "dizzy", "confused", "confusion",
+	"out of medication", "ran out of", "pregnant and bleeding",
+}
+
+// categoryKeywords infer a category when the sender did not choose one.
+// Categories are checked in order, so symptoms outrank administrative topics.
+var categoryKeywords = []struct {
+	category string
+	phrases  []string
+}{
+	{CategorySymptoms, []string{"pain", "ache", "fever", "rash", "cough", "nausea", "vomiting", "dizzy", "bleeding", "swelling", "headache", "symptom", "symptoms", "sore", "itchy", "breathing", "diarrhea", "infection"}},
+	{CategoryRefill, []string{"refill", "refills", "prescription", "pharmacy", "ran out", "out of medication", "renew"}},
+	{CategoryAppointment, []string{"appointment", "reschedule", "schedule", "cancel", "booking", "visit", "follow-up", "follow up"}},
+	{CategoryBilling, []string{"bill", "billing", "invoice", "charge", "charged", "payment", "copay", "insurance", "refund", "statement"}},
+}
+
+// Result is the outcome of triaging a message
+type Result struct {
+	Urgency  string
+	Category string
+	Matched  []string // Phrases that set the urgency
+}
+
+// normalize lowercases text and collapses punctuation and whitespace so
+// phrases match on word boundaries
+func normalize(text string) string {
+	text = strings.NewReplacer("’", "'", "‘", "'").Replace(strings.ToLower(text))
+	text = strings.Map(func(r rune) rune {
+		if unicode.IsLetter(r) || unicode.IsDigit(r) || r == '\'' || r == '-' {
+			return r
+		}
+		return ' '
+	}, text)
+	return " " + strings.Join(strings.Fields(text), " ") + " "
+}
+
+func matches(normalized string, phrases []string) []string {
+	var found []string
+	for _, phrase := range phrases {
+		if strings.Contains(normalized, " "+phrase+" ") {
+			found = append(found, phrase)
+		}
+	}
+	return found
+}
+
+// Classify triages a patient's message. The requested urgency and category
+// are kept unless the rules find something more urgent; rules never lower
+// the urgency a patient chose.
+func Classify(subject, content, urgency, category string) Result {
+	text := normalize(subject + " " + content)
+	result := Result{Urgency: urgency, Category: category}
+	if result.Urgency == "" {
+		result.Urgency = UrgencyRoutine
+	}
+
+	if found := matches(text, emergencyPhrases); len(found) > 0 {
+		result.Urgency = UrgencyEmergency
+		result.Matched = found
+	} else if found := matches(text, urgentPhrases); len(found) > 0 && Rank(result.Urgency) < Rank(UrgencyUrgent) {
+		result.Urgency = UrgencyUrgent
+		result.Matched = found
+	}
+
+	if result.Category == "" {
+		result.Category = CategoryOther
+		for _, rule := range categoryKeywords {
+			if len(matches(text, rule.phrases)) > 0 {
+				result.Category = rule.category
+				break
+			}
+		}
+	}
+	return result
+}
+
+// SafetyReply is sent automatically when a message may describe an emergency
+const SafetyReply = "Your message mentions symptoms that may need emergency care. " +
+	"Messages are not monitored around the clock and your care team may not see this right away. " +
+	"If you are experiencing a medical emergency, call 911 (or your local emergency number) now or go to the nearest emergency room. " +
+	"If you are thinking about harming yourself, call or text 988 to reach the Suicide & Crisis Lifeline."
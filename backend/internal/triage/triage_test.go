@@ -0,0 +1,52 @@
+package triage
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestClassify(t *testing.T) {
+	tests := []struct {
+		subject, content, urgency, category string
+		want                                Result
+	}{
+		{"Question", "I have had chest pain since this morning", "", "",
+			Result{Urgency: UrgencyEmergency, Category: CategorySymptoms, Matched: []string{"chest pain"}}},
+		{"Refill", "I ran out of my lisinopril", "", "",
+			Result{Urgency: UrgencyUrgent, Category: CategoryRefill, Matched: []string{"ran out of"}}},
+		{"Bill", "Why was I charged twice?", "", "",
+			Result{Urgency: UrgencyRoutine, Category: CategoryBilling}},
+		{"Hello", "Thanks for your help", "", "",
+			Result{Urgency: UrgencyRoutine, Category: CategoryOther}},
+
+		// Punctuation and curly apostrophes still match
+		{"Help", "My cough is worse and I can’t breathe!", "", "",
+			Result{Urgency: UrgencyEmergency, Category: CategorySymptoms, Matched: []string{"can't breathe"}}},
+
+		// Symptoms outrank administrative topics
+		{"Appointment", "Can I reschedule? My rash is worse", "", "",
+			Result{Urgency: UrgencyRoutine, Category: CategorySymptoms}},
+
+		// The sender's choices are kept unless the rules find something more urgent
+		{"Visit", "Can we talk about my results?", UrgencyUrgent, CategoryAppointment,
+			Result{Urgency: UrgencyUrgent, Category: CategoryAppointment}},
+		{"Visit", "I feel dizzy", UrgencyEmergency, "",
+			Result{Urgency: UrgencyEmergency, Category: CategorySymptoms}},
+		{"Visit", "I feel dizzy", UrgencyRoutine, CategoryOther,
+			Result{Urgency: UrgencyUrgent, Category: CategoryOther, Matched: []string{"dizzy"}}},
+	}
+	for _, tt := range tests {
+		got := Classify(tt.subject, tt.content, tt.urgency, tt.category)
+		if !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("Classify(%q, %q, %q, %q) = %+v, want %+v", tt.subject, tt.content, tt.urgency, tt.category, got, tt.want)
+		}
+	}
+}
+
+func TestClassifyWordBoundaries(t *testing.T) {
+	// "stroke" inside another word is not an emergency
+	got := Classify("Golf", "Working on my backstroke", "", "")
+	if got.Urgency != UrgencyRoutine {
+		t.Errorf("Classify(backstroke) urgency = %q, want %q", got.Urgency, UrgencyRoutine)
+	}
+}
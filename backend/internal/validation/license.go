@@ -0,0 +1,65 @@
+package validation
+
+import (
+	"errors"
+	"regexp"
+	"strings"
+)
+
+var (
+	ErrUnknownLicenseState = errors.New("unknown license issuing state")
+	ErrInvalidLicense      = errors.New("license number does not match the issuing state's format")
+)
+
+// defaultLicensePattern accepts the alphanumeric formats used by most state boards
+var defaultLicensePattern = regexp.MustCompile(`^[A-Z0-9][A-Z0-9.\-]{3,14}$`)
+
+// licensePatterns holds the physician license formats for states whose boards
+// publish a stricter format. Other states fall back to defaultLicensePattern.
+var licensePatterns = map[string]*regexp.Regexp{
+	"CA": regexp.MustCompile(`^([ACG]\d{4,6}|20A\d{4})$`),
+	"FL": regexp.MustCompile(`^(ME|OS)\d{4,6}$`),
+	"GA": regexp.MustCompile(`^(DO)?\d{4,6}$`),
+	"IL": regexp.MustCompile(`^036\.?\d{6}$`),
+	"MA": regexp.MustCompile(`^\d{5,6}$`),
+	"NY": regexp.MustCompile(`^\d{6}$`),
+	"PA": regexp.MustCompile(`^(MD|DO)\d{6}[A-Z]?$`),
+	"TX": regexp.MustCompile(`^[A-Z]\d{4,5}$`),
+}
+
+// LicenseStates lists the US states, DC and territories that issue medical licenses
+var LicenseStates = []string{
+	"AK", "AL", "AR", "AZ", "CA", "CO", "CT", "DC", "DE", "FL", "GA", "GU", "HI", "IA",
+	"ID", "IL", "IN", "KS", "KY", "LA", "MA", "MD", "ME", "MI", "MN", "MO", "MS", "MT",
+	"NC", "ND", "NE", "NH", "NJ", "NM", "NV", "NY", "OH", "OK", "OR", "PA", "PR", "RI",
+	"SC", "SD", "TN", "TX", "UT", "VA", "VI", "VT", "WA", "WI", "WV", "WY",
+}
+
+// NormalizeLicense upper-cases a license number and removes spaces
+func NormalizeLicense(license string) string {
+	return strings.ToUpper(strings.ReplaceAll(strings.TrimSpace(license), " ", ""))
+}
+
+// ValidateLicense checks a license number against its issuing state's format.
+// Both values are expected to be normalized already.
+func ValidateLicense(state, license string) error {
+	known := false
+	for _, s := range LicenseStates {
+		if s == state {
+			known = true
+			break
+		}
+	}
+	if !known {
+		return ErrUnknownLicenseState
+	}
+
+	pattern, ok := licensePatterns[state]
+	if !ok {
+		pattern = defaultLicensePattern
+	}
+	if !pattern.MatchString(license) {
+		return ErrInvalidLicense
+	}
+	return nil
+}
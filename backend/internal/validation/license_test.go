@@ -0,0 +1,41 @@
+package validation
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestNormalizeLicense(t *testing.T) {
+	if got := NormalizeLicense("  me 12345 "); got != "ME12345" {
+		t.Errorf("NormalizeLicense = %q, want %q", got, "ME12345")
+	}
+}
+
+func TestValidateLicense(t *testing.T) {
+	tests := []struct {
+		state, license string
+		want           error
+	}{
+		{"CA", "A12345", nil},
+		{"CA", "20A1234", nil},
+		{"CA", "B12345", ErrInvalidLicense},
+		{"FL", "ME123456", nil},
+		{"FL", "MD123456", ErrInvalidLicense},
+		{"IL", "036.123456", nil},
+		{"IL", "036123456", nil},
+		{"NY", "123456", nil},
+		{"NY", "12345", ErrInvalidLicense},
+		{"PA", "MD123456E", nil},
+		{"TX", "K1234", nil},
+		{"TX", "12345", ErrInvalidLicense},
+		{"OH", "35.123456", nil}, // default pattern
+		{"OH", "AB", ErrInvalidLicense},
+		{"OH", "-12345", ErrInvalidLicense},
+		{"ZZ", "123456", ErrUnknownLicenseState},
+	}
+	for _, tt := range tests {
+		if err := ValidateLicense(tt.state, tt.license); !errors.Is(err, tt.want) {
+			t.Errorf("ValidateLicense(%q, %q) = %v, want %v", tt.state, tt.license, err, tt.want)
+		}
+	}
+}
@@ -0,0 +1,33 @@
+package validation
+
+// ValidNPI reports whether npi is a 10-digit National Provider Identifier
+// with a correct check digit. The check digit is the Luhn check digit of the
+// first nine digits prefixed with the 80840 health industry issuer code.
+func ValidNPI(npi string) bool {
+	if len(npi) != 10 {
+		return false
+	}
+	for _, r := range npi {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+
+	digits := "80840" + npi[:9]
+	sum := 0
+	double := true // the rightmost payload digit is doubled
+	for i := len(digits) - 1; i >= 0; i-- {
+		d := int(digits[i] - '0')
+		if double {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		double = !double
+	}
+
+	check := (10 - sum%10) % 10
+	return check == int(npi[9]-'0')
+}
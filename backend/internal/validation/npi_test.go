@@ -0,0 +1,24 @@
+package validation
+
+import "testing"
+
+func TestValidNPI(t *testing.T) {
+	tests := []struct {
+		npi  string
+		want bool
+	}{
+		{"1234567893", true},
+		{"1245319599", true},
+		{"1234567890", false}, // wrong check digit
+		{"1245319598", false},
+		{"123456789", false}, // too short
+		{"12345678931", false},
+		{"12345678a3", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		if got := ValidNPI(tt.npi); got != tt.want {
+			t.Errorf("ValidNPI(%q) = %v, want %v", tt.npi, got, tt.want)
+		}
+	}
+}
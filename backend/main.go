@@ -1,16 +1,28 @@
 package main
 
 import (
+	"errors"
 	"log"
 	"os"
+	"strconv"
+	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/joho/godotenv"
+	"golang.org/x/crypto/bcrypt"
 	"gorm.io/driver/sqlite"
 	"gorm.io/gorm"
 
+	"github.com/yourusername/health-connect/internal/auth"
 	"github.com/yourusername/health-connect/internal/handlers"
+	"github.com/yourusername/health-connect/internal/interactions"
+	"github.com/yourusername/health-connect/internal/mail"
+	"github.com/yourusername/health-connect/internal/middleware"
 	"github.com/yourusername/health-connect/internal/models"
+	"github.com/yourusername/health-connect/internal/notify"
+	"github.com/yourusername/health-connect/internal/realtime"
+	"github.com/yourusername/health-connect/internal/storage"
 )
 
 func initDB() *gorm.DB {
@@ -24,26 +36,93 @@ func initDB() *gorm.DB {
 		dbPath = "healthconnect.db"
 	}
 
-	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{})
+	db, err := gorm.Open(sqlite.Open(dbPath), &gorm.Config{TranslateError: true})
 	if err != nil {
 		log.Fatal("Failed to connect to database:", err)
 	}
 
 	// Auto-migrate models
 	err = db.AutoMigrate(
+		&models.Account{},
 		&models.Patient{},
 		&models.Physician{},
 		&models.Medication{},
+		&models.MedicationVersion{},
+		&models.InteractionAcknowledgment{},
+		&models.Allergy{},
+		&models.AllergyOverride{},
+		&models.RefillRequest{},
 		&models.Message{},
+		&models.MessageReceipt{},
+		&models.Attachment{},
+		&models.OutOfOffice{},
+		&models.ThreadCoverage{},
+		&models.MessageTemplate{},
+		&models.Conversation{},
+		&models.ConversationParticipant{},
+		&models.Notification{},
+		&models.NotificationDelivery{},
+		&models.NotificationPreference{},
+		&models.Event{},
 		&models.Specialty{},
+		&models.RefreshToken{},
+		&models.RevokedToken{},
+		&models.PasswordResetToken{},
+		&models.Admin{},
+		&models.MFAEnrollment{},
+		&models.MFARecoveryCode{},
+		&models.LoginThrottle{},
 	)
 	if err != nil {
 		log.Fatal("Failed to migrate database:", err)
 	}
 
+	// Move credentials stored on profiles by older versions into accounts
+	if err := models.MigrateAccounts(db); err != nil {
+		log.Fatal("Failed to migrate accounts:", err)
+	}
+
+	// Link free-text prescribers from older versions to physicians
+	if err := models.MigrateMedicationPrescribers(db); err != nil {
+		log.Fatal("Failed to migrate medication prescribers:", err)
+	}
+
+	// Allow one refill request in progress per medication
+	if err := models.SetupOpenRefillIndex(db); err != nil {
+		log.Fatal("Failed to create refill request index:", err)
+	}
+
+	// Give messages created before threading a thread of their own
+	if err := models.BackfillMessageThreads(db); err != nil {
+		log.Fatal("Failed to backfill message threads:", err)
+	}
+	if err := models.BackfillMessageReceipts(db); err != nil {
+		log.Fatal("Failed to backfill message receipts:", err)
+	}
+
+	// Index messages for full-text search
+	if err := models.SetupMessageSearch(db); errors.Is(err, models.ErrSearchUnavailable) {
+		log.Println("Warning:", err)
+	} else if err != nil {
+		log.Fatal("Failed to set up message search:", err)
+	}
+
+	// Drop real-time events too old to be replayed
+	if err := realtime.PurgeExpiredEvents(db); err != nil {
+		log.Println("Failed to purge expired events:", err)
+	}
+
 	// Seed specialties if they don't exist
 	seedSpecialties(db)
 
+	// Create the initial admin account if configured
+	seedAdmin(db)
+
+	// Drop revocation entries and refresh tokens that have expired
+	if err := auth.PurgeExpiredTokens(db); err != nil {
+		log.Printf("Failed to purge expired tokens: %v", err)
+	}
+
 	log.Printf("SQLite database connected successfully: %s", dbPath)
 	return db
 }
@@ -66,16 +145,145 @@ func seedSpecialties(db *gorm.DB) {
 	log.Println("Medical specialties seeded successfully")
 }
 
+// seedAdmin creates an admin account from ADMIN_EMAIL and ADMIN_PASSWORD if
+// it doesn't exist. An existing account with that email is given the admin role.
+func seedAdmin(db *gorm.DB) {
+	email := os.Getenv("ADMIN_EMAIL")
+	password := os.Getenv("ADMIN_PASSWORD")
+	if email == "" || password == "" {
+		return
+	}
+
+	var admin models.Admin
+	if result := db.Where("email = ?", email).First(&admin); result.Error == nil {
+		return
+	}
+
+	var account models.Account
+	if result := db.Where("email = ?", email).First(&account); result.Error != nil {
+		hashedPassword, err := bcrypt.GenerateFromPassword([]byte(password), bcrypt.DefaultCost)
+		if err != nil {
+			log.Printf("Failed to hash admin password: %v", err)
+			return
+		}
+
+		account = models.Account{
+			Email:    email,
+			Password: string(hashedPassword),
+			Verified: true, // Configured by the operator, so no verification email is needed
+		}
+		if err := db.Create(&account).Error; err != nil {
+			log.Printf("Failed to seed admin account: %s", email)
+			return
+		}
+	}
+
+	admin = models.Admin{
+		AccountID: account.ID,
+		Email:     email,
+		Name:      "Administrator",
+	}
+	if err := db.Create(&admin).Error; err != nil {
+		log.Printf("Failed to seed admin: %s", email)
+		return
+	}
+	log.Printf("Admin account seeded: %s", email)
+}
+
+// keyReloadInterval is how often JWT_KEYS_DIR is re-read (JWT_KEYS_RELOAD_INTERVAL, default 5m)
+func keyReloadInterval() time.Duration {
+	if value := os.Getenv("JWT_KEYS_RELOAD_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil {
+			log.Fatal("Invalid JWT_KEYS_RELOAD_INTERVAL:", err)
+		}
+		return interval
+	}
+	return 5 * time.Minute
+}
+
+// notificationInterval reads how often queued email and SMS notifications
+// are sent from NOTIFICATION_INTERVAL
+func notificationInterval() time.Duration {
+	if value := os.Getenv("NOTIFICATION_INTERVAL"); value != "" {
+		interval, err := time.ParseDuration(value)
+		if err != nil {
+			log.Fatal("Invalid NOTIFICATION_INTERVAL:", err)
+		}
+		return interval
+	}
+	return time.Minute
+}
+
+// attachmentMaxBytes reads the per-file upload limit from ATTACHMENT_MAX_BYTES
+func attachmentMaxBytes() int64 {
+	if value := os.Getenv("ATTACHMENT_MAX_BYTES"); value != "" {
+		size, err := strconv.ParseInt(value, 10, 64)
+		if err != nil || size <= 0 {
+			log.Fatal("Invalid ATTACHMENT_MAX_BYTES:", value)
+		}
+		return size
+	}
+	return handlers.DefaultAttachmentMaxBytes
+}
+
+// trustedProxies reads the reverse proxies allowed to set X-Forwarded-For
+// from TRUSTED_PROXIES (comma-separated IPs or CIDRs). With none set the
+// client IP is the connection's remote address.
+func trustedProxies() []string {
+	var proxies []string
+	for _, proxy := range strings.Split(os.Getenv("TRUSTED_PROXIES"), ",") {
+		if proxy = strings.TrimSpace(proxy); proxy != "" {
+			proxies = append(proxies, proxy)
+		}
+	}
+	return proxies
+}
+
 func main() {
 	// Initialize database
 	db := initDB()
 
+	// Load JWT signing keys; production refuses to start without one
+	if err := auth.LoadSigningKeys(); err != nil {
+		log.Fatal("Failed to load JWT signing keys:", err)
+	}
+	auth.WatchSigningKeys(keyReloadInterval())
+
 	// Initialize handlers
-	authHandler := handlers.NewAuthHandler(db)
+	mailer := mail.NewSenderFromEnv()
+	authHandler := handlers.NewAuthHandler(db, mailer)
 	patientHandler := handlers.NewPatientHandler(db)
 	physicianHandler := handlers.NewPhysicianHandler(db)
+	outOfOfficeHandler := handlers.NewOutOfOfficeHandler(db)
+	hub := realtime.NewHub(db)
+	hub.StartPurge()
+	notifier := notify.NewDispatcher(db, handlers.AppBaseURL(),
+		notify.EmailChannel{Sender: mailer},
+		notify.SMSChannel{Sender: notify.NewSMSSenderFromEnv()},
+		notify.InAppChannel{Hub: hub},
+	)
+	notifier.Start(notificationInterval())
+	messageHandler := handlers.NewMessageHandler(db, hub, notifier)
+	notificationHandler := handlers.NewNotificationHandler(db)
+	checker, err := interactions.NewCheckerFromEnv()
+	if err != nil {
+		log.Fatal("Failed to load drug interactions:", err)
+	}
+	medicationHandler := handlers.NewMedicationHandler(db, notifier, checker)
+	allergyHandler := handlers.NewAllergyHandler(db, checker)
+	refillHandler := handlers.NewRefillHandler(db, messageHandler)
+	eventsHandler := handlers.NewEventsHandler(db, hub)
+	templateHandler := handlers.NewTemplateHandler(db, messageHandler)
+	conversationHandler := handlers.NewConversationHandler(db, messageHandler)
+	attachmentHandler := handlers.NewAttachmentHandler(db, storage.NewStoreFromEnv(), hub, attachmentMaxBytes())
+	adminHandler := handlers.NewAdminHandler(db, mailer)
+	authMiddleware := middleware.NewAuthMiddleware(db)
 
 	r := gin.Default()
+	if err := r.SetTrustedProxies(trustedProxies()); err != nil {
+		log.Fatal("Invalid TRUSTED_PROXIES:", err)
+	}
 
 	// CORS middleware
 	r.Use(func(c *gin.Context) {
@@ -98,20 +306,63 @@ func main() {
 		})
 	})
 
+	// Public keys for verifying access tokens
+	r.GET("/.well-known/jwks.json", authHandler.JWKS)
+
 	// Auth routes
-	auth := r.Group("/auth")
+	authRoutes := r.Group("/auth")
 	{
-		auth.GET("", authHandler.Auth)
-		auth.POST("/patient", authHandler.PatientLogin)
-		auth.POST("/physician", authHandler.PhysicianLogin)
-		auth.POST("/register/patient", authHandler.PatientRegister)
-		auth.POST("/register/physician", authHandler.PhysicianRegister)
+		authRoutes.GET("", authHandler.Auth)
+		authRoutes.POST("/login", authHandler.Login)
+		authRoutes.POST("/patient", authHandler.PatientLogin)
+		authRoutes.POST("/physician", authHandler.PhysicianLogin)
+		authRoutes.POST("/admin", authHandler.AdminLogin)
+		authRoutes.POST("/register/patient", authHandler.PatientRegister)
+		authRoutes.POST("/register/physician", authHandler.PhysicianRegister)
+		authRoutes.POST("/refresh", authHandler.Refresh)
+		authRoutes.POST("/logout", authMiddleware.RequireAuth(), authHandler.Logout)
+		authRoutes.GET("/me", authMiddleware.RequireAuth(), authHandler.Me)
+		authRoutes.POST("/switch-role", authMiddleware.RequireAuth(), authHandler.SwitchRole)
+		authRoutes.POST("/roles/patient", authMiddleware.RequireAuth(), authHandler.AddPatientRole)
+		authRoutes.POST("/roles/physician", authMiddleware.RequireAuth(), authHandler.AddPhysicianRole)
+		authRoutes.POST("/password/forgot", middleware.RateLimit(5, 15*time.Minute), authHandler.ForgotPassword)
+		authRoutes.POST("/password/reset", authHandler.ResetPassword)
+		authRoutes.POST("/verify", authHandler.VerifyEmail)
+		authRoutes.POST("/verify/resend", middleware.RateLimit(5, 15*time.Minute), authHandler.ResendVerification)
+		authRoutes.POST("/mfa/verify", middleware.RateLimit(10, 5*time.Minute), authHandler.VerifyMFA)
+		authRoutes.POST("/mfa/enroll", authMiddleware.RequireAuth(), authHandler.EnrollMFA)
+		authRoutes.POST("/mfa/confirm", authMiddleware.RequireAuth(), authHandler.ConfirmMFA)
+		authRoutes.POST("/mfa/disable", authMiddleware.RequireAuth(), authHandler.DisableMFA)
 	}
 
-	// Patient routes
-	patients := r.Group("/patients")
+	// Patient routes (patient themself or a linked physician)
+	patients := r.Group("/patients", append(authMiddleware.RequirePHIAccess(), authMiddleware.RequirePatientAccess())...)
 	{
 		patients.GET("/:id/medications", patientHandler.GetPatientMedications)
+		patients.GET("/:id/medications/:medicationId/history", medicationHandler.GetMedicationHistory)
+		patients.GET("/:id/medications/interactions", medicationHandler.GetMedicationInteractions)
+		patients.GET("/:id/medications/doses", medicationHandler.GetUpcomingDoses)
+
+		// Prescribing is for the patient's physicians only
+		prescribing := authMiddleware.RequireRole(auth.RolePhysician)
+		patients.POST("/:id/medications", prescribing, medicationHandler.PrescribeMedication)
+		patients.PUT("/:id/medications/:medicationId", prescribing, medicationHandler.UpdateMedication)
+		patients.POST("/:id/medications/:medicationId/discontinue", prescribing, medicationHandler.DiscontinueMedication)
+		patients.POST("/:id/medications/interactions/acknowledge", prescribing, medicationHandler.AcknowledgeInteraction)
+
+		// Patients and their physicians both keep the allergy list
+		patients.GET("/:id/allergies", allergyHandler.GetAllergies)
+		patients.POST("/:id/allergies", allergyHandler.CreateAllergy)
+		patients.PUT("/:id/allergies/:allergyId", allergyHandler.UpdateAllergy)
+		patients.DELETE("/:id/allergies/:allergyId", allergyHandler.DeleteAllergy)
+		patients.POST("/:id/allergies/:allergyId/verify", prescribing, allergyHandler.VerifyAllergy)
+
+		// Patients request refills; their physicians review them
+		patients.GET("/:id/refills", refillHandler.GetPatientRefills)
+		patients.POST("/:id/medications/:medicationId/refills", authMiddleware.RequireRole(auth.RolePatient), refillHandler.RequestRefill)
+		patients.POST("/:id/refills/:refillId/approve", prescribing, refillHandler.ApproveRefill)
+		patients.POST("/:id/refills/:refillId/deny", prescribing, refillHandler.DenyRefill)
+		patients.POST("/:id/refills/:refillId/sent-to-pharmacy", prescribing, refillHandler.SendRefillToPharmacy)
 		patients.GET("/:id/messages", patientHandler.GetPatientMessages)
 		patients.GET("/:id/physicians", patientHandler.GetPatientPhysicians)
 	}
@@ -119,9 +370,92 @@ func main() {
 	// Physician routes
 	physicians := r.Group("/physicians")
 	{
-		physicians.GET("/:id/patients", physicianHandler.GetPhysicianPatients)
-		physicians.GET("/:id/messages", physicianHandler.GetPhysicianMessages)
+		// Public so the registration form can list specialties
 		physicians.GET("/specialties", physicianHandler.GetSpecialties)
+
+		// Physician themself only
+		physician := physicians.Group("/:id", append(authMiddleware.RequirePHIAccess(), authMiddleware.RequirePhysicianSelf())...)
+		physician.GET("/patients", physicianHandler.GetPhysicianPatients)
+		physician.GET("/messages", physicianHandler.GetPhysicianMessages)
+		physician.GET("/inbox", physicianHandler.GetPhysicianInbox)
+		physician.GET("/refills", refillHandler.GetPhysicianRefills)
+		physician.GET("/out-of-office", outOfOfficeHandler.GetOutOfOffice)
+		physician.POST("/out-of-office", outOfOfficeHandler.CreateOutOfOffice)
+		physician.PUT("/out-of-office/:periodId", outOfOfficeHandler.UpdateOutOfOffice)
+		physician.DELETE("/out-of-office/:periodId", outOfOfficeHandler.DeleteOutOfOffice)
+	}
+
+	// Messaging routes (patients and physicians only)
+	messaging := append(authMiddleware.RequirePHIAccess(), authMiddleware.RequireRole(auth.RolePatient, auth.RolePhysician))
+	messages := r.Group("/messages", messaging...)
+	{
+		messages.POST("", messageHandler.SendMessage)
+		messages.POST("/:id/replies", messageHandler.ReplyToMessage)
+		messages.POST("/:id/read", messageHandler.MarkMessageRead)
+		messages.POST("/:id/attachments", attachmentHandler.UploadAttachments)
+		messages.GET("/unread", messageHandler.GetUnreadCounts)
+		messages.GET("/search", messageHandler.SearchMessages)
+	}
+	templateRoutes := r.Group("/templates", append(authMiddleware.RequirePHIAccess(), authMiddleware.RequireRole(auth.RolePhysician))...)
+	{
+		templateRoutes.GET("", templateHandler.GetTemplates)
+		templateRoutes.POST("", templateHandler.CreateTemplate)
+		templateRoutes.GET("/:id", templateHandler.GetTemplate)
+		templateRoutes.PUT("/:id", templateHandler.UpdateTemplate)
+		templateRoutes.DELETE("/:id", templateHandler.DeleteTemplate)
+		templateRoutes.POST("/:id/preview", templateHandler.PreviewTemplate)
+		templateRoutes.POST("/:id/send", templateHandler.SendTemplate)
+	}
+	conversations := r.Group("/conversations", messaging...)
+	{
+		conversations.GET("", conversationHandler.GetConversations)
+		conversations.POST("", conversationHandler.CreateConversation)
+		conversations.GET("/:id", conversationHandler.GetConversation)
+		conversations.POST("/:id/messages", conversationHandler.SendConversationMessage)
+		conversations.POST("/:id/participants", conversationHandler.AddParticipant)
+		conversations.PUT("/:id/participants/:participantId", conversationHandler.UpdateParticipant)
+		conversations.DELETE("/:id/participants/:participantId", conversationHandler.RemoveParticipant)
+	}
+	attachments := r.Group("/attachments", messaging...)
+	{
+		attachments.GET("/:id", attachmentHandler.DownloadAttachment)
+	}
+	threads := r.Group("/threads", messaging...)
+	{
+		threads.GET("/:id", messageHandler.GetThread)
+		threads.POST("/:id/read", messageHandler.MarkThreadRead)
+		threads.POST("/:id/typing", eventsHandler.SendTyping)
+	}
+
+	notifications := r.Group("/notifications", messaging...)
+	{
+		notifications.GET("", notificationHandler.GetNotifications)
+		notifications.POST("/read", notificationHandler.MarkAllNotificationsRead)
+		notifications.POST("/:id/read", notificationHandler.MarkNotificationRead)
+		notifications.GET("/preferences", notificationHandler.GetNotificationPreferences)
+		notifications.PUT("/preferences", notificationHandler.UpdateNotificationPreferences)
+	}
+
+	// Real-time events; EventSource cannot set headers, so the stream is opened
+	// with a short-lived ticket in the query rather than the access token
+	eventTickets := r.Group("/events", messaging...)
+	{
+		eventTickets.POST("/ticket", eventsHandler.IssueStreamTicket)
+	}
+	streaming := append(authMiddleware.RequireStreamPHIAccess(), authMiddleware.RequireRole(auth.RolePatient, auth.RolePhysician))
+	events := r.Group("/events", streaming...)
+	{
+		events.GET("", eventsHandler.StreamEvents)
+	}
+
+	// Admin routes
+	admin := r.Group("/admin", authMiddleware.RequireAuth(), authMiddleware.RequireRole(auth.RoleAdmin))
+	{
+		admin.GET("/physicians/pending", adminHandler.GetPendingPhysicians)
+		admin.POST("/physicians/:id/approve", adminHandler.ApprovePhysician)
+		admin.POST("/physicians/:id/reject", adminHandler.RejectPhysician)
+		admin.GET("/lockouts", adminHandler.GetLockouts)
+		admin.POST("/lockouts/unlock", adminHandler.UnlockLogin)
 	}
 
 	log.Println("Server starting on :8080")
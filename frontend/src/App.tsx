@@ -9,12 +9,12 @@ import "./App.css";
 function App() {
   const [isSignedIn, setIsSignedIn] = useState(false);
   const [userType, setUserType] = useState<"patient" | "physician" | null>(null);
-  const [userId, setUserId] = useState<number | null>(null);
+  const [userId, setUserId] = useState<string | null>(null);
   const [userEmail, setUserEmail] = useState<string>("");
   const [showPatientRegister, setShowPatientRegister] = useState(false);
   const [showPhysicianRegister, setShowPhysicianRegister] = useState(false);
 
-  const handlePatientSignIn = (email: string, id?: number) => {
+  const handlePatientSignIn = (email: string, id?: string) => {
     console.log("Patient sign in:", email, id);
     setUserEmail(email);
     setUserId(id || null);
@@ -22,7 +22,7 @@ function App() {
     setIsSignedIn(true);
   };
 
-  const handlePhysicianSignIn = (email: string, id?: number) => {
+  const handlePhysicianSignIn = (email: string, id?: string) => {
     console.log("Physician sign in:", email, id);
     setUserEmail(email);
     setUserId(id || null);
@@ -30,7 +30,7 @@ function App() {
     setIsSignedIn(true);
   };
 
-  const handlePatientRegisterSuccess = (id?: number) => {
+  const handlePatientRegisterSuccess = (id?: string) => {
     // After successful registration, automatically sign in
     setShowPatientRegister(false);
     setUserId(id || null);
@@ -38,7 +38,7 @@ function App() {
     setIsSignedIn(true);
   };
 
-  const handlePhysicianRegisterSuccess = (id?: number) => {
+  const handlePhysicianRegisterSuccess = (id?: string) => {
     // After successful registration, automatically sign in
     setShowPhysicianRegister(false);
     setUserId(id || null);
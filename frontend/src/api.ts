@@ -4,36 +4,560 @@ const api = axios.create({
   baseURL: "http://localhost:8080",
 });
 
+const TOKEN_KEY = "token";
+const REFRESH_TOKEN_KEY = "refresh_token";
+
+export const setAuthToken = (token: string | null, refreshToken?: string) => {
+  if (token) {
+    localStorage.setItem(TOKEN_KEY, token);
+    if (refreshToken) {
+      localStorage.setItem(REFRESH_TOKEN_KEY, refreshToken);
+    }
+  } else {
+    localStorage.removeItem(TOKEN_KEY);
+    localStorage.removeItem(REFRESH_TOKEN_KEY);
+  }
+};
+
+// Attach the JWT to every request
+api.interceptors.request.use((config) => {
+  const token = localStorage.getItem(TOKEN_KEY);
+  if (token) {
+    config.headers.Authorization = `Bearer ${token}`;
+  }
+  return config;
+});
+
+// On 401, try once to rotate the refresh token, otherwise drop the session
+api.interceptors.response.use(
+  (response) => response,
+  async (error) => {
+    const original = error.config;
+    const refreshToken = localStorage.getItem(REFRESH_TOKEN_KEY);
+
+    if (
+      error.response?.status === 401 &&
+      refreshToken &&
+      original &&
+      !original._retry &&
+      original.url !== "/auth/refresh"
+    ) {
+      original._retry = true;
+      try {
+        const { data } = await api.post("/auth/refresh", { refresh_token: refreshToken });
+        setAuthToken(data.token, data.refresh_token);
+        return api(original);
+      } catch {
+        setAuthToken(null);
+      }
+    } else if (error.response?.status === 401) {
+      setAuthToken(null);
+    }
+    return Promise.reject(error);
+  }
+);
+
+export const logout = async () => {
+  try {
+    await api.post("/auth/logout", {
+      refresh_token: localStorage.getItem(REFRESH_TOKEN_KEY),
+    });
+  } finally {
+    setAuthToken(null);
+  }
+};
+
+// Auth API functions
+export const authAPI = {
+  me: async () => {
+    const response = await api.get("/auth/me");
+    return response.data;
+  },
+  // Start a session in another role held by the same account
+  switchRole: async (role: "patient" | "physician" | "admin") => {
+    const response = await api.post("/auth/switch-role", { role });
+    if (response.data.success && response.data.token) {
+      setAuthToken(response.data.token, response.data.refresh_token);
+    }
+    return response.data;
+  },
+};
+
 // Patient API functions
 export const patientAPI = {
-  getMedications: async (patientId: number) => {
+  getMedications: async (patientId: string) => {
     const response = await api.get(`/patients/${patientId}/medications`);
     return response.data;
   },
-  getMessages: async (patientId: number) => {
+  getMessages: async (patientId: string) => {
     const response = await api.get(`/patients/${patientId}/messages`);
     return response.data;
   },
-  getPhysicians: async (patientId: number) => {
+  getPhysicians: async (patientId: string) => {
     const response = await api.get(`/patients/${patientId}/physicians`);
     return response.data;
   },
 };
 
+export type InteractionSeverity = "minor" | "moderate" | "major" | "contraindicated";
+
+export interface InteractionWarning {
+  severity: InteractionSeverity;
+  description: string;
+  ingredients: [string, string];
+  medication_id?: string;
+  medication_name: string;
+  other_medication_id: string;
+  other_medication_name: string;
+  blocking: boolean; // Prescribing needs an override
+  acknowledgment?: { reason: string; acknowledged_by_id: string; created_at: string };
+}
+
+// Structured dosing schedule; see the backend README for each field
+export interface DosingSchedule {
+  amount?: number;
+  max_amount?: number;
+  unit?: string;
+  route?: string;
+  times_per_day?: number;
+  times?: string[]; // Local "HH:MM"
+  interval_hours?: number;
+  every_days?: number;
+  as_needed: boolean;
+  days?: number;
+  taper?: { amount: number; days: number; times_per_day?: number }[];
+}
+
+export interface UpcomingDose {
+  at: string;
+  amount?: number;
+  max_amount?: number;
+  unit?: string;
+  route?: string;
+  medication_id: string;
+  medication_name: string;
+}
+
+export interface MedicationInput {
+  name: string;
+  dosage?: string;
+  frequency?: string;
+  instructions?: string;
+  schedule?: DosingSchedule; // Read from dosage and frequency when omitted
+  start_date?: string;
+  end_date?: string;
+  reason?: string;
+  // Prescribe despite matching allergies or major or contraindicated
+  // interactions (409 otherwise)
+  override_allergies?: boolean;
+  override_interactions?: boolean;
+  override_reason?: string;
+}
+
+// Medication management API functions (physicians only, except history, interactions and doses)
+export const medicationAPI = {
+  prescribe: async (patientId: string, medication: MedicationInput) => {
+    const response = await api.post(`/patients/${patientId}/medications`, medication);
+    return response.data;
+  },
+  update: async (patientId: string, medicationId: string, medication: MedicationInput) => {
+    const response = await api.put(`/patients/${patientId}/medications/${medicationId}`, medication);
+    return response.data;
+  },
+  discontinue: async (patientId: string, medicationId: string, reason: string, endDate?: string) => {
+    const response = await api.post(`/patients/${patientId}/medications/${medicationId}/discontinue`, {
+      reason,
+      end_date: endDate,
+    });
+    return response.data;
+  },
+  history: async (patientId: string, medicationId: string) => {
+    const response = await api.get(`/patients/${patientId}/medications/${medicationId}/history`);
+    return response.data;
+  },
+  interactions: async (patientId: string) => {
+    const response = await api.get(`/patients/${patientId}/medications/interactions`);
+    return response.data;
+  },
+  // Doses due over the next days (1-31) in the browser's time zone
+  doses: async (patientId: string, days?: number, from?: string) => {
+    const tz = Intl.DateTimeFormat().resolvedOptions().timeZone;
+    const response = await api.get(`/patients/${patientId}/medications/doses`, { params: { days, from, tz } });
+    return response.data;
+  },
+  acknowledgeInteraction: async (patientId: string, medicationId: string, otherMedicationId: string, reason: string) => {
+    const response = await api.post(`/patients/${patientId}/medications/interactions/acknowledge`, {
+      medication_id: medicationId,
+      other_medication_id: otherMedicationId,
+      reason,
+    });
+    return response.data;
+  },
+};
+
+export type AllergySeverity = "mild" | "moderate" | "severe" | "unknown";
+
+export interface AllergyInput {
+  substance: string;
+  reaction?: string;
+  severity?: AllergySeverity;
+  onset?: string;
+}
+
+// Allergy API functions. Patients and their physicians record allergies;
+// only physicians verify them.
+export const allergyAPI = {
+  list: async (patientId: string) => {
+    const response = await api.get(`/patients/${patientId}/allergies`);
+    return response.data;
+  },
+  create: async (patientId: string, allergy: AllergyInput) => {
+    const response = await api.post(`/patients/${patientId}/allergies`, allergy);
+    return response.data;
+  },
+  update: async (patientId: string, allergyId: string, allergy: AllergyInput) => {
+    const response = await api.put(`/patients/${patientId}/allergies/${allergyId}`, allergy);
+    return response.data;
+  },
+  remove: async (patientId: string, allergyId: string) => {
+    const response = await api.delete(`/patients/${patientId}/allergies/${allergyId}`);
+    return response.data;
+  },
+  verify: async (patientId: string, allergyId: string) => {
+    const response = await api.post(`/patients/${patientId}/allergies/${allergyId}/verify`);
+    return response.data;
+  },
+};
+
+export type RefillStatus = "requested" | "approved" | "denied" | "sent_to_pharmacy";
+
+// Refill request API functions. Patients request refills; their physicians
+// approve or deny them and then send them to the pharmacy.
+export const refillAPI = {
+  request: async (
+    patientId: string,
+    medicationId: string,
+    refill?: { pharmacy?: string; note?: string; physician_id?: string }
+  ) => {
+    const response = await api.post(`/patients/${patientId}/medications/${medicationId}/refills`, refill || {});
+    return response.data;
+  },
+  forPatient: async (patientId: string, status?: RefillStatus) => {
+    const response = await api.get(`/patients/${patientId}/refills`, { params: { status } });
+    return response.data;
+  },
+  forPhysician: async (physicianId: string, status?: RefillStatus) => {
+    const response = await api.get(`/physicians/${physicianId}/refills`, { params: { status } });
+    return response.data;
+  },
+  approve: async (patientId: string, refillId: string, note?: string) => {
+    const response = await api.post(`/patients/${patientId}/refills/${refillId}/approve`, { note });
+    return response.data;
+  },
+  // A note explaining the denial is required
+  deny: async (patientId: string, refillId: string, note: string) => {
+    const response = await api.post(`/patients/${patientId}/refills/${refillId}/deny`, { note });
+    return response.data;
+  },
+  sentToPharmacy: async (patientId: string, refillId: string, pharmacy?: string) => {
+    const response = await api.post(`/patients/${patientId}/refills/${refillId}/sent-to-pharmacy`, { pharmacy });
+    return response.data;
+  },
+};
+
 // Physician API functions
 export const physicianAPI = {
-  getPatients: async (physicianId: number) => {
+  getPatients: async (physicianId: string) => {
     const response = await api.get(`/physicians/${physicianId}/patients`);
     return response.data;
   },
-  getMessages: async (physicianId: number) => {
+  getMessages: async (physicianId: string) => {
     const response = await api.get(`/physicians/${physicianId}/messages`);
     return response.data;
   },
+  // Unread patient messages, most urgent and longest waiting first
+  getInbox: async (
+    physicianId: string,
+    params?: { status?: "unread" | "all"; urgency?: string; category?: string; limit?: number; offset?: number }
+  ) => {
+    const response = await api.get(`/physicians/${physicianId}/inbox`, { params });
+    return response.data;
+  },
+  getOutOfOffice: async (physicianId: string) => {
+    const response = await api.get(`/physicians/${physicianId}/out-of-office`);
+    return response.data;
+  },
+  // Schedule an out-of-office period with an auto-reply and optional covering physician
+  createOutOfOffice: async (
+    physicianId: string,
+    period: { starts_at: string; ends_at: string; auto_reply: string; covering_physician_id?: string }
+  ) => {
+    const response = await api.post(`/physicians/${physicianId}/out-of-office`, period);
+    return response.data;
+  },
+  updateOutOfOffice: async (
+    physicianId: string,
+    periodId: string,
+    period: { starts_at: string; ends_at: string; auto_reply: string; covering_physician_id?: string }
+  ) => {
+    const response = await api.put(`/physicians/${physicianId}/out-of-office/${periodId}`, period);
+    return response.data;
+  },
+  deleteOutOfOffice: async (physicianId: string, periodId: string) => {
+    const response = await api.delete(`/physicians/${physicianId}/out-of-office/${periodId}`);
+    return response.data;
+  },
   getSpecialties: async () => {
     const response = await api.get("/physicians/specialties");
     return response.data;
   },
 };
 
+// Messaging API functions
+export const messageAPI = {
+  send: async (payload: {
+    patient_id?: string;
+    physician_id?: string;
+    subject: string;
+    content: string;
+    urgency?: "routine" | "urgent" | "emergency";
+    category?: "refill" | "billing" | "symptoms" | "appointment" | "other";
+  }) => {
+    const response = await api.post("/messages", payload);
+    return response.data;
+  },
+  reply: async (messageId: string, content: string, subject?: string) => {
+    const response = await api.post(`/messages/${messageId}/replies`, { content, subject });
+    return response.data;
+  },
+  getThread: async (threadId: string) => {
+    const response = await api.get(`/threads/${threadId}`);
+    return response.data;
+  },
+  markRead: async (messageId: string) => {
+    const response = await api.post(`/messages/${messageId}/read`);
+    return response.data;
+  },
+  markThreadRead: async (threadId: string) => {
+    const response = await api.post(`/threads/${threadId}/read`);
+    return response.data;
+  },
+  // Unread counts for inbox badges: { total, threads: [{ thread_id, unread }] }
+  getUnreadCounts: async () => {
+    const response = await api.get("/messages/unread");
+    return response.data;
+  },
+  // Full-text search; subject and snippet come back HTML-escaped with <mark> highlights
+  search: async (params: {
+    q: string;
+    from?: string;
+    to?: string;
+    sender?: "patient" | "physician";
+    sender_id?: string;
+    sort?: "relevance" | "date";
+    limit?: number;
+    offset?: number;
+  }) => {
+    const response = await api.get("/messages/search", { params });
+    return response.data;
+  },
+  // Attach files (photos or PDFs) to a message you sent
+  uploadAttachments: async (messageId: string, files: File[]) => {
+    const form = new FormData();
+    files.forEach((file) => form.append("file", file));
+    const response = await api.post(`/messages/${messageId}/attachments`, form);
+    return response.data;
+  },
+  // Download an attachment as a Blob, e.g. for URL.createObjectURL
+  downloadAttachment: async (attachmentId: string) => {
+    const response = await api.get(`/attachments/${attachmentId}`, { responseType: "blob" });
+    return response.data as Blob;
+  },
+  // Tell the other participants you are typing; call every few seconds while typing
+  sendTyping: async (threadId: string) => {
+    const response = await api.post(`/threads/${threadId}/typing`);
+    return response.data;
+  },
+};
+
+export interface MessageTemplateInput {
+  name: string;
+  subject?: string;
+  content: string;
+  category?: "refill" | "billing" | "symptoms" | "appointment" | "other";
+  shared?: boolean;
+}
+
+export interface TemplateRecipient {
+  patient_id?: string;
+  medication_id?: string;
+  reply_to_id?: string;
+}
+
+// Message template API functions (physicians only)
+export const templateAPI = {
+  list: async () => {
+    const response = await api.get("/templates");
+    return response.data;
+  },
+  create: async (template: MessageTemplateInput) => {
+    const response = await api.post("/templates", template);
+    return response.data;
+  },
+  update: async (templateId: string, template: MessageTemplateInput) => {
+    const response = await api.put(`/templates/${templateId}`, template);
+    return response.data;
+  },
+  remove: async (templateId: string) => {
+    const response = await api.delete(`/templates/${templateId}`);
+    return response.data;
+  },
+  preview: async (templateId: string, recipient: TemplateRecipient) => {
+    const response = await api.post(`/templates/${templateId}/preview`, recipient);
+    return response.data;
+  },
+  send: async (templateId: string, recipient: TemplateRecipient) => {
+    const response = await api.post(`/templates/${templateId}/send`, recipient);
+    return response.data;
+  },
+};
+
+export interface ConversationInput {
+  patient_id?: string;
+  physician_ids: string[];
+  subject: string;
+  content: string;
+}
+
+// Care-team conversation API functions. A conversation's id is also its
+// thread id, so use messageAPI for thread, read and typing calls.
+export const conversationAPI = {
+  list: async () => {
+    const response = await api.get("/conversations");
+    return response.data;
+  },
+  get: async (conversationId: string) => {
+    const response = await api.get(`/conversations/${conversationId}`);
+    return response.data;
+  },
+  create: async (conversation: ConversationInput) => {
+    const response = await api.post("/conversations", conversation);
+    return response.data;
+  },
+  send: async (conversationId: string, content: string) => {
+    const response = await api.post(`/conversations/${conversationId}/messages`, { content });
+    return response.data;
+  },
+  addParticipant: async (conversationId: string, physicianId: string, role: "owner" | "member" = "member") => {
+    const response = await api.post(`/conversations/${conversationId}/participants`, { physician_id: physicianId, role });
+    return response.data;
+  },
+  setRole: async (conversationId: string, physicianId: string, role: "owner" | "member") => {
+    const response = await api.put(`/conversations/${conversationId}/participants/${physicianId}`, { role });
+    return response.data;
+  },
+  // Remove a physician, or pass your own id to leave
+  removeParticipant: async (conversationId: string, physicianId: string) => {
+    const response = await api.delete(`/conversations/${conversationId}/participants/${physicianId}`);
+    return response.data;
+  },
+};
+
+export interface NotificationPreferences {
+  email_enabled: boolean;
+  sms_enabled: boolean;
+  in_app_enabled: boolean;
+  phone: string;
+  quiet_hours_start: string;
+  quiet_hours_end: string;
+  time_zone: string;
+  digest_minutes: number;
+}
+
+// Notification API functions
+export const notificationAPI = {
+  list: async (unreadOnly = false) => {
+    const response = await api.get("/notifications", { params: unreadOnly ? { unread: true } : {} });
+    return response.data;
+  },
+  markRead: async (notificationId: string) => {
+    const response = await api.post(`/notifications/${notificationId}/read`);
+    return response.data;
+  },
+  markAllRead: async () => {
+    const response = await api.post("/notifications/read");
+    return response.data;
+  },
+  getPreferences: async () => {
+    const response = await api.get("/notifications/preferences");
+    return response.data;
+  },
+  updatePreferences: async (preferences: NotificationPreferences) => {
+    const response = await api.put("/notifications/preferences", preferences);
+    return response.data;
+  },
+};
+
+export type RealtimeEventType =
+  | "message.created"
+  | "message.read"
+  | "message.attachments"
+  | "typing"
+  | "conversation.updated"
+  | "notification";
+
+// Subscribe to live messaging events. The browser reconnects on its own and
+// replays missed events via Last-Event-ID; if the stream is refused (usually an
+// expired token) the token is refreshed and the stream reopened from the last
+// event seen. Returns a function that closes the stream.
+export const subscribeEvents = (
+  onEvent: (type: RealtimeEventType, data: any) => void
+) => {
+  let source: EventSource | null = null;
+  let lastEventId = "";
+  let closed = false;
+
+  const open = async () => {
+    if (closed || !localStorage.getItem(TOKEN_KEY)) return;
+
+    // EventSource cannot send the token in a header, so trade it for a
+    // short-lived ticket rather than putting it in the URL
+    let ticket: string;
+    try {
+      const response = await api.post("/events/ticket");
+      ticket = response.data.ticket;
+    } catch {
+      setTimeout(open, 3000);
+      return;
+    }
+    if (closed) return;
+
+    const params = new URLSearchParams({ ticket });
+    if (lastEventId) params.set("cursor", lastEventId);
+    source = new EventSource(`${api.defaults.baseURL}/events?${params}`);
+
+    (["message.created", "message.read", "message.attachments", "typing", "conversation.updated", "notification"] as RealtimeEventType[]).forEach((type) => {
+      source!.addEventListener(type, (event) => {
+        const message = event as MessageEvent;
+        if (message.lastEventId) lastEventId = message.lastEventId;
+        onEvent(type, JSON.parse(message.data));
+      });
+    });
+
+    source.onerror = () => {
+      // Tickets expire quickly, so reconnect with a fresh one instead of
+      // letting EventSource retry the old URL. Requesting it refreshes the
+      // access token if that has expired too.
+      source?.close();
+      setTimeout(open, 3000);
+    };
+  };
+
+  open();
+  return () => {
+    closed = true;
+    source?.close();
+  };
+};
+
 export default api;
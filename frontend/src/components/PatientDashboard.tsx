@@ -2,11 +2,12 @@ import { useState, useRef, useEffect } from "react";
 import "./PatientDashboard.css";
 import DateRangePicker from "./DateRangePicker";
 import DoctorSearch from "./DoctorSearch";
-import { patientAPI } from "../api";
+import { authAPI, medicationAPI, patientAPI, refillAPI, subscribeEvents } from "../api";
+import type { UpcomingDose } from "../api";
 import api from "../api";
 
 interface PatientDashboardProps {
-  userId?: number | null;
+  userId?: string | null;
   userEmail?: string;
 }
 
@@ -18,6 +19,19 @@ interface Medication {
   instructions?: string;
 }
 
+interface Refill {
+  id: string;
+  medication_id: string;
+  status: "requested" | "approved" | "denied" | "sent_to_pharmacy";
+}
+
+const refillStatusText: Record<Refill["status"], string> = {
+  requested: "Refill requested",
+  approved: "Refill approved",
+  denied: "Refill not approved - check your messages",
+  sent_to_pharmacy: "Refill sent to your pharmacy",
+};
+
 interface Message {
   id: number;
   subject?: string;
@@ -51,24 +65,26 @@ function PatientDashboard({ userId, userEmail }: PatientDashboardProps) {
 
   // Data states
   const [medications, setMedications] = useState<Medication[]>([]);
+  const [nextDose, setNextDose] = useState<UpcomingDose | null>(null);
   const [messages, setMessages] = useState<Message[]>([]);
   const [physicians, setPhysicians] = useState<Physician[]>([]);
+  const [refills, setRefills] = useState<Refill[]>([]);
+  const [refillError, setRefillError] = useState<string>("");
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState<string>("");
-  const [patientId, setPatientId] = useState<number | null>(userId || null);
+  const [patientId, setPatientId] = useState<string | null>(userId || null);
 
-  // Fetch patient ID from email if not provided
+  // Fetch patient ID from the signed-in session if not provided
   useEffect(() => {
     const fetchPatientId = async () => {
       if (!patientId && userEmail) {
         try {
-          // We'll need to get patient ID from email
-          // For now, we'll use a default or try to get it from the backend
-          // This is a placeholder - you may need to add an endpoint to get user by email
-          setPatientId(1); // Fallback to ID 1 for demo
+          const me = await authAPI.me();
+          if (me.success && me.role === "patient") {
+            setPatientId(me.id);
+          }
         } catch (err) {
           console.error("Failed to fetch patient ID:", err);
-          setPatientId(1); // Fallback
         }
       }
     };
@@ -88,9 +104,10 @@ function PatientDashboard({ userId, userEmail }: PatientDashboardProps) {
       setError("");
 
       try {
-        // Fetch medications, messages, and physicians in parallel
-        const [medicationsRes, messagesRes, physiciansRes] = await Promise.all([
+        // Fetch medications, upcoming doses, messages, and physicians in parallel
+        const [medicationsRes, dosesRes, messagesRes, physiciansRes] = await Promise.all([
           patientAPI.getMedications(patientId).catch(() => ({ success: false, medications: [] })),
+          medicationAPI.doses(patientId).catch(() => ({ success: false, doses: [] })),
           patientAPI.getMessages(patientId).catch(() => ({ success: false, messages: [] })),
           patientAPI.getPhysicians(patientId).catch(() => ({ success: false, physicians: [] })),
         ]);
@@ -99,6 +116,10 @@ function PatientDashboard({ userId, userEmail }: PatientDashboardProps) {
           setMedications(medicationsRes.medications || []);
         }
 
+        if (dosesRes.success && dosesRes.doses?.length > 0) {
+          setNextDose(dosesRes.doses[0]);
+        }
+
         if (messagesRes.success) {
           setMessages(messagesRes.messages || []);
         }
@@ -117,6 +138,52 @@ function PatientDashboard({ userId, userEmail }: PatientDashboardProps) {
     fetchPatientData();
   }, [patientId]);
 
+  // Load refill requests
+  const loadRefills = async () => {
+    if (!patientId) return;
+    const refillsRes = await refillAPI.forPatient(patientId).catch(() => null);
+    if (refillsRes?.success) {
+      setRefills(refillsRes.refills || []);
+    }
+  };
+
+  // Refresh messages when one arrives or is read, and refill requests when
+  // a notification arrives, over one event stream
+  useEffect(() => {
+    if (!patientId) return;
+    loadRefills();
+
+    return subscribeEvents(async (type) => {
+      if (type === "notification") {
+        loadRefills();
+        return;
+      }
+      if (type !== "message.created" && type !== "message.read") return;
+      const messagesRes = await patientAPI.getMessages(patientId).catch(() => null);
+      if (messagesRes?.success) {
+        setMessages(messagesRes.messages || []);
+      }
+    });
+  }, [patientId]);
+
+  // Newest refill request for the medication shown on the reminder card
+  const latestRefill = medications.length > 0
+    ? refills.find((refill) => refill.medication_id === String(medications[0].id))
+    : undefined;
+
+  const handleRefill = async () => {
+    if (!patientId || medications.length === 0) return;
+    if (latestRefill && (latestRefill.status === "requested" || latestRefill.status === "approved")) return;
+
+    setRefillError("");
+    try {
+      await refillAPI.request(patientId, String(medications[0].id));
+      await loadRefills();
+    } catch (err: any) {
+      setRefillError(err.response?.data?.message || "Failed to request refill");
+    }
+  };
+
   const dates = [
     { x: 30, label: "Nov 23" },
     { x: 60, label: "Nov 24" },
@@ -257,7 +324,17 @@ function PatientDashboard({ userId, userEmail }: PatientDashboardProps) {
         ) : (
           <div className="medication-row">
             {/* Medication Reminder Card */}
-            {medications.length > 0 ? (
+            {nextDose ? (
+              <div className="card medication-card">
+                <h3 className="card-title">Medication Reminder</h3>
+                <h4 className="medication-name">{nextDose.medication_name}</h4>
+                <p className="medication-dosage">
+                  {nextDose.amount !== undefined &&
+                    `${nextDose.amount}${nextDose.max_amount ? `-${nextDose.max_amount}` : ""} ${nextDose.unit || ""} at `}
+                  {new Date(nextDose.at).toLocaleTimeString([], { hour: "numeric", minute: "2-digit" })}
+                </p>
+              </div>
+            ) : medications.length > 0 ? (
               <div className="card medication-card">
                 <h3 className="card-title">Medication Reminder</h3>
                 <h4 className="medication-name">{medications[0].name}</h4>
@@ -277,9 +354,14 @@ function PatientDashboard({ userId, userEmail }: PatientDashboardProps) {
             {/* Refill Alert Card */}
             <div className="card refill-card">
               <h3 className="card-title">Refill Alert</h3>
-              <p className="refill-action">Click Here to Refill</p>
+              <p className="refill-action" onClick={handleRefill}>Click Here to Refill</p>
               <p className="refill-status">
-                {medications.length > 0 ? "Check medication status" : "No medications to refill"}
+                {refillError ||
+                  (medications.length === 0
+                    ? "No medications to refill"
+                    : latestRefill
+                      ? refillStatusText[latestRefill.status]
+                      : "Check medication status")}
               </p>
             </div>
           </div>
@@ -395,7 +477,7 @@ function PatientDashboard({ userId, userEmail }: PatientDashboardProps) {
                 const physicianMessages = messages.filter(
                   (msg) => msg.physician?.id === physician.id
                 );
-                const unreadCount = physicianMessages.filter((msg) => !msg.read).length;
+                const unreadCount = physicianMessages.filter((msg) => !msg.read && msg.sender_type !== "patient").length;
                 
                 if (physicianMessages.length === 0) return null;
 
@@ -1,9 +1,9 @@
 import { useState } from "react";
 import "./PatientRegistration.css";
-import api from "../api";
+import api, { setAuthToken } from "../api";
 
 interface PatientRegistrationProps {
-  onSuccess?: (userId?: number) => void;
+  onSuccess?: (userId?: string) => void;
   onBack?: () => void;
 }
 
@@ -54,6 +54,7 @@ function PatientRegistration({ onSuccess, onBack }: PatientRegistrationProps) {
       const response = await api.post("/auth/register/patient", payload);
 
       if (response.data.success) {
+        setAuthToken(response.data.token, response.data.refresh_token);
         onSuccess?.(response.data.id);
       } else {
         setError(response.data.message || "Registration failed");
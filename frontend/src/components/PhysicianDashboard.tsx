@@ -2,11 +2,11 @@ import { useState, useRef, useEffect } from "react";
 import "./PhysicianDashboard.css";
 import DateRangePicker from "./DateRangePicker";
 import DoctorSearch from "./DoctorSearch";
-import { physicianAPI } from "../api";
+import { authAPI, physicianAPI, refillAPI, subscribeEvents } from "../api";
 import api from "../api";
 
 interface PhysicianDashboardProps {
-  userId?: number | null;
+  userId?: string | null;
   userEmail?: string;
 }
 
@@ -19,6 +19,13 @@ interface Patient {
   verified?: boolean;
 }
 
+interface Refill {
+  id: string;
+  patient_id: string;
+  patient?: { name: string };
+  medication?: { name: string; dosage?: string };
+}
+
 interface Message {
   id: number;
   subject?: string;
@@ -47,22 +54,22 @@ function PhysicianDashboard({ userId, userEmail }: PhysicianDashboardProps) {
   // Data states
   const [patients, setPatients] = useState<Patient[]>([]);
   const [messages, setMessages] = useState<Message[]>([]);
+  const [refills, setRefills] = useState<Refill[]>([]);
   const [loading, setLoading] = useState(true);
   const [error, setError] = useState<string>("");
-  const [physicianId, setPhysicianId] = useState<number | null>(userId || null);
+  const [physicianId, setPhysicianId] = useState<string | null>(userId || null);
 
-  // Fetch physician ID from email if not provided
+  // Fetch physician ID from the signed-in session if not provided
   useEffect(() => {
     const fetchPhysicianId = async () => {
       if (!physicianId && userEmail) {
         try {
-          // We'll need to get physician ID from email
-          // For now, we'll use a default or try to get it from the backend
-          // This is a placeholder - you may need to add an endpoint to get user by email
-          setPhysicianId(1); // Fallback to ID 1 for demo
+          const me = await authAPI.me();
+          if (me.success && me.role === "physician") {
+            setPhysicianId(me.id);
+          }
         } catch (err) {
           console.error("Failed to fetch physician ID:", err);
-          setPhysicianId(1); // Fallback
         }
       }
     };
@@ -106,6 +113,54 @@ function PhysicianDashboard({ userId, userEmail }: PhysicianDashboardProps) {
     fetchPhysicianData();
   }, [physicianId]);
 
+  // Load refill requests waiting for review
+  const loadRefills = async () => {
+    if (!physicianId) return;
+    const refillsRes = await refillAPI.forPhysician(physicianId, "requested").catch(() => null);
+    if (refillsRes?.success) {
+      setRefills(refillsRes.refills || []);
+    }
+  };
+
+  // Refresh messages when one arrives or is read, and refill requests when
+  // a notification arrives, over one event stream
+  useEffect(() => {
+    if (!physicianId) return;
+    loadRefills();
+
+    return subscribeEvents(async (type) => {
+      if (type === "notification") {
+        loadRefills();
+        return;
+      }
+      if (type !== "message.created" && type !== "message.read") return;
+      const messagesRes = await physicianAPI.getMessages(physicianId).catch(() => null);
+      if (messagesRes?.success) {
+        setMessages(messagesRes.messages || []);
+      }
+    });
+  }, [physicianId]);
+
+  // Review the longest waiting refill request
+  const handleRefill = async () => {
+    const refill = refills[0];
+    if (!refill) return;
+
+    const what = `${refill.medication?.name || "medication"} for ${refill.patient?.name || "this patient"}`;
+    try {
+      if (window.confirm(`Approve the refill of ${what}?`)) {
+        await refillAPI.approve(refill.patient_id, refill.id);
+      } else {
+        const note = window.prompt(`Why are you not approving the refill of ${what}? Leave empty to decide later.`);
+        if (!note) return;
+        await refillAPI.deny(refill.patient_id, refill.id, note);
+      }
+    } catch (err) {
+      console.error("Failed to review refill:", err);
+    }
+    await loadRefills();
+  };
+
   const dates = [
     { x: 30, label: "Nov 23" },
     { x: 60, label: "Nov 24" },
@@ -265,9 +320,13 @@ function PhysicianDashboard({ userId, userEmail }: PhysicianDashboardProps) {
             {/* Patient Refill Alert Card */}
             <div className="card patient-refill-card">
               <h3 className="card-title">Patient Refill Alert</h3>
-              <p className="refill-action">Click Here to Prod</p>
+              <p className="refill-action" onClick={handleRefill}>
+                {refills.length > 0 ? "Click Here to Review" : "No refills waiting"}
+              </p>
               <p className="refill-status">
-                {patients.length > 0 ? `${patients.length} Patient${patients.length > 1 ? "s" : ""} to review` : "No patients"}
+                {refills.length > 0
+                  ? `${refills.length} Refill request${refills.length > 1 ? "s" : ""} to review`
+                  : "No refill requests"}
               </p>
             </div>
           </div>
@@ -383,7 +442,7 @@ function PhysicianDashboard({ userId, userEmail }: PhysicianDashboardProps) {
                 const patientMessages = messages.filter(
                   (msg) => msg.patient?.id === patient.id
                 );
-                const unreadCount = patientMessages.filter((msg) => !msg.read).length;
+                const unreadCount = patientMessages.filter((msg) => !msg.read && msg.sender_type !== "physician").length;
                 
                 if (patientMessages.length === 0) return null;
 
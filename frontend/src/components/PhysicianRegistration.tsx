@@ -1,9 +1,9 @@
 import { useState } from "react";
 import "./PhysicianRegistration.css";
-import api from "../api";
+import api, { setAuthToken } from "../api";
 
 interface PhysicianRegistrationProps {
-  onSuccess?: (userId?: number) => void;
+  onSuccess?: (userId?: string) => void;
   onBack?: () => void;
 }
 
@@ -57,6 +57,8 @@ function PhysicianRegistration({ onSuccess, onBack }: PhysicianRegistrationProps
     name: "",
     address: "",
     license: "",
+    licenseState: "",
+    npi: "",
     officeLocation: "",
     specialties: [] as string[],
   });
@@ -99,6 +101,8 @@ function PhysicianRegistration({ onSuccess, onBack }: PhysicianRegistrationProps
         name: formData.name.trim(),
         address: formData.address.trim(),
         license: formData.license.trim(),
+        license_state: formData.licenseState.trim().toUpperCase(),
+        npi: formData.npi.trim(),
         office_location: formData.officeLocation.trim(),
         specialties: formData.specialties,
       };
@@ -106,6 +110,7 @@ function PhysicianRegistration({ onSuccess, onBack }: PhysicianRegistrationProps
       const response = await api.post("/auth/register/physician", payload);
 
       if (response.data.success) {
+        setAuthToken(response.data.token, response.data.refresh_token);
         onSuccess?.(response.data.id);
       } else {
         setError(response.data.message || "Registration failed");
@@ -215,6 +220,38 @@ function PhysicianRegistration({ onSuccess, onBack }: PhysicianRegistrationProps
             />
           </div>
 
+          <div className="form-group">
+            <label htmlFor="licenseState">License Issuing State *</label>
+            <input
+              type="text"
+              id="licenseState"
+              name="licenseState"
+              value={formData.licenseState}
+              onChange={handleChange}
+              required
+              minLength={2}
+              maxLength={2}
+              className="form-input"
+              placeholder="GA"
+            />
+          </div>
+
+          <div className="form-group">
+            <label htmlFor="npi">NPI Number *</label>
+            <input
+              type="text"
+              id="npi"
+              name="npi"
+              value={formData.npi}
+              onChange={handleChange}
+              required
+              pattern="[0-9]{10}"
+              maxLength={10}
+              className="form-input"
+              placeholder="1234567893"
+            />
+          </div>
+
           <div className="form-group">
             <label htmlFor="officeLocation">Office Location *</label>
             <textarea
@@ -1,10 +1,10 @@
 import { useState } from "react";
 import "./SignIn.css";
-import api from "../api";
+import api, { setAuthToken } from "../api";
 
 interface SignInProps {
-  onPatientSignIn?: (email: string, userId?: number) => void;
-  onPhysicianSignIn?: (email: string, userId?: number) => void;
+  onPatientSignIn?: (email: string, userId?: string) => void;
+  onPhysicianSignIn?: (email: string, userId?: string) => void;
   onPatientRegister?: () => void;
   onPhysicianRegister?: () => void;
 }
@@ -19,6 +19,22 @@ function SignIn({ onPatientSignIn, onPhysicianSignIn, onPatientRegister, onPhysi
   const [patientLoading, setPatientLoading] = useState(false);
   const [physicianLoading, setPhysicianLoading] = useState(false);
 
+  // Second login step for accounts with MFA enabled
+  const completeMfa = async (data: any) => {
+    if (!data.mfa_required) {
+      return data;
+    }
+    const code = window.prompt("Enter the 6-digit code from your authenticator app, or a recovery code")?.trim();
+    if (!code) {
+      return { success: false };
+    }
+    const response = await api.post("/auth/mfa/verify", {
+      mfa_token: data.mfa_token,
+      ...(/^\d{6}$/.test(code) ? { code } : { recovery_code: code }),
+    });
+    return response.data;
+  };
+
   const handlePatientContinue = async (e: React.FormEvent) => {
     e.preventDefault();
     setPatientError("");
@@ -30,21 +46,17 @@ function SignIn({ onPatientSignIn, onPhysicianSignIn, onPatientRegister, onPhysi
         onPatientSignIn?.(patientEmail);
       } else {
         // Try API call
-        const response = await api.post("/auth/patient", {
+        const response = await api.post("/auth/login", {
           email: patientEmail,
           password: patientPassword,
+          role: "patient",
         });
         
-        if (response.data.success) {
-          // Try to get user ID from token or make a separate call
-          // For now, we'll need to fetch the patient by email to get ID
-          try {
-            // We'll need to get the patient ID - for now pass undefined
-            // The dashboard will need to handle this
-            onPatientSignIn?.(patientEmail, response.data.id);
-          } catch {
-            onPatientSignIn?.(patientEmail);
-          }
+        const data = await completeMfa(response.data);
+
+        if (data.success) {
+          setAuthToken(data.token, data.refresh_token);
+          onPatientSignIn?.(patientEmail, data.id);
         } else {
           setPatientError("Invalid email or password");
         }
@@ -54,7 +66,7 @@ function SignIn({ onPatientSignIn, onPhysicianSignIn, onPatientRegister, onPhysi
       if (patientEmail.trim() === "demo@example.com" && patientPassword === "password") {
         onPatientSignIn?.(patientEmail);
       } else {
-        setPatientError("Invalid email or password");
+        setPatientError(error.response?.data?.message || "Invalid email or password");
       }
     } finally {
       setPatientLoading(false);
@@ -72,21 +84,17 @@ function SignIn({ onPatientSignIn, onPhysicianSignIn, onPatientRegister, onPhysi
         onPhysicianSignIn?.(physicianEmail);
       } else {
         // Try API call
-        const response = await api.post("/auth/physician", {
+        const response = await api.post("/auth/login", {
           email: physicianEmail,
           password: physicianPassword,
+          role: "physician",
         });
         
-        if (response.data.success) {
-          // Try to get user ID from token or make a separate call
-          // For now, we'll need to fetch the physician by email to get ID
-          try {
-            // We'll need to get the physician ID - for now pass undefined
-            // The dashboard will need to handle this
-            onPhysicianSignIn?.(physicianEmail, response.data.id);
-          } catch {
-            onPhysicianSignIn?.(physicianEmail);
-          }
+        const data = await completeMfa(response.data);
+
+        if (data.success) {
+          setAuthToken(data.token, data.refresh_token);
+          onPhysicianSignIn?.(physicianEmail, data.id);
         } else {
           setPhysicianError("Invalid email or password");
         }
@@ -96,7 +104,7 @@ function SignIn({ onPatientSignIn, onPhysicianSignIn, onPatientRegister, onPhysi
       if (physicianEmail.trim() === "doctor@example.com" && physicianPassword === "password") {
         onPhysicianSignIn?.(physicianEmail);
       } else {
-        setPhysicianError("Invalid email or password");
+        setPhysicianError(error.response?.data?.message || "Invalid email or password");
       }
     } finally {
       setPhysicianLoading(false);